@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/spf13/cobra"
+)
+
+var adoptConvertExec bool
+
+// adoptCmd represents the adopt command
+var adoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Bring DOKS contexts created by doctl under kubectl-doks management",
+	Long: `Finds do-<region>-<name> contexts that were not written by kubectl-doks, such as those saved by
+"doctl kubernetes cluster kubeconfig save", and matches them to live clusters by API server URL or by name.
+Matched contexts are tagged with the digitalocean.com/cluster-id extension, and renamed if their cluster now
+uses another context name, so that sync manages them instead of rewriting them.
+Their credentials are kept, unless --convert-exec is given, in which case contexts that run doctl as an
+exec plugin get a token from the DigitalOcean API instead, like the contexts saved by kubectl-doks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		syncer, store, err := newSyncer(&accountClusters{}, nil, func(options *doks.Options) {
+			options.ConvertExec = adoptConvertExec
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := syncer.Adopt(context.Background())
+		printMessages(result, store)
+		recordResult(cmd, store, result)
+		if err != nil {
+			return err
+		}
+
+		if len(result.Adopted) == 0 {
+			fmt.Println("No DOKS contexts to adopt.")
+			return nil
+		}
+		for _, a := range result.Adopted {
+			fmt.Println(a)
+		}
+		return nil
+	},
+}
+
+func init() {
+	adoptCmd.Flags().BoolVar(&adoptConvertExec, "convert-exec", false, "Replace doctl exec credentials of adopted contexts with a token")
+	kubeconfigCmd.AddCommand(adoptCmd)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+const doctlKubeconfigForAdopt = `apiVersion: v1
+kind: Config
+current-context: do-nyc1-prod
+clusters:
+- name: do-nyc1-prod
+  cluster:
+    server: https://prod-id.k8s.ondigitalocean.com
+- name: do-nyc1-staging
+  cluster:
+    server: https://staging-id.k8s.ondigitalocean.com
+users:
+- name: do-nyc1-prod-admin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: doctl
+      args:
+      - kubernetes
+      - cluster
+      - kubeconfig
+      - exec-credential
+      - --version=v1beta1
+      - prod-id
+- name: do-nyc1-staging-admin
+  user:
+    token: staging-token
+contexts:
+- name: do-nyc1-prod
+  context:
+    cluster: do-nyc1-prod
+    user: do-nyc1-prod-admin
+- name: do-nyc1-staging
+  context:
+    cluster: do-nyc1-staging
+    user: do-nyc1-staging-admin
+`
+
+// newAdoptServer returns a server listing the running clusters prod and staging, with their API server URLs.
+// The names of the clusters whose credentials were fetched are added to fetched.
+func newAdoptServer(t *testing.T, fetched *[]string) *dotest.Server {
+	server := newTestServer()
+	for _, name := range []string{"prod", "staging"} {
+		server.AddCluster(do.Cluster{ID: name + "-id", Name: name, Region: "nyc1", Endpoint: "https://" + name + "-id.k8s.ondigitalocean.com"})
+	}
+	server.SetTokenFunc(func(cluster do.Cluster) string { return "new-" + cluster.Name + "-token" })
+	server.OnRequest(func(request dotest.Request) {
+		if request.Route == dotest.RouteCredentials {
+			*fetched = append(*fetched, strings.TrimSuffix(request.ClusterID, "-id"))
+		}
+	})
+	return server
+}
+
+func TestAdoptCommand(t *testing.T) {
+	var fetched []string
+	server := newAdoptServer(t, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, []byte(doctlKubeconfigForAdopt), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalAdoptConvertExec := apiURL, accessTokens, kubeConfigPath, adoptConvertExec
+	apiURL, accessTokens, kubeConfigPath, adoptConvertExec = server.URL, []string{"test-token"}, "", true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, adoptConvertExec = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalAdoptConvertExec
+	}()
+
+	require.NoError(t, adoptCmd.RunE(adoptCmd, []string{}))
+	assert.Equal(t, []string{"prod"}, fetched, "Only doctl exec credentials should be replaced")
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	for _, name := range []string{"prod", "staging"} {
+		id, found := kubeconfig.GetClusterID(updated.Clusters["do-nyc1-"+name])
+		assert.True(t, found)
+		assert.Equal(t, name+"-id", id)
+	}
+	assert.Nil(t, updated.AuthInfos["do-nyc1-prod-admin"].Exec)
+	assert.Equal(t, "new-prod-token", updated.AuthInfos["do-nyc1-prod-admin"].Token)
+	assert.Equal(t, "staging-token", updated.AuthInfos["do-nyc1-staging-admin"].Token)
+	assert.Equal(t, "do-nyc1-prod", updated.CurrentContext)
+
+	_, err = os.Stat(finalKubeConfigPath + ".kubectl-doks.bak")
+	assert.NoError(t, err, "A backup should be created before adopting contexts")
+}
+
+func TestSyncCommandWithAdopt(t *testing.T) {
+	var fetched []string
+	server := newAdoptServer(t, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, []byte(doctlKubeconfigForAdopt), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncAdopt := apiURL, accessTokens, kubeConfigPath, syncAdopt
+	apiURL, accessTokens, kubeConfigPath, syncAdopt = server.URL, []string{"test-token"}, "", true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, syncAdopt = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncAdopt
+	}()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.Empty(t, fetched, "Adopted contexts should not be rewritten")
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+	id, _ := kubeconfig.GetClusterID(updated.Clusters["do-nyc1-prod"])
+	assert.Equal(t, "prod-id", id)
+	assert.NotNil(t, updated.AuthInfos["do-nyc1-prod-admin"].Exec, "doctl exec credentials should be kept without --convert-exec")
+}
+
+func TestSyncCommandConvertExecRequiresAdopt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	originalSyncAdopt, originalSyncConvertExec := syncAdopt, syncConvertExec
+	syncAdopt, syncConvertExec = false, true
+	defer func() { syncAdopt, syncConvertExec = originalSyncAdopt, originalSyncConvertExec }()
+
+	assert.EqualError(t, syncCmd.RunE(syncCmd, []string{}), "--convert-exec requires --adopt")
+}
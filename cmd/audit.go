@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/audit"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/journal"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/spf13/cobra"
+)
+
+// usedAuthContexts are the doctl auth contexts of the tokens used by the last listAllClusters call, for the audit log.
+var usedAuthContexts []string
+
+// recordChange appends a record of the change cmd made to the kubeconfig at path to the audit log, and saves the
+// patch reverting it to the operation journal. before is the kubeconfig before the change, and backup the path of
+// its backup, if one was made. It returns the ID of the operation, or an empty string if it was not journaled.
+// As the change is already made, failing to record it is only a warning.
+func recordChange(cmd *cobra.Command, path string, before []byte, backup string, added, updated, removed []string) string {
+	after, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(messages, "Warning: Could not record the change in the audit log: %v\n", err)
+		return ""
+	}
+
+	record := newAuditRecord(cmd)
+	record.Kubeconfig = path
+	record.Added, record.Updated, record.Removed = added, updated, removed
+	record.Backup = backup
+	record.BeforeHash, record.AfterHash = audit.Hash(before), audit.Hash(after)
+
+	op, err := journalChange(record.Command, path, before, after, record.Time)
+	if err != nil {
+		fmt.Fprintf(messages, "Warning: Could not record the change in the operation journal: %v\n", err)
+	}
+	record.Operation = op
+
+	appendAuditRecord(record)
+	return op
+}
+
+// recordSplitResult records the change described by result, made to the split layout directory dir, in the audit log,
+// if any file was written or removed. The record lists the files added, updated and removed instead of contexts.
+// It is not journaled, as undo only reverts changes to a kubeconfig file.
+func recordSplitResult(cmd *cobra.Command, dir string, result doks.Result) {
+	if !result.Written {
+		return
+	}
+	record := newAuditRecord(cmd)
+	record.Kubeconfig = dir
+	for _, contextName := range result.Added {
+		record.Added = append(record.Added, result.Files[contextName])
+	}
+	for _, contextName := range slices.Concat(result.Renewed, result.Updated) {
+		record.Updated = append(record.Updated, result.Files[contextName])
+	}
+	record.Removed = slices.Clone(result.Removed)
+	for _, contextName := range result.Expired {
+		record.Removed = append(record.Removed, result.Files[contextName])
+	}
+	appendAuditRecord(record)
+}
+
+// newAuditRecord returns an audit record of a change made now by cmd, with the auth contexts used.
+func newAuditRecord(cmd *cobra.Command) audit.Record {
+	command := strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	record := audit.NewRecord(command, os.Args)
+	record.AuthContexts = usedAuthContexts
+	return record
+}
+
+// appendAuditRecord appends record to the audit log. As the change is already made, failing to do so is only a warning.
+func appendAuditRecord(record audit.Record) {
+	logPath, err := audit.DefaultPath()
+	if err == nil {
+		err = audit.Append(logPath, record)
+	}
+	if err != nil {
+		fmt.Fprintf(messages, "Warning: Could not record the change in the audit log: %v\n", err)
+	}
+}
+
+// journalChange saves the patch from before to after of the kubeconfig at path to the operation journal,
+// and returns the ID of the operation. Changes that do not touch DOKS contexts or the current context are not journaled.
+func journalChange(command, path string, before, after []byte, at time.Time) (string, error) {
+	patch, err := kubeconfig.DiffManaged(before, after)
+	if err != nil || patch.Empty() {
+		return "", err
+	}
+	dir, err := journal.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	op := journal.Operation{ID: journal.NewID(), Time: at, Command: command, Kubeconfig: path, Patch: patch}
+	if err := journal.Save(dir, op); err != nil {
+		return "", err
+	}
+	return op.ID, nil
+}
+
+// recordResult records the change described by result in the audit log, if the kubeconfig of store was written.
+func recordResult(cmd *cobra.Command, store *kubeconfigStore, result doks.Result) {
+	if !result.Written {
+		return
+	}
+	var backup string
+	if store.BackedUp {
+		backup = store.Path + ".kubectl-doks.bak"
+	}
+	added, updated, removed := changedContexts(result)
+	recordChange(cmd, store.Path, store.original, backup, added, updated, removed)
+}
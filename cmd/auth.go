@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -12,7 +16,7 @@ import (
 // validateAuthSources ensures that mutually exclusive authentication flags are not used together.
 func validateAuthSources() error {
     authMethods := 0
-    if len(accessTokens) > 0 {
+    if hasExplicitTokens() {
         authMethods++
     }
     if len(authContexts) > 0 {
@@ -23,20 +27,48 @@ func validateAuthSources() error {
     }
 
     if authMethods > 1 {
-        return fmt.Errorf("only one of --access-token, --auth-context, or --all-auth-contexts flags can be specified")
+        return fmt.Errorf("only one of --access-token (or --access-token-file, --access-token-stdin, --access-token-command), --auth-context, or --all-auth-contexts flags can be specified")
     }
     return nil
 }
 
-// getAllAccessTokens gathers access tokens following a specific precedence order:
-// 1. --access-token flags
+// authSource is an access token together with information about where it came from.
+type authSource struct {
+	// Token is the DigitalOcean API token.
+	Token string
+	// AuthContext is the name of the doctl authentication context the token was read from, if any.
+	AuthContext string
+	// APIURL is the DigitalOcean API endpoint the token is used with. Empty means the default endpoint.
+	APIURL string
+}
+
+// getAuthSources gathers access tokens following a specific precedence order:
+// 1. --access-token, --access-token-file, --access-token-stdin and --access-token-command flags
 // 2. --auth-context or --all-auth-contexts flags (from doctl config)
 // 3. DIGITALOCEAN_ACCESS_TOKEN environment variable
 // 4. Current doctl authentication context
-func getAllAccessTokens() ([]string, error) {
-	// 1. --access-token
-	if len(accessTokens) > 0 {
-		return unique(accessTokens), nil
+//
+// Each source also carries the API endpoint to use it with, following this precedence order:
+// 1. A token given as <token>@<url>
+// 2. --api-url flag
+// 3. The auth context's api-url in the kubectl-doks config file (doctl tokens only)
+// 4. The api-url in the doctl config file (doctl tokens only)
+func getAuthSources() ([]authSource, error) {
+	// 1. --access-token and external token sources
+	if hasExplicitTokens() {
+		explicitTokens, err := getExplicitTokens()
+		if err != nil {
+			return nil, err
+		}
+		var sources []authSource
+		for _, token := range explicitTokens {
+			url := token.APIURL
+			if url == "" {
+				url = apiURL
+			}
+			sources = append(sources, authSource{Token: token.Token, APIURL: url})
+		}
+		return uniqueSources(sources), nil
 	}
 
 	// We might need the doctl config for the next steps.
@@ -51,35 +83,177 @@ func getAllAccessTokens() ([]string, error) {
 			// Config file does not exist, but flags were provided that require it.
 			return nil, fmt.Errorf("doctl config file not found at %q", getDoctlConfigPath())
 		}
-		tokens, err := getTokensFromDoctlConfig(doctlConfig)
+		sources, err := getTokensFromDoctlConfig(doctlConfig)
 		if err != nil {
 			return nil, err
 		}
-		if len(tokens) > 0 {
-			return tokens, nil
+		if len(sources) > 0 {
+			sources, err = withDoctlAPIURLs(doctlConfig, sources)
+			if err != nil {
+				return nil, err
+			}
+			return uniqueSources(sources), nil
 		}
 		return nil, fmt.Errorf("no tokens found for the specified auth contexts")
 	}
 
 	// 3. Environment variables
 	if token := os.Getenv("DIGITALOCEAN_ACCESS_TOKEN"); token != "" {
-		return []string{token}, nil
+		return []authSource{{Token: token, APIURL: apiURL}}, nil
 	}
 
 	// 4. Current doctl authentication context
 	if doctlConfig != nil {
-		tokens, err := getCurrentDoctlContextToken(doctlConfig)
+		sources, err := getCurrentDoctlContextToken(doctlConfig)
 		if err != nil {
 			return nil, err
 		}
-		if len(tokens) > 0 {
-			return tokens, nil
+		if len(sources) > 0 {
+			return withDoctlAPIURLs(doctlConfig, sources)
 		}
 	}
 
 	return nil, fmt.Errorf("no DigitalOcean access token found")
 }
 
+// withDoctlAPIURLs sets the API endpoint of sources read from the doctl config, see getAuthSources.
+func withDoctlAPIURLs(doctlConfig *viper.Viper, sources []authSource) ([]authSource, error) {
+	if apiURL != "" {
+		for i := range sources {
+			sources[i].APIURL = apiURL
+		}
+		return sources, nil
+	}
+
+	pluginConfig, err := loadPluginConfig()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		sources[i].APIURL = authContextAPIURL(pluginConfig, sources[i].AuthContext)
+		if sources[i].APIURL == "" {
+			sources[i].APIURL = doctlConfig.GetString("api-url")
+		}
+	}
+	return sources, nil
+}
+
+// explicitToken is a token given on the command line or read from an external credential source.
+type explicitToken struct {
+	// Label describes where the token came from, for use in messages. It never contains the token.
+	Label string
+	Token string
+	// APIURL is the endpoint given with the <token>@<url> syntax, if any.
+	APIURL string
+}
+
+// splitTokenURL splits a value in the <token>@<url> form into the token and the API endpoint.
+// Values without an @http:// or @https:// suffix are returned unchanged with an empty endpoint.
+func splitTokenURL(value string) (string, string) {
+	for _, scheme := range []string{"@https://", "@http://"} {
+		if i := strings.LastIndex(value, scheme); i > 0 {
+			return value[:i], value[i+1:]
+		}
+	}
+	return value, ""
+}
+
+// tokenStdin is where --access-token-stdin reads tokens from.
+var tokenStdin io.Reader = os.Stdin
+
+// hasExplicitTokens reports whether any flag that provides tokens directly was used.
+func hasExplicitTokens() bool {
+	return len(accessTokens) > 0 || len(accessTokenFiles) > 0 || accessTokenStdin || len(accessTokenCommands) > 0
+}
+
+// getExplicitTokens gathers the tokens given with --access-token and read from the external credential sources:
+// --access-token-file, --access-token-stdin and --access-token-command.
+// Files, stdin and command output may hold one token per line; blank lines and lines starting with # are ignored.
+func getExplicitTokens() ([]explicitToken, error) {
+	var tokens []explicitToken
+	for i, token := range accessTokens {
+		tokens = append(tokens, explicitToken{Label: fmt.Sprintf("--access-token #%d", i+1), Token: token})
+	}
+
+	for _, path := range accessTokenFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading access token file: %w", err)
+		}
+		fileTokens, err := parseTokens(data, fmt.Sprintf("file %s", path))
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range fileTokens {
+			tokens = append(tokens, explicitToken{Label: fmt.Sprintf("--access-token-file %s", path), Token: token})
+		}
+	}
+
+	if accessTokenStdin {
+		data, err := io.ReadAll(tokenStdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading access token from stdin: %w", err)
+		}
+		stdinTokens, err := parseTokens(data, "stdin")
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range stdinTokens {
+			tokens = append(tokens, explicitToken{Label: "--access-token-stdin", Token: token})
+		}
+	}
+
+	for i, command := range accessTokenCommands {
+		data, err := runTokenCommand(i+1, command)
+		if err != nil {
+			return nil, err
+		}
+		commandTokens, err := parseTokens(data, fmt.Sprintf("the output of access token command #%d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range commandTokens {
+			tokens = append(tokens, explicitToken{Label: fmt.Sprintf("--access-token-command #%d", i+1), Token: token})
+		}
+	}
+
+	for i := range tokens {
+		tokens[i].Token, tokens[i].APIURL = splitTokenURL(tokens[i].Token)
+	}
+	return tokens, nil
+}
+
+// runTokenCommand runs command, the number-th --access-token-command, through the shell and returns its standard output.
+// Standard input and standard error are passed through so that tools such as password managers can prompt the user.
+// Errors leave out the command, which may hold secrets or secret-manager paths.
+func runTokenCommand(number int, command string) ([]byte, error) {
+	c := exec.Command("sh", "-c", command)
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("access token command #%d failed: %w", number, err)
+	}
+	return out, nil
+}
+
+// parseTokens extracts tokens from data, one per line, skipping blank lines and # comments.
+// An error mentioning origin is returned if no token is found.
+func parseTokens(data []byte, origin string) ([]string, error) {
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no access token found in %s", origin)
+	}
+	return tokens, nil
+}
+
 // loadDoctlConfig loads the doctl configuration file.
 func loadDoctlConfig() (*viper.Viper, error) {
 	v := viper.New()
@@ -96,48 +270,58 @@ func loadDoctlConfig() (*viper.Viper, error) {
 }
 
 // getTokensFromDoctlConfig retrieves tokens from specified doctl auth contexts.
-func getTokensFromDoctlConfig(v *viper.Viper) ([]string, error) {
+func getTokensFromDoctlConfig(v *viper.Viper) ([]authSource, error) {
 	var contextsToUse []string
 	if allAuthContexts {
-		settings := v.AllSettings()
-		if authContextsMap, ok := settings["auth-contexts"].(map[string]interface{}); ok {
-			for name := range authContextsMap {
-				contextsToUse = append(contextsToUse, name)
-			}
-		} else if v.IsSet("access-token") {
-			// If no auth-contexts map exists, but there's a top-level token,
-			// consider 'default' as the only available context.
-			contextsToUse = append(contextsToUse, "default")
-		}
+		contextsToUse = doctlAuthContextNames(v)
 	} else {
 		contextsToUse = authContexts
 	}
 
-	var tokens []string
+	var sources []authSource
 	for _, context := range contextsToUse {
-		var token string
-		if context == "default" {
-			// The 'default' context can be a named context or refer to the top-level token.
-			// If 'auth-contexts.default' is 'true' or not set, use the top-level token.
-			if !v.IsSet("auth-contexts."+context) || v.GetString("auth-contexts."+context) == "true" {
-				token = v.GetString("access-token")
-			} else {
-				// It's a named context with its own token.
-				token = v.GetString("auth-contexts.default")
-			}
-		} else {
-			token = v.GetString(fmt.Sprintf("auth-contexts.%s", context))
+		if token := doctlContextToken(v, context); token != "" {
+			sources = append(sources, authSource{Token: token, AuthContext: context})
 		}
-		if token != "" {
-			tokens = append(tokens, token)
+	}
+
+	return sources, nil
+}
+
+// doctlAuthContextNames returns the names of all auth contexts defined in the doctl config, sorted by name.
+func doctlAuthContextNames(v *viper.Viper) []string {
+	var names []string
+	settings := v.AllSettings()
+	if authContextsMap, ok := settings["auth-contexts"].(map[string]interface{}); ok {
+		for name := range authContextsMap {
+			names = append(names, name)
 		}
+		// Sort for a stable order so duplicate tokens always resolve to the same context.
+		sort.Strings(names)
+	} else if v.IsSet("access-token") {
+		// If no auth-contexts map exists, but there's a top-level token,
+		// consider 'default' as the only available context.
+		names = append(names, "default")
 	}
+	return names
+}
 
-	return unique(tokens), nil
+// doctlContextToken returns the token of a doctl auth context, or an empty string if it has none.
+func doctlContextToken(v *viper.Viper, context string) string {
+	if context == "default" {
+		// The 'default' context can be a named context or refer to the top-level token.
+		// If 'auth-contexts.default' is 'true' or not set, use the top-level token.
+		if !v.IsSet("auth-contexts."+context) || v.GetString("auth-contexts."+context) == "true" {
+			return v.GetString("access-token")
+		}
+		// It's a named context with its own token.
+		return v.GetString("auth-contexts.default")
+	}
+	return v.GetString(fmt.Sprintf("auth-contexts.%s", context))
 }
 
 // getCurrentDoctlContextToken retrieves the token from the current doctl context.
-func getCurrentDoctlContextToken(v *viper.Viper) ([]string, error) {
+func getCurrentDoctlContextToken(v *viper.Viper) ([]authSource, error) {
 	currentContext := v.GetString("context")
 	if currentContext == "" {
 		// If 'context' is not explicitly set, doctl uses 'default'.
@@ -164,7 +348,7 @@ func getCurrentDoctlContextToken(v *viper.Viper) ([]string, error) {
 		return nil, nil // No token found for the context.
 	}
 
-	return []string{token}, nil
+	return []authSource{{Token: token, AuthContext: currentContext}}, nil
 }
 
 // getDoctlConfigPath determines the path to the doctl config file based on the OS.
@@ -198,3 +382,17 @@ func unique(slice []string) []string {
 	}
 	return list
 }
+
+// uniqueSources returns a new slice with sources sharing a token and API endpoint removed, keeping the first occurrence.
+func uniqueSources(sources []authSource) []authSource {
+	seen := make(map[authSource]bool)
+	var list []authSource
+	for _, source := range sources {
+		key := authSource{Token: source.Token, APIURL: source.APIURL}
+		if !seen[key] {
+			seen[key] = true
+			list = append(list, source)
+		}
+	}
+	return list
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/spf13/cobra"
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect DigitalOcean authentication",
+	Long:  `Commands to inspect the DigitalOcean access tokens and doctl authentication contexts used by kubectl-doks.`,
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Validate access tokens and doctl authentication contexts",
+	Long: `Checks every doctl authentication context (or the tokens given with --access-token and the other token flags) and reports,
+for each one, the team and account it belongs to, whether the token is valid, and whether it can list
+Kubernetes clusters. Problems such as a missing doctl config file, empty tokens or tokens shared by
+several contexts are reported with a suggested fix. Exits with a non-zero status if any problem is found.`,
+	// auth status reports missing credentials itself.
+	Annotations: map[string]string{credentialsAnnotation: credentialsOptional},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, problems, err := authStatusEntries()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		for i := range entries {
+			checkAuthStatus(ctx, &entries[i])
+		}
+
+		out := cmd.OutOrStdout()
+		printAuthStatus(out, entries)
+
+		for _, entry := range entries {
+			for _, problem := range entry.Problems {
+				problems = append(problems, fmt.Sprintf("%s: %s", entry.Name, problem))
+			}
+		}
+		if len(problems) == 0 {
+			return nil
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Problems:")
+		for _, problem := range problems {
+			fmt.Fprintf(out, "  - %s\n", problem)
+		}
+		return fmt.Errorf("found %d authentication problem(s)", len(problems))
+	},
+}
+
+// authStatusEntry is a token source checked by `auth status` along with the results of the checks.
+type authStatusEntry struct {
+	Name    string
+	Token   string
+	APIURL  string
+	Current bool
+
+	Team            do.Team
+	Email           string
+	TokenValid      bool
+	TokenInvalid    bool
+	CanListClusters bool
+	ClusterCount    int
+	Problems        []string
+}
+
+// authStatusEntries returns the token sources to check.
+// Problems that are not tied to a single source, such as a missing doctl config file, are returned separately.
+func authStatusEntries() ([]authStatusEntry, []string, error) {
+	var entries []authStatusEntry
+	var problems []string
+
+	if hasExplicitTokens() {
+		explicitTokens, err := getExplicitTokens()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, token := range explicitTokens {
+			url := token.APIURL
+			if url == "" {
+				url = apiURL
+			}
+			entries = append(entries, authStatusEntry{Name: token.Label, Token: token.Token, APIURL: url})
+		}
+		return markDuplicateTokens(entries), nil, nil
+	}
+
+	doctlConfig, err := loadDoctlConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if doctlConfig == nil {
+		problems = append(problems, fmt.Sprintf("doctl config file not found at %q; run \"doctl auth init\" to create it, or pass --access-token", getDoctlConfigPath()))
+	} else {
+		currentContext := doctlConfig.GetString("context")
+		if currentContext == "" {
+			currentContext = "default"
+		}
+
+		names := authContexts
+		if len(names) == 0 {
+			names = doctlAuthContextNames(doctlConfig)
+		}
+		if len(names) == 0 {
+			problems = append(problems, fmt.Sprintf("no auth contexts found in %q; run \"doctl auth init\" to add one", getDoctlConfigPath()))
+		}
+
+		var sources []authSource
+		for _, name := range names {
+			sources = append(sources, authSource{Token: doctlContextToken(doctlConfig, name), AuthContext: name})
+		}
+		sources, err = withDoctlAPIURLs(doctlConfig, sources)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, source := range sources {
+			entries = append(entries, authStatusEntry{
+				Name:    source.AuthContext,
+				Token:   source.Token,
+				APIURL:  source.APIURL,
+				Current: source.AuthContext == currentContext,
+			})
+		}
+	}
+
+	if token := os.Getenv("DIGITALOCEAN_ACCESS_TOKEN"); token != "" {
+		entries = append(entries, authStatusEntry{Name: "DIGITALOCEAN_ACCESS_TOKEN", Token: token, APIURL: apiURL})
+	}
+
+	return markDuplicateTokens(entries), problems, nil
+}
+
+// markDuplicateTokens records a problem on every entry whose token and API endpoint are also used by an earlier entry.
+func markDuplicateTokens(entries []authStatusEntry) []authStatusEntry {
+	firstUse := make(map[authSource]string)
+	for i, entry := range entries {
+		if entry.Token == "" {
+			continue
+		}
+		key := authSource{Token: entry.Token, APIURL: entry.APIURL}
+		if name, ok := firstUse[key]; ok {
+			entries[i].Problems = append(entries[i].Problems,
+				fmt.Sprintf("uses the same token as %q, so its clusters are only synced once; remove one of them or give it its own token", name))
+			continue
+		}
+		firstUse[key] = entry.Name
+	}
+	return entries
+}
+
+// checkAuthStatus validates the token of entry against the DigitalOcean API and records the results on it.
+func checkAuthStatus(ctx context.Context, entry *authStatusEntry) {
+	if entry.Token == "" {
+		entry.Problems = append(entry.Problems, fmt.Sprintf("token is empty; run \"doctl auth init --context %s\" to set it", entry.Name))
+		return
+	}
+
+	client, err := do.NewClient(entry.Token, entry.APIURL)
+	if err != nil {
+		entry.Problems = append(entry.Problems, fmt.Sprintf("creating DigitalOcean client: %v", err))
+		return
+	}
+
+	account, err := client.GetAccount(ctx)
+	if err != nil {
+		if do.IsUnauthorized(err) {
+			entry.TokenInvalid = true
+			entry.Problems = append(entry.Problems, "token is invalid or has expired; generate a new token and update it with \"doctl auth init\"")
+		} else {
+			entry.Problems = append(entry.Problems, fmt.Sprintf("could not verify token (%v); check your network connection and --api-url", err))
+		}
+		return
+	}
+	entry.TokenValid = true
+	entry.Team = account.Team
+	entry.Email = account.Email
+
+	clusters, err := client.ListClusters(ctx)
+	if err != nil {
+		entry.Problems = append(entry.Problems, fmt.Sprintf("token cannot list Kubernetes clusters (%v); make sure it has read access to Kubernetes", err))
+		return
+	}
+	entry.CanListClusters = true
+	entry.ClusterCount = len(clusters)
+}
+
+// printAuthStatus writes a table summarizing the checked entries.
+func printAuthStatus(out io.Writer, entries []authStatusEntry) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tCONTEXT\tTEAM\tEMAIL\tTOKEN\tCLUSTERS\tAPI")
+	for _, entry := range entries {
+		current := ""
+		if entry.Current {
+			current = "*"
+		}
+
+		tokenStatus := "unknown"
+		switch {
+		case entry.Token == "":
+			tokenStatus = "empty"
+		case entry.TokenValid:
+			tokenStatus = "valid"
+		case entry.TokenInvalid:
+			tokenStatus = "invalid"
+		}
+
+		clusters := "-"
+		if entry.CanListClusters {
+			clusters = fmt.Sprintf("%d", entry.ClusterCount)
+		} else if entry.TokenValid {
+			clusters = "error"
+		}
+
+		api := entry.APIURL
+		if api == "" {
+			api = "default"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", current, entry.Name, orDash(entry.Team.Name), orDash(entry.Email), tokenStatus, clusters, api)
+	}
+	w.Flush()
+}
+
+// orDash returns s, or "-" if s is empty, for use in tables.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	authCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(authCmd)
+}
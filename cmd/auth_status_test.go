@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockConfigForAuthStatus = `
+access-token: good-token
+auth-contexts:
+  default: "true"
+  team-a: good-token
+  team-b: expired-token
+  team-c: read-only-token
+  team-d: ""
+context: team-a
+`
+
+// newAuthStatusServer returns a server where good-token and read-only-token belong to the team My Team, which has
+// two clusters. read-only-token cannot list clusters, and expired-token is unknown.
+func newAuthStatusServer() *dotest.Server {
+	server := dotest.NewServer()
+	team := do.Team{UUID: "team-uuid", Name: "My Team"}
+	for _, token := range []string{"good-token", "read-only-token"} {
+		server.AddToken(token, do.Account{UUID: "account-uuid", Email: "user@example.com", Team: team})
+	}
+	server.AddCluster(do.Cluster{ID: "c1", Name: "one", Region: "nyc1", Team: team})
+	server.AddCluster(do.Cluster{ID: "c2", Name: "two", Region: "sfo3", Team: team})
+	server.FailToken("read-only-token", dotest.RouteListClusters, http.StatusForbidden, 0)
+	return server
+}
+
+func TestAuthStatusCommand(t *testing.T) {
+	server := newAuthStatusServer()
+	defer server.Close()
+
+	originalAPIURL := apiURL
+	apiURL = server.URL
+	defer func() { apiURL = originalAPIURL }()
+
+	t.Run("all doctl contexts", func(t *testing.T) {
+		setup(t)
+		configFile = createMockDoctlConfig(t, mockConfigForAuthStatus)
+
+		var out bytes.Buffer
+		authStatusCmd.SetOut(&out)
+		defer authStatusCmd.SetOut(nil)
+
+		err := authStatusCmd.RunE(authStatusCmd, []string{})
+		require.Error(t, err)
+
+		output := out.String()
+		assert.Regexp(t, `\*\s+team-a\s+My Team\s+user@example.com\s+valid\s+2`, output)
+		assert.Regexp(t, `team-b\s+-\s+-\s+invalid\s+-`, output)
+		assert.Regexp(t, `team-c\s+My Team\s+user@example.com\s+valid\s+error`, output)
+		assert.Regexp(t, `team-d\s+-\s+-\s+empty\s+-`, output)
+		assert.Contains(t, output, `team-a: uses the same token as "default"`)
+		assert.Contains(t, output, "team-b: token is invalid or has expired")
+		assert.Contains(t, output, "team-c: token cannot list Kubernetes clusters")
+		assert.Contains(t, output, `team-d: token is empty; run "doctl auth init --context team-d"`)
+	})
+
+	t.Run("access tokens", func(t *testing.T) {
+		setup(t)
+		accessTokens = []string{"good-token"}
+
+		var out bytes.Buffer
+		authStatusCmd.SetOut(&out)
+		defer authStatusCmd.SetOut(nil)
+
+		require.NoError(t, authStatusCmd.RunE(authStatusCmd, []string{}))
+		assert.Regexp(t, `--access-token #1\s+My Team\s+user@example.com\s+valid\s+2`, out.String())
+		assert.NotContains(t, out.String(), "Problems:")
+	})
+
+	t.Run("missing doctl config", func(t *testing.T) {
+		setup(t)
+		configFile = "/path/to/non/existent/config.yaml"
+
+		var out bytes.Buffer
+		authStatusCmd.SetOut(&out)
+		defer authStatusCmd.SetOut(nil)
+
+		require.Error(t, authStatusCmd.RunE(authStatusCmd, []string{}))
+		assert.Contains(t, out.String(), `doctl config file not found at "/path/to/non/existent/config.yaml"`)
+	})
+}
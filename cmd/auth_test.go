@@ -5,16 +5,29 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
 // setup and teardown for global flags
 func setup(t *testing.T) {
+	// Reset the external token sources after each test so they do not leak into other tests
+	t.Cleanup(func() {
+		accessTokenFiles = nil
+		accessTokenStdin = false
+		accessTokenCommands = nil
+	})
+
 	// Reset global flags before each test
 	accessTokens = nil
+	accessTokenFiles = nil
+	accessTokenStdin = false
+	accessTokenCommands = nil
 	authContexts = nil
 	allAuthContexts = false
 	configFile = ""
+	preferAuthContext = ""
+	nameCollision = ""
 	t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "")
 }
 
@@ -87,6 +100,38 @@ func TestValidateAuthSources(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "Access token and access token file are allowed together",
+			setup: func() {
+				accessTokens = []string{"token1"}
+				accessTokenFiles = []string{"/path/to/token"}
+			},
+			expectError: false,
+		},
+		{
+			name: "Access token file and auth context should error",
+			setup: func() {
+				accessTokenFiles = []string{"/path/to/token"}
+				authContexts = []string{"context1"}
+			},
+			expectError: true,
+		},
+		{
+			name: "Access token stdin and all auth contexts should error",
+			setup: func() {
+				accessTokenStdin = true
+				allAuthContexts = true
+			},
+			expectError: true,
+		},
+		{
+			name: "Access token command and auth context should error",
+			setup: func() {
+				accessTokenCommands = []string{"echo token"}
+				authContexts = []string{"context1"}
+			},
+			expectError: true,
+		},
 		{
 			name: "Access token, auth context and all auth contexts should error",
 			setup: func() {
@@ -192,7 +237,16 @@ const mockConfigDefaultOnly = `
 access-token: tokenDefaultOnly
 `
 
-func TestGetAllAccessTokens(t *testing.T) {
+// sourceTokens returns the tokens of sources.
+func sourceTokens(sources []authSource) []string {
+	var tokens []string
+	for _, source := range sources {
+		tokens = append(tokens, source.Token)
+	}
+	return tokens
+}
+
+func TestGetAuthSourcesPrecedence(t *testing.T) {
 	mockConfigPath := createMockDoctlConfig(t, mockConfigContent)
 	mockConfigWithDefaultPath := createMockDoctlConfig(t, mockConfigWithDefaultContext)
 	mockConfigDefaultOnlyPath := createMockDoctlConfig(t, mockConfigDefaultOnly)
@@ -344,21 +398,331 @@ func TestGetAllAccessTokens(t *testing.T) {
 			setup(t)
 			tt.setup()
 
-			got, err := getAllAccessTokens()
+			sources, err := getAuthSources()
 
 			if (err != nil) != tt.expectError {
-				t.Errorf("getAllAccessTokens() error = %v, wantErr %v", err, tt.expectError)
+				t.Errorf("getAuthSources() error = %v, wantErr %v", err, tt.expectError)
 				return
 			}
+			got := sourceTokens(sources)
 
 			// Sort slices for consistent comparison
 			sort.Strings(got)
 			sort.Strings(tt.want)
 
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getAllAccessTokens() = %v, want %v", got, tt.want)
+				t.Errorf("getAuthSources() tokens = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+
+func TestGetAuthSources(t *testing.T) {
+	mockConfigPath := createMockDoctlConfig(t, mockConfigContent)
+	mockConfigWithDefaultPath := createMockDoctlConfig(t, mockConfigWithDefaultContext)
+
+	tests := []struct {
+		name  string
+		setup func()
+		want  []authSource
+	}{
+		{
+			name: "access tokens have no auth context",
+			setup: func() {
+				accessTokens = []string{"flag-token"}
+			},
+			want: []authSource{{Token: "flag-token"}},
+		},
+		{
+			name: "auth contexts carry their names",
+			setup: func() {
+				authContexts = []string{"context2", "context3"}
+				configFile = mockConfigPath
+			},
+			want: []authSource{{Token: "token2", AuthContext: "context2"}, {Token: "token3", AuthContext: "context3"}},
+		},
+		{
+			name: "all auth contexts are sorted by name",
+			setup: func() {
+				allAuthContexts = true
+				configFile = mockConfigWithDefaultPath
+			},
+			want: []authSource{
+				{Token: "token1", AuthContext: "context1"},
+				{Token: "token2", AuthContext: "context2"},
+				{Token: "tokenDefault", AuthContext: "default"},
+			},
+		},
+		{
+			name: "current context carries its name",
+			setup: func() {
+				configFile = mockConfigPath
+			},
+			want: []authSource{{Token: "token1", AuthContext: "context1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			tt.setup()
+
+			got, err := getAuthSources()
+			if err != nil {
+				t.Fatalf("getAuthSources() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAuthSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetExplicitTokens(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("# personal token\nfile-token\n\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n# nothing here\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		setup       func()
+		want        []explicitToken
+		expectError bool
+	}{
+		{
+			name: "token file",
+			setup: func() {
+				accessTokenFiles = []string{tokenFile}
+			},
+			want: []explicitToken{{Label: "--access-token-file " + tokenFile, Token: "file-token"}},
+		},
+		{
+			name: "stdin",
+			setup: func() {
+				accessTokenStdin = true
+				tokenStdin = strings.NewReader("stdin-token-1\nstdin-token-2\n")
+			},
+			want: []explicitToken{
+				{Label: "--access-token-stdin", Token: "stdin-token-1"},
+				{Label: "--access-token-stdin", Token: "stdin-token-2"},
+			},
+		},
+		{
+			name: "command",
+			setup: func() {
+				accessTokenCommands = []string{"echo '  command-token  '"}
+			},
+			want: []explicitToken{{Label: "--access-token-command #1", Token: "command-token"}},
+		},
+		{
+			name: "all sources combined in order",
+			setup: func() {
+				accessTokens = []string{"flag-token"}
+				accessTokenFiles = []string{tokenFile}
+				accessTokenCommands = []string{"echo command-token"}
+			},
+			want: []explicitToken{
+				{Label: "--access-token #1", Token: "flag-token"},
+				{Label: "--access-token-file " + tokenFile, Token: "file-token"},
+				{Label: "--access-token-command #1", Token: "command-token"},
+			},
+		},
+		{
+			name: "missing token file",
+			setup: func() {
+				accessTokenFiles = []string{filepath.Join(t.TempDir(), "missing")}
+			},
+			expectError: true,
+		},
+		{
+			name: "token file without tokens",
+			setup: func() {
+				accessTokenFiles = []string{emptyFile}
+			},
+			expectError: true,
+		},
+		{
+			name: "failing command",
+			setup: func() {
+				accessTokenCommands = []string{"exit 3"}
+			},
+			expectError: true,
+		},
+		{
+			name: "command without output",
+			setup: func() {
+				accessTokenCommands = []string{"true"}
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			originalStdin := tokenStdin
+			defer func() { tokenStdin = originalStdin }()
+			tt.setup()
+
+			got, err := getExplicitTokens()
+			if (err != nil) != tt.expectError {
+				t.Fatalf("getExplicitTokens() error = %v, wantErr %v", err, tt.expectError)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getExplicitTokens() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestGetExplicitTokensCommandErrorHidesCommand(t *testing.T) {
+	setup(t)
+	accessTokenCommands = []string{"echo dop_v1_secret >/dev/null; exit 3"}
+
+	_, err := getExplicitTokens()
+	if err == nil {
+		t.Fatal("getExplicitTokens() should fail when the command fails")
+	}
+	if err.Error() != "access token command #1 failed: exit status 3" {
+		t.Errorf("getExplicitTokens() error = %q, want the command to be left out", err)
+	}
+}
+
+func TestGetAuthSourcesFromExternalSources(t *testing.T) {
+	setup(t)
+	configFile = createMockDoctlConfig(t, mockConfigContent)
+	t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "env-token")
+	accessTokenCommands = []string{"printf 'command-token\\ncommand-token\\n'"}
+
+	got, err := getAuthSources()
+	if err != nil {
+		t.Fatalf("getAuthSources() error = %v", err)
+	}
+	want := []authSource{{Token: "command-token"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getAuthSources() = %v, want %v", got, want)
+	}
+}
+
+func TestSplitTokenURL(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantToken string
+		wantURL   string
+	}{
+		{"dop_v1_abc", "dop_v1_abc", ""},
+		{"dop_v1_abc@https://api.staging.example.com", "dop_v1_abc", "https://api.staging.example.com"},
+		{"dop_v1_abc@http://localhost:8080", "dop_v1_abc", "http://localhost:8080"},
+		{"weird@token", "weird@token", ""},
+		{"@https://api.example.com", "@https://api.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			token, url := splitTokenURL(tt.value)
+			if token != tt.wantToken || url != tt.wantURL {
+				t.Errorf("splitTokenURL(%q) = (%q, %q), want (%q, %q)", tt.value, token, url, tt.wantToken, tt.wantURL)
+			}
+		})
+	}
+}
+
+const mockConfigWithAPIURL = `
+api-url: https://api.doctl.example.com
+auth-contexts:
+  prod: prod-token
+  staging: staging-token
+context: prod
+`
+
+const mockPluginConfigWithAPIURL = `
+auth-contexts:
+  staging:
+    api-url: https://api.staging.example.com
+`
+
+func TestGetAuthSourcesAPIURL(t *testing.T) {
+	mockConfigPath := createMockDoctlConfig(t, mockConfigWithAPIURL)
+
+	tests := []struct {
+		name  string
+		setup func()
+		want  []authSource
+	}{
+		{
+			name: "doctl api-url and per-context override",
+			setup: func() {
+				allAuthContexts = true
+				configFile = mockConfigPath
+			},
+			want: []authSource{
+				{Token: "prod-token", AuthContext: "prod", APIURL: "https://api.doctl.example.com"},
+				{Token: "staging-token", AuthContext: "staging", APIURL: "https://api.staging.example.com"},
+			},
+		},
+		{
+			name: "--api-url wins over config files",
+			setup: func() {
+				allAuthContexts = true
+				configFile = mockConfigPath
+				apiURL = "https://api.flag.example.com"
+			},
+			want: []authSource{
+				{Token: "prod-token", AuthContext: "prod", APIURL: "https://api.flag.example.com"},
+				{Token: "staging-token", AuthContext: "staging", APIURL: "https://api.flag.example.com"},
+			},
+		},
+		{
+			name: "token@url wins over --api-url",
+			setup: func() {
+				accessTokens = []string{"prod-token", "staging-token@https://api.staging.example.com", "staging-token@https://api.other.example.com"}
+				apiURL = "https://api.flag.example.com"
+			},
+			want: []authSource{
+				{Token: "prod-token", APIURL: "https://api.flag.example.com"},
+				{Token: "staging-token", APIURL: "https://api.staging.example.com"},
+				{Token: "staging-token", APIURL: "https://api.other.example.com"},
+			},
+		},
+		{
+			name: "environment token uses --api-url only",
+			setup: func() {
+				configFile = mockConfigPath
+				t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "env-token")
+			},
+			want: []authSource{{Token: "env-token"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			pluginConfig := filepath.Join(home, ".kube", "kubectl-doks", "config.yaml")
+			if err := os.MkdirAll(filepath.Dir(pluginConfig), 0755); err != nil {
+				t.Fatalf("Failed to create plugin config dir: %v", err)
+			}
+			if err := os.WriteFile(pluginConfig, []byte(mockPluginConfigWithAPIURL), 0600); err != nil {
+				t.Fatalf("Failed to write plugin config: %v", err)
+			}
+			originalAPIURL := apiURL
+			defer func() { apiURL = originalAPIURL }()
+			tt.setup()
+
+			got, err := getAuthSources()
+			if err != nil {
+				t.Fatalf("getAuthSources() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAuthSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
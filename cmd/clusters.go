@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// messages is where the helpers shared by the commands print their notices and warnings.
+// kubeconfig export points it at standard error, so that they do not end up in a kubeconfig written to standard output.
+var messages io.Writer = os.Stdout
+
+// listAllClusters lists the clusters visible to every configured access token.
+// Each token is resolved to its team once, and every cluster carries the team and auth context it was listed with.
+// A cluster visible to several tokens is only returned once, listed with the preferred auth context if it is one of them
+// and with the first token that can see it otherwise.
+// It also returns the client that can be used to fetch credentials for each cluster, keyed by cluster ID.
+func listAllClusters(ctx context.Context) ([]do.Cluster, map[string]*do.Client, error) {
+	sources, err := getAuthSources()
+	if err != nil {
+		return nil, nil, err
+	}
+	usedAuthContexts = nil
+	for _, source := range sources {
+		if source.AuthContext != "" {
+			usedAuthContexts = append(usedAuthContexts, source.AuthContext)
+		}
+	}
+
+	preferred, err := preferredAuthContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var allClusters []do.Cluster
+	clusterIDToClient := make(map[string]*do.Client)
+
+	for _, source := range sources {
+		client, err := do.NewClient(source.Token, source.APIURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating DigitalOcean client: %w", err)
+		}
+
+		// The team is informational only, so a token that cannot read its account is not fatal.
+		var team do.Team
+		if account, err := client.GetAccount(ctx); err == nil {
+			team = account.Team
+		} else if verbose {
+			fmt.Fprintf(messages, "Notice: Could not resolve team for %s: %v\n", doks.DescribeSource(do.Team{}, source.AuthContext), err)
+		}
+
+		clusters, err := client.ListClusters(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetching clusters for %s: %w", doks.DescribeSource(team, source.AuthContext), err)
+		}
+
+		if verbose {
+			fmt.Fprintf(messages, "Notice: Found %d cluster(s) for %s\n", len(clusters), doks.DescribeSource(team, source.AuthContext))
+		}
+
+		for _, cluster := range clusters {
+			cluster.Team = team
+			cluster.AuthContext = source.AuthContext
+
+			i := indexOfCluster(allClusters, cluster.ID)
+			if i < 0 {
+				allClusters = append(allClusters, cluster)
+				clusterIDToClient[cluster.ID] = client
+				continue
+			}
+
+			seen := allClusters[i]
+			replace := preferred != "" && cluster.AuthContext == preferred && seen.AuthContext != preferred
+			if verbose {
+				using := seen
+				if replace {
+					using = cluster
+				}
+				fmt.Fprintf(messages, "Notice: Cluster %q is visible from %s and %s; using %s\n", cluster.Name,
+					doks.DescribeSource(seen.Team, seen.AuthContext), doks.DescribeSource(cluster.Team, cluster.AuthContext),
+					doks.DescribeSource(using.Team, using.AuthContext))
+			}
+			if replace {
+				allClusters[i] = cluster
+				clusterIDToClient[cluster.ID] = client
+			}
+		}
+	}
+
+	return allClusters, clusterIDToClient, nil
+}
+
+// indexOfCluster returns the index of the cluster with the given ID in clusters, or -1 if there is none.
+func indexOfCluster(clusters []do.Cluster, id string) int {
+	for i, cluster := range clusters {
+		if cluster.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// preferredAuthContext returns the doctl auth context whose token is used for clusters visible to several tokens,
+// from --prefer-auth-context or the prefer-auth-context key of the kubectl-doks config file.
+func preferredAuthContext() (string, error) {
+	if preferAuthContext != "" {
+		return preferAuthContext, nil
+	}
+	pluginConfig, err := loadPluginConfig()
+	if err != nil {
+		return "", err
+	}
+	return pluginConfig.GetString("prefer-auth-context"), nil
+}
+
+// nameCollisionStrategy returns how clusters that would share a context name are handled,
+// from --name-collision or the name-collision key of the kubectl-doks config file.
+func nameCollisionStrategy() (string, error) {
+	strategy := nameCollision
+	if strategy == "" {
+		pluginConfig, err := loadPluginConfig()
+		if err != nil {
+			return "", err
+		}
+		strategy = pluginConfig.GetString("name-collision")
+	}
+
+	switch strategy {
+	case "":
+		return doks.NameCollisionError, nil
+	case doks.NameCollisionError, doks.NameCollisionTeam:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid name collision strategy %q: must be %q or %q", strategy, doks.NameCollisionError, doks.NameCollisionTeam)
+	}
+}
+
+// assignSelectedContextNames returns the kubeconfig context name to use for each selected cluster, keyed by cluster ID,
+// as doks.AssignSelectedContextNames does with the strategy of --name-collision: collisions among clusters only
+// matter if they involve a selected cluster.
+func assignSelectedContextNames(clusters, selected []do.Cluster) (map[string]string, error) {
+	strategy, err := nameCollisionStrategy()
+	if err != nil {
+		return nil, err
+	}
+	return doks.AssignSelectedContextNames(clusters, selected, strategy)
+}
+
+// statusAction returns what to do with cluster when syncing or saving all clusters, as doks.StatusAction does,
+// printing why clusters are skipped or deferred with --verbose, and warning about clusters in a degraded or error state.
+func statusAction(cluster do.Cluster) doks.Action {
+	action, notice, warning := doks.StatusAction(cluster)
+	if notice != "" && verbose {
+		fmt.Fprintf(messages, "Notice: %s\n", notice)
+	}
+	if warning != "" {
+		fmt.Fprintf(messages, "Warning: %s\n", warning)
+	}
+	return action
+}
+
+// checkClusterStatus checks that the credentials of a cluster the user asked for by name can be saved,
+// as doks.CheckClusterStatus does, printing its warning.
+func checkClusterStatus(cluster do.Cluster, waiting bool) error {
+	warning, err := doks.CheckClusterStatus(cluster, waiting)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		fmt.Fprintf(messages, "Warning: %s\n", warning)
+	}
+	return nil
+}
+
+// mergeClusterCredentials fetches the credentials for cluster and adds its entries to reconciler
+// under contextName, as returned by assignSelectedContextNames.
+// The resulting cluster entry is tagged with the DigitalOcean cluster ID and the expiry of the credentials.
+func mergeClusterCredentials(ctx context.Context, client *do.Client, cluster do.Cluster, contextName string, reconciler *kubeconfig.Reconciler) error {
+	credentials, err := client.GetCredentials(ctx, cluster.ID, expirySeconds)
+	if err != nil {
+		return fmt.Errorf("getting credentials for cluster %s: %w", cluster.Name, err)
+	}
+
+	reconciler.AddCluster(cluster, contextName, credentials)
+	return nil
+}
+
+// backupKubeconfig copies the kubeconfig at path to its kubectl-doks backup location if the file exists.
+func backupKubeconfig(path string) error {
+	backupPath := path + ".kubectl-doks.bak"
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	if verbose {
+		fmt.Fprintf(messages, "Notice: Creating backup of kubeconfig at %s\n", backupPath)
+	}
+	if err := kubeconfig.BackupKubeconfig(path, backupPath); err != nil {
+		return fmt.Errorf("backing up kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// writeKubeconfig serializes the config of reconciler and writes it to path.
+func writeKubeconfig(path string, reconciler *kubeconfig.Reconciler) error {
+	configBytes, err := reconciler.Bytes()
+	if err != nil {
+		return fmt.Errorf("serializing modified kubeconfig: %w", err)
+	}
+
+	if err := os.WriteFile(path, configBytes, 0600); err != nil {
+		return fmt.Errorf("writing updated kubeconfig: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+const mockConfigForClusters = `
+auth-contexts:
+  personal: personal-token
+  team: team-token
+context: personal
+`
+
+// newTestServer returns a server where the token test-token lists the given clusters.
+func newTestServer(clusters ...do.Cluster) *dotest.Server {
+	server := dotest.NewServer()
+	server.AddToken("test-token", do.Account{})
+	for _, cluster := range clusters {
+		server.AddCluster(cluster)
+	}
+	return server
+}
+
+// newClustersServer returns a server where the tokens personal-token and team-token belong to the teams personal
+// and team, and the credentials of each cluster have the token <cluster ID>-token.
+func newClustersServer(personal, team do.Team, clusters ...do.Cluster) *dotest.Server {
+	server := dotest.NewServer()
+	server.AddToken("personal-token", do.Account{UUID: "account-uuid", Email: "user@example.com", Team: personal})
+	server.AddToken("team-token", do.Account{UUID: "account-uuid", Email: "user@example.com", Team: team})
+	for _, cluster := range clusters {
+		server.AddCluster(cluster)
+	}
+	server.SetTokenFunc(func(cluster do.Cluster) string { return cluster.ID + "-token" })
+	return server
+}
+
+func TestListAllClustersDeduplicates(t *testing.T) {
+	// Both tokens list the clusters of the same team, which their accounts name differently.
+	team := do.Team{UUID: "team-uuid"}
+	server := newClustersServer(do.Team{UUID: team.UUID, Name: "Personal"}, do.Team{UUID: team.UUID, Name: "Acme"},
+		do.Cluster{ID: "shared-id", Name: "shared", Region: "nyc1", Team: team},
+		do.Cluster{ID: "personal-id", Name: "mine", Region: "nyc1", Team: team})
+	defer server.Close()
+
+	originalAPIURL := apiURL
+	apiURL = server.URL
+	defer func() { apiURL = originalAPIURL }()
+
+	tests := []struct {
+		name              string
+		preferAuthContext string
+		pluginConfig      string
+		expectedContext   string
+		expectedTeam      string
+	}{
+		{name: "first token wins", expectedContext: "personal", expectedTeam: "Personal"},
+		{name: "preferred auth context flag", preferAuthContext: "team", expectedContext: "team", expectedTeam: "Acme"},
+		{name: "preferred auth context in config file", pluginConfig: "prefer-auth-context: team\n", expectedContext: "team", expectedTeam: "Acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.pluginConfig != "" {
+				require.NoError(t, os.MkdirAll(filepath.Join(home, ".kube", "kubectl-doks"), 0700))
+				require.NoError(t, os.WriteFile(filepath.Join(home, ".kube", "kubectl-doks", "config.yaml"), []byte(tt.pluginConfig), 0600))
+			}
+			configFile = createMockDoctlConfig(t, mockConfigForClusters)
+			allAuthContexts = true
+			preferAuthContext = tt.preferAuthContext
+
+			clusters, clients, err := listAllClusters(context.Background())
+			require.NoError(t, err)
+			require.Len(t, clusters, 2)
+			assert.Len(t, clients, 2)
+
+			assert.Equal(t, "shared-id", clusters[0].ID)
+			assert.Equal(t, tt.expectedContext, clusters[0].AuthContext)
+			assert.Equal(t, tt.expectedTeam, clusters[0].Team.Name)
+			assert.Equal(t, "personal-id", clusters[1].ID)
+		})
+	}
+}
+
+func TestAssignSelectedContextNames(t *testing.T) {
+	api1 := do.Cluster{ID: "id-1", Name: "api", Region: "nyc1", Team: do.Team{UUID: "uuid-1", Name: "Acme Prod"}}
+	api2 := do.Cluster{ID: "id-2", Name: "api", Region: "nyc1", Team: do.Team{UUID: "uuid-2", Name: "Acme Staging"}}
+	other := do.Cluster{ID: "id-3", Name: "other", Region: "sfo3"}
+
+	t.Run("no collision", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+
+		names, err := assignSelectedContextNames([]do.Cluster{api1, other}, []do.Cluster{api1, other})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"id-1": "do-nyc1-api", "id-3": "do-sfo3-other"}, names)
+	})
+
+	t.Run("collision fails by default", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+
+		_, err := assignSelectedContextNames([]do.Cluster{api1, api2, other}, []do.Cluster{api1, api2, other})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `context name "do-nyc1-api" is used by multiple clusters`)
+		assert.Contains(t, err.Error(), `id-1 from team "Acme Prod"`)
+		assert.Contains(t, err.Error(), "--name-collision=team")
+	})
+
+	t.Run("collision between unselected clusters is ignored", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+
+		names, err := assignSelectedContextNames([]do.Cluster{api1, api2, other}, []do.Cluster{other})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"id-3": "do-sfo3-other"}, names)
+
+		_, err = assignSelectedContextNames([]do.Cluster{api1, api2, other}, []do.Cluster{api1})
+		assert.ErrorContains(t, err, `context name "do-nyc1-api" is used by multiple clusters`)
+	})
+
+	t.Run("collision with team names", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+		nameCollision = "team"
+
+		names, err := assignSelectedContextNames([]do.Cluster{api1, api2, other}, []do.Cluster{api1, api2, other})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"id-1": "do-nyc1-api@acme-prod",
+			"id-2": "do-nyc1-api@acme-staging",
+			"id-3": "do-sfo3-other",
+		}, names)
+	})
+
+	t.Run("collision between clusters without a team", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+		nameCollision = "team"
+
+		noTeam1 := do.Cluster{ID: "id-1", Name: "api", Region: "nyc1"}
+		noTeam2 := do.Cluster{ID: "id-2", Name: "api", Region: "nyc1"}
+		_, err := assignSelectedContextNames([]do.Cluster{noTeam1, noTeam2}, []do.Cluster{noTeam1, noTeam2})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be told apart by team")
+	})
+
+	t.Run("invalid strategy", func(t *testing.T) {
+		setup(t)
+		t.Setenv("HOME", t.TempDir())
+		nameCollision = "rename"
+
+		_, err := assignSelectedContextNames([]do.Cluster{api1}, []do.Cluster{api1})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid name collision strategy "rename"`)
+	})
+}
+
+func TestSyncCommandWithNameCollision(t *testing.T) {
+	personal, team := do.Team{UUID: "personal-uuid", Name: "Personal"}, do.Team{UUID: "team-uuid", Name: "Acme"}
+	server := newClustersServer(personal, team,
+		do.Cluster{ID: "personal-id", Name: "api", Region: "nyc1", Team: personal},
+		do.Cluster{ID: "team-id", Name: "api", Region: "nyc1", Team: team})
+	defer server.Close()
+
+	originalAPIURL, originalKubeConfigPath := apiURL, kubeConfigPath
+	defer func() { apiURL, kubeConfigPath = originalAPIURL, originalKubeConfigPath }()
+
+	t.Run("fails without a strategy", func(t *testing.T) {
+		setup(t)
+		tmpDir := t.TempDir()
+		t.Setenv("HOME", tmpDir)
+		apiURL, kubeConfigPath = server.URL, ""
+		configFile = createMockDoctlConfig(t, mockConfigForClusters)
+		allAuthContexts = true
+
+		err := syncCmd.RunE(syncCmd, []string{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `context name "do-nyc1-api" is used by multiple clusters`)
+		assert.NoFileExists(t, filepath.Join(tmpDir, ".kube", "config"))
+	})
+
+	t.Run("team-qualified names", func(t *testing.T) {
+		setup(t)
+		tmpDir := t.TempDir()
+		t.Setenv("HOME", tmpDir)
+		apiURL, kubeConfigPath = server.URL, ""
+		configFile = createMockDoctlConfig(t, mockConfigForClusters)
+		allAuthContexts = true
+		nameCollision = "team"
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, ".kube"), 0755))
+
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+		updatedBytes, err := os.ReadFile(filepath.Join(tmpDir, ".kube", "config"))
+		require.NoError(t, err)
+		config, err := k8sclientcmd.Load(updatedBytes)
+		require.NoError(t, err)
+
+		assert.NotContains(t, config.Contexts, "do-nyc1-api")
+		for contextName, id := range map[string]string{"do-nyc1-api@personal": "personal-id", "do-nyc1-api@acme": "team-id"} {
+			require.Contains(t, config.Contexts, contextName)
+			assert.Equal(t, contextName, config.Contexts[contextName].Cluster)
+			assert.Equal(t, contextName+"-admin", config.Contexts[contextName].AuthInfo)
+			assert.Equal(t, id+"-token", config.AuthInfos[contextName+"-admin"].Token)
+
+			clusterID, ok := kubeconfig.GetClusterID(config.Clusters[contextName])
+			require.True(t, ok)
+			assert.Equal(t, id, clusterID)
+		}
+	})
+}
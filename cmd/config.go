@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/hooks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/state"
+	"github.com/spf13/viper"
+)
+
+// pluginConfigPath returns the path of the kubectl-doks config file, ~/.kube/kubectl-doks/config.yaml.
+func pluginConfigPath() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// loadPluginConfig loads the kubectl-doks config file.
+// It returns an empty config if the file does not exist.
+func loadPluginConfig() (*viper.Viper, error) {
+	v := viper.New()
+	cfgFile, err := pluginConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	v.SetConfigFile(cfgFile)
+
+	if err := v.ReadInConfig(); err != nil {
+		if os.IsNotExist(err) {
+			return v, nil // Not an error if config doesn't exist.
+		}
+		return nil, fmt.Errorf("failed to read kubectl-doks config file at %q: %w", cfgFile, err)
+	}
+	return v, nil
+}
+
+// authContextAPIURL returns the API URL configured for a doctl auth context in the kubectl-doks config file, if any.
+func authContextAPIURL(pluginConfig *viper.Viper, context string) string {
+	return pluginConfig.GetString(fmt.Sprintf("auth-contexts.%s.api-url", context))
+}
+
+// hooksConfig returns the hooks configured under hooks in the kubectl-doks config file.
+func hooksConfig(pluginConfig *viper.Viper) (hooks.Config, error) {
+	config := hooks.Config{
+		Commands:      make(map[hooks.Event][]string),
+		Timeout:       pluginConfig.GetDuration("hooks.timeout"),
+		FailurePolicy: pluginConfig.GetString("hooks.failure-policy"),
+	}
+	for key := range pluginConfig.GetStringMap("hooks") {
+		if key == "timeout" || key == "failure-policy" {
+			continue
+		}
+		// A single command may be given as a string, which GetStringSlice would split on spaces.
+		switch commands := pluginConfig.Get("hooks." + key).(type) {
+		case string:
+			config.Commands[hooks.Event(key)] = []string{commands}
+		case []any:
+			for _, command := range commands {
+				config.Commands[hooks.Event(key)] = append(config.Commands[hooks.Event(key)], fmt.Sprint(command))
+			}
+		default:
+			return hooks.Config{}, fmt.Errorf("invalid hooks in kubectl-doks config file: %s must be a command or a list of commands", key)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return hooks.Config{}, fmt.Errorf("invalid hooks in kubectl-doks config file: %w", err)
+	}
+	return config, nil
+}
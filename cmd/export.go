@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportAll    bool
+	exportOutput string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [<cluster>...]",
+	Short: "Write a standalone kubeconfig for DOKS clusters",
+	Long: `Fetches the credentials of the given clusters, or of all running clusters with --all, and writes them as a
+self-contained kubeconfig to standard output or to the file given with -o. Clusters are given like for save.
+The kubeconfig has the same contexts, DigitalOcean extensions and --expiry-seconds as sync and save would write,
+but your own kubeconfig is neither read nor written, which makes it suitable for CI jobs or handing to a teammate.
+
+The current context is set to the last cluster given, or to the only cluster exported by --all, unless
+--set-current-context=false. The kubeconfig only ever holds the contexts, clusters and users of the exported clusters,
+so --minify has no effect.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportAll == (len(args) > 0) {
+			return errors.New("specify the clusters to export, or --all")
+		}
+
+		// Notices and warnings must not end up in the kubeconfig.
+		messages = cmd.ErrOrStderr()
+		defer func() { messages = os.Stdout }()
+
+		ctx := context.Background()
+		allClusters, clusterToClient, err := listAllClusters(ctx)
+		if err != nil {
+			return err
+		}
+
+		selectedClusters, err := selectExportClusters(allClusters, args)
+		if err != nil {
+			return err
+		}
+
+		contextNames, err := assignSelectedContextNames(allClusters, selectedClusters)
+		if err != nil {
+			return err
+		}
+
+		reconciler, err := kubeconfig.NewReconciler(nil)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range selectedClusters {
+			if err := mergeClusterCredentials(ctx, clusterToClient[cluster.ID], cluster, contextNames[cluster.ID], reconciler); err != nil {
+				return err
+			}
+		}
+
+		config := reconciler.Config()
+		if setCurrentContext && (len(args) > 0 || len(selectedClusters) == 1) {
+			config.CurrentContext = contextNames[selectedClusters[len(selectedClusters)-1].ID]
+		}
+
+		configBytes, err := reconciler.Bytes()
+		if err != nil {
+			return fmt.Errorf("serializing exported kubeconfig: %w", err)
+		}
+
+		if exportOutput == "" {
+			_, err := cmd.OutOrStdout().Write(configBytes)
+			return err
+		}
+		if err := os.WriteFile(exportOutput, configBytes, 0600); err != nil {
+			return fmt.Errorf("writing exported kubeconfig: %w", err)
+		}
+		if verbose {
+			fmt.Fprintf(messages, "Notice: Exported %d DOKS cluster(s) to %s\n", len(selectedClusters), exportOutput)
+		}
+		return nil
+	},
+}
+
+// selectExportClusters returns the clusters named by args, or all the running clusters if args is empty.
+// Every argument is resolved before any credentials are fetched, so that a typo fails early.
+func selectExportClusters(allClusters []do.Cluster, args []string) ([]do.Cluster, error) {
+	var selectedClusters []do.Cluster
+	if len(args) == 0 {
+		for _, cluster := range allClusters {
+			if statusAction(cluster) == doks.SaveCluster {
+				selectedClusters = append(selectedClusters, cluster)
+			}
+		}
+		if len(selectedClusters) == 0 {
+			return nil, errors.New("no running DOKS clusters found")
+		}
+		return selectedClusters, nil
+	}
+
+	for _, arg := range args {
+		cluster, err := doks.ResolveCluster(allClusters, arg)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkClusterStatus(cluster, false); err != nil {
+			return nil, err
+		}
+		if indexOfCluster(selectedClusters, cluster.ID) < 0 {
+			selectedClusters = append(selectedClusters, cluster)
+		}
+	}
+	return selectedClusters, nil
+}
+
+func init() {
+	exportCmd.Flags().BoolVar(&exportAll, "all", false, "Export all running clusters")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the kubeconfig to this file instead of standard output")
+	// Exports are always minimal, so --minify changes nothing. It is accepted for those used to kubectl config view --minify.
+	exportCmd.Flags().Bool("minify", false, "Has no effect: exports always hold only the contexts, clusters and users of the exported clusters")
+	kubeconfigCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+func TestExportCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha", "beta"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	userKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(userKubeConfigPath), 0755))
+	userKubeconfig := []byte("apiVersion: v1\nkind: Config\ncurrent-context: mine\n")
+	require.NoError(t, os.WriteFile(userKubeConfigPath, userKubeconfig, 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSetCurrentContext := apiURL, accessTokens, kubeConfigPath, setCurrentContext
+	originalExportAll, originalExportOutput := exportAll, exportOutput
+	apiURL, accessTokens, kubeConfigPath, setCurrentContext = server.URL, []string{"test-token"}, "", true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, setCurrentContext = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSetCurrentContext
+		exportAll, exportOutput = originalExportAll, originalExportOutput
+		exportCmd.SetOut(nil)
+	}()
+
+	t.Run("requires clusters or --all", func(t *testing.T) {
+		exportAll, exportOutput = false, ""
+		assert.Error(t, exportCmd.RunE(exportCmd, []string{}))
+
+		exportAll = true
+		assert.Error(t, exportCmd.RunE(exportCmd, []string{"alpha"}))
+	})
+
+	t.Run("writes the named clusters to standard output", func(t *testing.T) {
+		exportAll, exportOutput = false, ""
+		var out bytes.Buffer
+		exportCmd.SetOut(&out)
+
+		require.NoError(t, exportCmd.RunE(exportCmd, []string{"beta", "alpha"}))
+
+		exported, err := k8sclientcmd.Load(out.Bytes())
+		require.NoError(t, err)
+		assert.Len(t, exported.Contexts, 2)
+		assert.Equal(t, "do-nyc1-alpha", exported.CurrentContext, "The last cluster given should be the current context")
+		assert.Equal(t, "new-beta-token", exported.AuthInfos["do-nyc1-beta-admin"].Token)
+
+		extension, ok := kubeconfig.GetExtension(exported.Clusters["do-nyc1-beta"])
+		require.True(t, ok)
+		assert.Equal(t, "beta-id", extension.ClusterID)
+		_, ok = kubeconfig.CredentialsExpiry(exported, "do-nyc1-beta")
+		assert.True(t, ok, "The expiry of the credentials should be recorded")
+	})
+
+	t.Run("writes only the exported clusters", func(t *testing.T) {
+		exportAll, exportOutput, setCurrentContext = false, "", false
+		defer func() { setCurrentContext = true }()
+		var out bytes.Buffer
+		exportCmd.SetOut(&out)
+
+		require.NoError(t, exportCmd.RunE(exportCmd, []string{"alpha"}))
+
+		exported, err := k8sclientcmd.Load(out.Bytes())
+		require.NoError(t, err)
+		assert.Empty(t, exported.CurrentContext)
+		assert.Equal(t, []string{"do-nyc1-alpha"}, slices.Collect(maps.Keys(exported.Contexts)))
+		assert.Len(t, exported.Clusters, 1)
+		assert.Len(t, exported.AuthInfos, 1)
+	})
+
+	t.Run("writes all clusters to a file with --all", func(t *testing.T) {
+		exportAll, exportOutput = true, filepath.Join(tmpDir, "exported")
+
+		require.NoError(t, exportCmd.RunE(exportCmd, []string{}))
+
+		info, err := os.Stat(exportOutput)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		exported, err := k8sclientcmd.LoadFromFile(exportOutput)
+		require.NoError(t, err)
+		assert.Len(t, exported.Contexts, 2)
+		assert.Empty(t, exported.CurrentContext, "No current context should be picked among several clusters")
+	})
+
+	userBytes, err := os.ReadFile(userKubeConfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, userKubeconfig, userBytes, "The user's kubeconfig should be left untouched")
+	_, err = os.Stat(userKubeConfigPath + ".kubectl-doks.bak")
+	assert.True(t, os.IsNotExist(err), "No backup should be made")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/spf13/cobra"
+)
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove DOKS contexts whose credentials have expired",
+	Long: `Removes the DOKS contexts whose recorded credentials expiry has passed, such as short-lived credentials
+saved with --expiry-seconds, along with their clusters and users unless other contexts still use them.
+Contexts without a recorded expiry are kept. The DigitalOcean API is not contacted, so no access token is needed.`,
+	// gc only reads the kubeconfig.
+	Annotations: map[string]string{credentialsAnnotation: credentialsOptional},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var existingConfigBytes []byte
+		var err error
+		kubeConfigPath, existingConfigBytes, err = kubeconfig.GetKubeconfig(kubeConfigPath)
+		if err != nil {
+			return err
+		}
+
+		reconciler, err := kubeconfig.NewReconciler(existingConfigBytes)
+		if err != nil {
+			return err
+		}
+
+		expiries := make(map[string]time.Time)
+		now := time.Now()
+		for _, contextName := range kubeconfig.ExpiringContexts(reconciler.Config(), now) {
+			expiries[contextName], _ = kubeconfig.CredentialsExpiry(reconciler.Config(), contextName)
+		}
+
+		expired := reconciler.PruneExpired(now)
+		if len(expired) == 0 {
+			fmt.Println("No expired DOKS credentials found.")
+			return nil
+		}
+
+		if err := backupKubeconfig(kubeConfigPath); err != nil {
+			return err
+		}
+		if err := writeKubeconfig(kubeConfigPath, reconciler); err != nil {
+			return err
+		}
+		recordChange(cmd, kubeConfigPath, existingConfigBytes, kubeConfigPath+".kubectl-doks.bak", nil, nil, expired)
+
+		for _, contextName := range expired {
+			fmt.Printf("Removed context %q, whose credentials expired at %s.\n", contextName, expiries[contextName].Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+func init() {
+	kubeconfigCmd.AddCommand(gcCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+func TestGCCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, kubeconfigWithExpiries(t, map[string]time.Time{
+		"incident": time.Now().Add(-time.Minute),
+		"fresh":    time.Now().Add(time.Hour),
+		"forever":  {},
+	}), 0600))
+
+	originalKubeConfigPath := kubeConfigPath
+	kubeConfigPath = ""
+	defer func() { kubeConfigPath = originalKubeConfigPath }()
+
+	require.NoError(t, gcCmd.RunE(gcCmd, []string{}))
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	assert.NotContains(t, updated.Contexts, "do-nyc1-incident")
+	assert.NotContains(t, updated.Clusters, "do-nyc1-incident")
+	assert.NotContains(t, updated.AuthInfos, "do-nyc1-incident-admin")
+	assert.Contains(t, updated.Contexts, "do-nyc1-fresh")
+	assert.Contains(t, updated.Contexts, "do-nyc1-forever", "Contexts without a recorded expiry should be kept")
+
+	_, err = os.Stat(finalKubeConfigPath + ".kubectl-doks.bak")
+	assert.NoError(t, err, "A backup should be created before removing contexts")
+
+	// Running it again finds nothing to remove and leaves the file alone.
+	require.NoError(t, gcCmd.RunE(gcCmd, []string{}))
+	again, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(updatedBytes), string(again))
+}
+
+func TestSyncCommandPruneExpired(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"incident", "fresh"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, kubeconfigWithExpiries(t, map[string]time.Time{
+		"incident": time.Now().Add(-time.Minute),
+		"fresh":    time.Now().Add(time.Hour),
+	}), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncPruneExpired := apiURL, accessTokens, kubeConfigPath, syncPruneExpired
+	apiURL, accessTokens, kubeConfigPath, syncPruneExpired = server.URL, []string{"test-token"}, "", true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, syncPruneExpired = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncPruneExpired
+	}()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.Empty(t, fetched, "Expired credentials should be removed, not renewed or added back")
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+	assert.NotContains(t, updated.Contexts, "do-nyc1-incident")
+	assert.NotContains(t, updated.AuthInfos, "do-nyc1-incident-admin")
+	assert.Contains(t, updated.Contexts, "do-nyc1-fresh")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyLimit  int
+	historyOutput string
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the audit log of kubeconfig changes",
+	Long: `Shows the changes kubectl-doks made to kubeconfig files, as recorded in ~/.kube/kubectl-doks/audit.log by
+sync, save, refresh, adopt, gc, use and undo: when, by whom and on which host, with which command, and which
+contexts were added (+), updated (~) and removed (-). The OPERATION column is the ID that "kubectl doks undo"
+takes. The most recent --limit changes are shown, oldest first.
+With -o json, the records are printed as JSON lines, including the command line, auth contexts, backup path
+and the hashes of the kubeconfig before and after the change.`,
+	// history only reads the audit log.
+	Annotations: map[string]string{credentialsAnnotation: credentialsOptional},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if historyOutput != "table" && historyOutput != "json" {
+			return fmt.Errorf("invalid output format %q: must be table or json", historyOutput)
+		}
+
+		path, err := audit.DefaultPath()
+		if err != nil {
+			return err
+		}
+		records, err := audit.Read(path)
+		if err != nil {
+			return err
+		}
+		if historyLimit > 0 && len(records) > historyLimit {
+			records = records[len(records)-historyLimit:]
+		}
+
+		out := cmd.OutOrStdout()
+		if historyOutput == "json" {
+			encoder := json.NewEncoder(out)
+			for _, record := range records {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if len(records) == 0 {
+			fmt.Fprintln(out, "No kubeconfig changes recorded.")
+			return nil
+		}
+		printHistory(out, records)
+		return nil
+	},
+}
+
+// printHistory prints records as a table.
+func printHistory(out io.Writer, records []audit.Record) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tTIME\tUSER\tCOMMAND\tKUBECONFIG\tCHANGES")
+	for _, record := range records {
+		var changes []string
+		for _, contextName := range record.Added {
+			changes = append(changes, "+"+contextName)
+		}
+		for _, contextName := range record.Updated {
+			changes = append(changes, "~"+contextName)
+		}
+		for _, contextName := range record.Removed {
+			changes = append(changes, "-"+contextName)
+		}
+		if len(changes) == 0 {
+			changes = []string{"(none)"}
+		}
+		operation := record.Operation
+		if operation == "" {
+			operation = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s@%s\t%s\t%s\t%s\n", operation, record.Time.Local().Format(time.DateTime), record.User, record.Host,
+			record.Command, record.Kubeconfig, strings.Join(changes, " "))
+	}
+	w.Flush()
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Show at most this many of the most recent changes; 0 shows all")
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "table", "Output format: table or json")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogAndHistoryCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha"}, &fetched)
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	kubeconfigPath := filepath.Join(home, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(kubeconfigPath), 0755))
+	original := kubeconfigWithExpiries(t, map[string]time.Time{"gone": time.Now().Add(time.Hour)})
+	require.NoError(t, os.WriteFile(kubeconfigPath, original, 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	originalHistoryLimit, originalHistoryOutput := historyLimit, historyOutput
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+		historyLimit, historyOutput = originalHistoryLimit, originalHistoryOutput
+		historyCmd.SetOut(nil)
+	}()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	// Nothing changes, so nothing is recorded.
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+	logPath := filepath.Join(home, ".kube", "kubectl-doks", audit.FileName)
+	records, err := audit.Read(logPath)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, "kubeconfig sync", record.Command)
+	assert.Equal(t, kubeconfigPath, record.Kubeconfig)
+	assert.Equal(t, []string{"do-nyc1-alpha"}, record.Added)
+	assert.Equal(t, []string{"do-nyc1-gone"}, record.Removed)
+	assert.Equal(t, kubeconfigPath+".kubectl-doks.bak", record.Backup)
+	assert.Equal(t, audit.Hash(original), record.BeforeHash)
+	current, err := os.ReadFile(kubeconfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, audit.Hash(current), record.AfterHash)
+
+	t.Run("renders the log as a table", func(t *testing.T) {
+		historyLimit, historyOutput = 20, "table"
+		var out bytes.Buffer
+		historyCmd.SetOut(&out)
+		require.NoError(t, historyCmd.RunE(historyCmd, []string{}))
+		assert.Contains(t, out.String(), "CHANGES")
+		assert.Contains(t, out.String(), "kubeconfig sync")
+		assert.Contains(t, out.String(), "+do-nyc1-alpha -do-nyc1-gone")
+	})
+
+	t.Run("prints JSON lines with -o json", func(t *testing.T) {
+		historyLimit, historyOutput = 20, "json"
+		var out bytes.Buffer
+		historyCmd.SetOut(&out)
+		require.NoError(t, historyCmd.RunE(historyCmd, []string{}))
+		var printed audit.Record
+		require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+		assert.Equal(t, record.AfterHash, printed.AfterHash)
+	})
+
+	t.Run("rejects unknown output formats", func(t *testing.T) {
+		historyLimit, historyOutput = 20, "yaml"
+		assert.Error(t, historyCmd.RunE(historyCmd, []string{}))
+	})
+}
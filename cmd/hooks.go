@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/hooks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var noHooks bool
+
+// loadHooks returns a runner for the hooks configured in the kubectl-doks config file, or nil with --no-hooks.
+func loadHooks() (*hooks.Runner, error) {
+	if noHooks {
+		return nil, nil
+	}
+	pluginConfig, err := loadPluginConfig()
+	if err != nil {
+		return nil, err
+	}
+	config, err := hooksConfig(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &hooks.Runner{Config: config}, nil
+}
+
+// changedContexts returns the contexts added, updated and removed according to result.
+func changedContexts(result doks.Result) (added, updated, removed []string) {
+	added = result.Added
+	removed = slices.Concat(result.Removed, result.Expired)
+
+	candidates := slices.Concat(result.Renewed, result.Updated)
+	for _, a := range result.Adopted {
+		candidates = append(candidates, a.NewName)
+	}
+	for _, contextName := range candidates {
+		if !slices.Contains(added, contextName) && !slices.Contains(updated, contextName) {
+			updated = append(updated, contextName)
+		}
+	}
+	return added, updated, removed
+}
+
+// hookCluster describes the cluster of contextName in config for a hook, completed with the details of the
+// listed cluster with the same ID, if any.
+func hookCluster(config *k8sclientcmdapi.Config, contextName string, clusters []do.Cluster) *hooks.Cluster {
+	cluster := &hooks.Cluster{}
+	if context, ok := config.Contexts[contextName]; ok {
+		if entry, ok := config.Clusters[context.Cluster]; ok {
+			extension, _ := kubeconfig.GetExtension(entry)
+			cluster.ID, cluster.Region, cluster.Status = extension.ClusterID, extension.Region, extension.Status
+			cluster.Version, cluster.Team = extension.KubernetesVersion, extension.TeamName
+		}
+	}
+
+	for _, c := range clusters {
+		if c.ID != "" && c.ID == cluster.ID {
+			cluster.Name, cluster.Region, cluster.Status, cluster.Version = c.Name, c.Region, c.Status, c.Version
+			if c.Team.Name != "" {
+				cluster.Team = c.Team.Name
+			}
+		}
+	}
+	return cluster
+}
+
+// changeHooks returns a doks.Options.BeforeWrite function running the on-remove, on-add and on-update hooks of
+// runner for each context changed in the kubeconfig of store, before it is written.
+// The hooks get a copy of the kubeconfig as it is about to be written as KUBECONFIG, which is read back once they
+// have run, so that the changes they make, such as setting the namespace of a new context, are written too.
+func changeHooks(runner *hooks.Runner, store *kubeconfigStore) func(ctx context.Context, result *doks.Result) error {
+	return func(ctx context.Context, result *doks.Result) error {
+		added, updated, removed := changedContexts(*result)
+		runs := runner.Configured(hooks.OnRemove) && len(removed) > 0 ||
+			runner.Configured(hooks.OnAdd) && len(added) > 0 ||
+			runner.Configured(hooks.OnUpdate) && len(updated) > 0
+		if !runs {
+			return nil
+		}
+
+		// Removed contexts are only described by the kubeconfig as it was before the sync.
+		original, err := kubeconfig.NewReconciler(store.original)
+		if err != nil {
+			return err
+		}
+
+		pending, err := writePendingKubeconfig(result.Config)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(pending)
+
+		for _, change := range []struct {
+			event    hooks.Event
+			contexts []string
+			config   *k8sclientcmdapi.Config
+		}{
+			{hooks.OnRemove, removed, original.Config()},
+			{hooks.OnAdd, added, result.Config},
+			{hooks.OnUpdate, updated, result.Config},
+		} {
+			if !runner.Configured(change.event) {
+				continue
+			}
+			for _, contextName := range change.contexts {
+				payload := hooks.Payload{
+					Event:      change.event,
+					Kubeconfig: pending,
+					Context:    contextName,
+					Cluster:    hookCluster(change.config, contextName, result.Clusters),
+				}
+				if err := hookFailed(runner, runner.Run(ctx, payload)); err != nil {
+					return err
+				}
+			}
+		}
+
+		data, err := os.ReadFile(pending)
+		if err != nil {
+			return fmt.Errorf("reading the kubeconfig changed by hooks: %w", err)
+		}
+		changed, err := k8sclientcmd.Load(data)
+		if err != nil {
+			return fmt.Errorf("reading the kubeconfig changed by hooks: %w", err)
+		}
+		// result.Config is the config the syncer writes.
+		*result.Config = *changed
+		return nil
+	}
+}
+
+// writePendingKubeconfig writes config to a new temporary file, readable by the current user only,
+// and returns its path.
+func writePendingKubeconfig(config *k8sclientcmdapi.Config) (string, error) {
+	file, err := os.CreateTemp("", "kubectl-doks-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("creating kubeconfig for hooks: %w", err)
+	}
+	file.Close()
+	if err := k8sclientcmd.WriteToFile(*config, file.Name()); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing kubeconfig for hooks: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// runPostSyncHooks runs the post-sync hooks of runner once the kubeconfig of store has been written.
+func runPostSyncHooks(ctx context.Context, runner *hooks.Runner, store *kubeconfigStore, result doks.Result) error {
+	if !result.Written {
+		return nil
+	}
+	added, updated, removed := changedContexts(result)
+	payload := hooks.Payload{
+		Event:      hooks.PostSync,
+		Kubeconfig: store.Path,
+		Added:      added,
+		Updated:    updated,
+		Removed:    removed,
+	}
+	return hookFailed(runner, runner.Run(ctx, payload))
+}
+
+// warnSplitHooks warns that the hooks of runner are not run with the split layout.
+func warnSplitHooks(runner *hooks.Runner) {
+	for _, event := range hooks.Events {
+		if runner.Configured(event) {
+			fmt.Fprintf(messages, "Warning: Hooks are not run with --layout %s.\n", layoutSplit)
+			return
+		}
+	}
+}
+
+// hookFailed returns err, the error of failed hooks, with the abort failure policy.
+// With the warn policy, it prints err as warnings instead and returns nil.
+func hookFailed(runner *hooks.Runner, err error) error {
+	if err == nil {
+		return nil
+	}
+	if runner.Config.Abort() {
+		return err
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(messages, "Warning: %s\n", line)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/hooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+func TestSyncCommandHooks(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha"}, &fetched)
+	defer server.Close()
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalNoHooks := apiURL, accessTokens, kubeConfigPath, noHooks
+	apiURL, accessTokens, kubeConfigPath, noHooks = server.URL, []string{"test-token"}, "", false
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, noHooks = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalNoHooks
+	}()
+
+	// setup writes a kubeconfig holding the context of the deleted cluster gone, and the given config file,
+	// in which {log} is replaced with the path of a file for the hooks to write to.
+	setup := func(t *testing.T, pluginConfig string) (kubeconfigPath, logPath string) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		kubeconfigPath = filepath.Join(home, ".kube", "config")
+		require.NoError(t, os.MkdirAll(filepath.Dir(kubeconfigPath), 0755))
+		require.NoError(t, os.WriteFile(kubeconfigPath, kubeconfigWithExpiries(t, map[string]time.Time{"gone": time.Now().Add(time.Hour)}), 0600))
+
+		logPath = filepath.Join(home, "hooks.log")
+		pluginConfigPath := filepath.Join(home, ".kube", "kubectl-doks", "config.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(pluginConfigPath), 0700))
+		require.NoError(t, os.WriteFile(pluginConfigPath, []byte(strings.ReplaceAll(pluginConfig, "{log}", logPath)), 0600))
+		return kubeconfigPath, logPath
+	}
+
+	t.Run("runs the hooks of the changed contexts", func(t *testing.T) {
+		kubeconfigPath, logPath := setup(t, `hooks:
+  on-remove: echo "removed $KUBECTL_DOKS_CONTEXT $KUBECTL_DOKS_CLUSTER_ID" >> {log}
+  on-add:
+    - echo "added $KUBECTL_DOKS_CONTEXT $KUBECTL_DOKS_CLUSTER_NAME $KUBECTL_DOKS_CLUSTER_ID" >> {log}
+  post-sync: cat > {log}.json
+`)
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+		log, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		assert.Equal(t, "removed do-nyc1-gone gone-id\nadded do-nyc1-alpha alpha alpha-id\n", string(log))
+
+		data, err := os.ReadFile(logPath + ".json")
+		require.NoError(t, err)
+		var payload hooks.Payload
+		require.NoError(t, json.Unmarshal(data, &payload))
+		assert.Equal(t, hooks.Payload{
+			Event:      hooks.PostSync,
+			Kubeconfig: kubeconfigPath,
+			Added:      []string{"do-nyc1-alpha"},
+			Removed:    []string{"do-nyc1-gone"},
+		}, payload)
+	})
+
+	t.Run("keeps the changes hooks make to the kubeconfig", func(t *testing.T) {
+		kubeconfigPath, _ := setup(t, `hooks:
+  failure-policy: abort
+  on-add: kubectl config set-context "$KUBECTL_DOKS_CONTEXT" --namespace=x
+`)
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			fakeKubectl(t)
+		}
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+		config, err := k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		require.Contains(t, config.Contexts, "do-nyc1-alpha")
+		assert.Equal(t, "x", config.Contexts["do-nyc1-alpha"].Namespace)
+		assert.Equal(t, "new-alpha-token", config.AuthInfos["do-nyc1-alpha-admin"].Token)
+		assert.NotContains(t, config.Contexts, "do-nyc1-gone")
+	})
+
+	t.Run("warns about failed hooks by default", func(t *testing.T) {
+		kubeconfigPath, _ := setup(t, "hooks:\n  on-add: exit 1\n")
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+		config, err := k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Contains(t, config.Contexts, "do-nyc1-alpha")
+	})
+
+	t.Run("aborts before writing with the abort policy", func(t *testing.T) {
+		kubeconfigPath, _ := setup(t, "hooks:\n  failure-policy: abort\n  on-add: exit 1\n")
+		before, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+
+		err = syncCmd.RunE(syncCmd, []string{})
+		assert.ErrorContains(t, err, `on-add hook "exit 1" for context do-nyc1-alpha failed`)
+
+		after, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "The kubeconfig should not be written")
+	})
+
+	t.Run("skips hooks with --no-hooks", func(t *testing.T) {
+		_, logPath := setup(t, "hooks:\n  failure-policy: abort\n  on-add: echo added >> {log}\n")
+		noHooks = true
+		defer func() { noHooks = false }()
+
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+		_, err := os.Stat(logPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("rejects an invalid failure policy", func(t *testing.T) {
+		setup(t, "hooks:\n  failure-policy: ignore\n")
+		assert.ErrorContains(t, syncCmd.RunE(syncCmd, []string{}), "invalid hook failure policy")
+	})
+}
+
+// fakeKubectl puts a kubectl supporting only `kubectl config set-context <context> --namespace=<namespace>` first
+// in PATH, for tests of hooks running kubectl on hosts where it is not installed. It runs TestHelperKubectl.
+func fakeKubectl(t *testing.T) {
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nKUBECTL_DOKS_HELPER_KUBECTL=1 exec %q -test.run='^TestHelperKubectl$' -- \"$@\"\n", os.Args[0])
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// TestHelperKubectl is the kubectl of fakeKubectl. It does nothing when run as a test.
+func TestHelperKubectl(t *testing.T) {
+	if os.Getenv("KUBECTL_DOKS_HELPER_KUBECTL") != "1" {
+		return
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if len(args) != 4 || args[0] != "config" || args[1] != "set-context" || !strings.HasPrefix(args[3], "--namespace=") {
+		fmt.Fprintf(os.Stderr, "unsupported kubectl arguments: %v\n", args)
+		os.Exit(2)
+	}
+
+	path := os.Getenv("KUBECONFIG")
+	config, err := k8sclientcmd.LoadFromFile(path)
+	if err == nil {
+		if context, ok := config.Contexts[args[2]]; ok {
+			context.Namespace = strings.TrimPrefix(args[3], "--namespace=")
+			err = k8sclientcmd.WriteToFile(*config, path)
+		} else {
+			err = fmt.Errorf("context %q not found", args[2])
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/spf13/cobra"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+// Values of --layout.
+const (
+	layoutSingle = "single"
+	layoutSplit  = "split"
+)
+
+var (
+	kubeconfigLayout string
+	splitDir         string
+	splitIndex       bool
+	splitPrintPaths  bool
+)
+
+// addLayoutFlags adds the flags selecting where sync and save write the kubeconfig.
+func addLayoutFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&kubeconfigLayout, "layout", layoutSingle,
+		"Where to write credentials: 'single' merges them into the kubeconfig, 'split' writes one file per cluster to --dir")
+	cmd.Flags().StringVar(&splitDir, "dir", "", "Directory of the split layout (default: $HOME/.kube/doks)")
+	cmd.Flags().BoolVar(&splitIndex, "index", false, "With --layout split, also write an index of the cluster files to "+kubeconfig.SplitIndexFile)
+	cmd.Flags().BoolVar(&splitPrintPaths, "print-kubeconfig", false, "With --layout split, print the cluster files as a KUBECONFIG path list")
+}
+
+// splitLayout reports whether --layout selects the split layout, and checks its value.
+func splitLayout() (bool, error) {
+	switch kubeconfigLayout {
+	case layoutSingle, "":
+		return false, nil
+	case layoutSplit:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --layout %q: must be %q or %q", kubeconfigLayout, layoutSingle, layoutSplit)
+}
+
+// splitLayoutDir returns the directory of the split layout, ~/.kube/doks unless --dir is given.
+func splitLayoutDir() (string, error) {
+	if splitDir == "" {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding home directory: %w", err)
+		}
+		return filepath.Join(homedir, ".kube", "doks"), nil
+	}
+	if rest, ok := strings.CutPrefix(splitDir, "~/"); ok {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding home directory: %w", err)
+		}
+		return filepath.Join(homedir, rest), nil
+	}
+	return splitDir, nil
+}
+
+// syncSplit is sync for the split layout: it writes every live cluster to its own file in the split layout directory,
+// renewing expiring credentials, and removes the files of clusters that are gone, within maxPrune and maxPrunePercent.
+func syncSplit(cmd *cobra.Command, ctx context.Context, maxPrune, maxPrunePercent int) error {
+	dir, err := splitLayoutDir()
+	if err != nil {
+		return err
+	}
+
+	syncer, store, err := newSyncer(&accountClusters{}, nil, func(options *doks.Options) {
+		options.RefreshBefore = syncRefreshBefore
+		options.PruneExpired = syncPruneExpired
+		options.MaxPrune = maxPrune
+		options.MaxPrunePercent = maxPrunePercent
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := syncer.SyncSplit(ctx, dir)
+	printMessages(result, store)
+	recordSplitResult(cmd, dir, result)
+	if err != nil {
+		return pruneLimitExceeded(err)
+	}
+
+	if verbose {
+		for _, path := range result.Removed {
+			fmt.Fprintf(messages, "Notice: Removed kubeconfig of deleted cluster %s\n", path)
+		}
+		if len(result.Expired) > 0 {
+			fmt.Fprintf(messages, "Notice: Removing contexts with expired credentials: %v\n", result.Expired)
+		}
+		if len(result.Added) > 0 {
+			fmt.Fprintf(messages, "Notice: Adding contexts: %v\n", result.Added)
+		}
+		if len(result.Renewed) > 0 {
+			fmt.Fprintf(messages, "Notice: Renewing credentials for contexts: %v\n", result.Renewed)
+		}
+		if len(result.Updated) > 0 {
+			fmt.Fprintf(messages, "Notice: Updating cluster details for contexts: %v\n", result.Updated)
+		}
+		fmt.Fprintf(messages, "Notice: Successfully synced %d DOKS cluster(s) to %s.\n", len(result.Added)+len(result.Renewed), dir)
+	}
+
+	return finishSplitLayout(cmd, dir, result)
+}
+
+// saveSplit is save for the split layout: it writes the clusters named by args, or all the clusters that do not have
+// a file yet, to their own file in the split layout directory.
+func saveSplit(cmd *cobra.Command, ctx, waitCtx context.Context, args []string) error {
+	dir, err := splitLayoutDir()
+	if err != nil {
+		return err
+	}
+
+	accounts := &accountClusters{}
+	syncer, store, err := newSyncer(accounts, nil, func(options *doks.Options) {
+		if saveWait || saveWaitReady {
+			options.Wait = func(_ context.Context, cluster do.Cluster) (do.Cluster, error) {
+				return waitForCluster(waitCtx, accounts.clients[cluster.ID], cluster)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := syncer.SaveSplit(ctx, dir, args)
+	printMessages(result, store)
+	recordSplitResult(cmd, dir, result)
+	if err != nil {
+		return err
+	}
+	if len(result.Clusters) == 0 {
+		fmt.Fprintln(messages, "No DOKS clusters found.")
+		return nil
+	}
+
+	if verbose {
+		for _, cluster := range result.Clusters {
+			contextName := result.ContextNames[cluster.ID]
+			if slices.Contains(result.Added, contextName) {
+				fmt.Fprintf(messages, "Notice: Saved credentials for cluster %q from %s to %s\n", cluster.Name, doks.DescribeSource(cluster.Team, cluster.AuthContext), result.Files[contextName])
+			}
+		}
+		if len(result.Added) == 0 {
+			fmt.Fprintf(messages, "Notice: %s is already up to date.\n", dir)
+		}
+	}
+
+	if saveWaitReady {
+		for _, contextName := range result.Added {
+			config, err := k8sclientcmd.LoadFromFile(result.Files[contextName])
+			if err != nil {
+				return fmt.Errorf("reading kubeconfig at %s: %w", result.Files[contextName], err)
+			}
+			if err := waitForAPIServer(waitCtx, config, contextName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return finishSplitLayout(cmd, dir, result)
+}
+
+// finishSplitLayout writes the index of the split layout directory with --index, from the clusters of result,
+// and prints its cluster files as a KUBECONFIG path list to the output of cmd with --print-kubeconfig.
+func finishSplitLayout(cmd *cobra.Command, dir string, result doks.Result) error {
+	if splitIndex {
+		var entries []kubeconfig.SplitIndexEntry
+		for _, cluster := range result.Clusters {
+			contextName, ok := result.ContextNames[cluster.ID]
+			if !ok {
+				continue
+			}
+			path := kubeconfig.SplitFilePath(dir, cluster.ID)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			entries = append(entries, kubeconfig.SplitIndexEntry{
+				ClusterID: cluster.ID,
+				Name:      cluster.Name,
+				Context:   contextName,
+				Region:    cluster.Region,
+				Team:      cluster.Team.Name,
+				File:      filepath.Base(path),
+			})
+		}
+		if err := kubeconfig.WriteSplitIndex(dir, entries); err != nil {
+			return err
+		}
+	}
+
+	if splitPrintPaths {
+		paths, err := kubeconfig.SplitFiles(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), strings.Join(paths, string(os.PathListSeparator)))
+	}
+	return nil
+}
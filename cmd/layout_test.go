@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/audit"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+func TestSyncCommandSplitLayout(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha", "beta"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	dir := filepath.Join(tmpDir, "doks")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	// beta's credentials have expired, and gone no longer exists.
+	expiries := map[string]time.Time{"beta": time.Now().Add(-time.Hour), "gone": time.Now().Add(time.Hour)}
+	for name, expiresAt := range expiries {
+		data := kubeconfigWithExpiries(t, map[string]time.Time{name: expiresAt})
+		require.NoError(t, os.WriteFile(kubeconfig.SplitFilePath(dir, name+"-id"), data, 0600))
+	}
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	originalLayout, originalSplitDir, originalSplitIndex := kubeconfigLayout, splitDir, splitIndex
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	kubeconfigLayout, splitDir, splitIndex = layoutSplit, dir, true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+		kubeconfigLayout, splitDir, splitIndex = originalLayout, originalSplitDir, originalSplitIndex
+	}()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.ElementsMatch(t, []string{"alpha", "beta"}, fetched)
+
+	paths, err := kubeconfig.SplitFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "alpha-id"), kubeconfig.SplitFilePath(dir, "beta-id")}, paths,
+		"The file of the deleted cluster should be removed")
+
+	alpha, err := k8sclientcmd.LoadFromFile(kubeconfig.SplitFilePath(dir, "alpha-id"))
+	require.NoError(t, err)
+	assert.Equal(t, "do-nyc1-alpha", alpha.CurrentContext)
+	assert.Len(t, alpha.Contexts, 1)
+	assert.Equal(t, "new-alpha-token", alpha.AuthInfos["do-nyc1-alpha-admin"].Token)
+
+	beta, err := k8sclientcmd.LoadFromFile(kubeconfig.SplitFilePath(dir, "beta-id"))
+	require.NoError(t, err)
+	assert.Equal(t, "new-beta-token", beta.AuthInfos["do-nyc1-beta-admin"].Token, "Expired credentials should be renewed")
+
+	_, err = os.Stat(filepath.Join(dir, kubeconfig.SplitIndexFile))
+	assert.NoError(t, err, "The index should be written with --index")
+	_, err = os.Stat(filepath.Join(tmpDir, ".kube", "config"))
+	assert.True(t, os.IsNotExist(err), "The single kubeconfig should not be written")
+
+	auditLog := filepath.Join(tmpDir, ".kube", "kubectl-doks", audit.FileName)
+	records, err := audit.Read(auditLog)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, dir, records[0].Kubeconfig)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "alpha-id")}, records[0].Added)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "beta-id")}, records[0].Updated)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "gone-id")}, records[0].Removed)
+	assert.Empty(t, records[0].Operation, "Split layout changes cannot be undone")
+
+	// The files are up to date now.
+	fetched = nil
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.Empty(t, fetched)
+	records, err = audit.Read(auditLog)
+	require.NoError(t, err)
+	assert.Len(t, records, 1, "Runs that change nothing should not be recorded")
+
+	kubeconfigLayout = "tree"
+	assert.Error(t, syncCmd.RunE(syncCmd, []string{}))
+}
+
+func TestSyncCommandSplitLayoutPrintKubeconfig(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	dir := filepath.Join(tmpDir, "doks")
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	originalLayout, originalSplitDir, originalSplitPrintPaths, originalVerbose := kubeconfigLayout, splitDir, splitPrintPaths, verbose
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	kubeconfigLayout, splitDir, splitPrintPaths, verbose = layoutSplit, dir, true, true
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+		kubeconfigLayout, splitDir, splitPrintPaths, verbose = originalLayout, originalSplitDir, originalSplitPrintPaths, originalVerbose
+	}()
+
+	var stdout, stderr bytes.Buffer
+	syncCmd.SetOut(&stdout)
+	syncCmd.SetErr(&stderr)
+	defer func() {
+		syncCmd.SetOut(nil)
+		syncCmd.SetErr(nil)
+	}()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.Equal(t, kubeconfig.SplitFilePath(dir, "alpha-id")+"\n", stdout.String(), "Only the path list should be printed to stdout")
+	assert.Contains(t, stderr.String(), "Notice: Adding contexts: [do-nyc1-alpha]")
+	assert.Equal(t, os.Stdout, messages, "Messages should go to stdout again afterwards")
+}
+
+func TestSaveCommandSplitLayout(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha", "beta"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	originalLayout, originalSplitDir := kubeconfigLayout, splitDir
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	kubeconfigLayout, splitDir = layoutSplit, ""
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+		kubeconfigLayout, splitDir = originalLayout, originalSplitDir
+	}()
+
+	require.NoError(t, saveCmd.RunE(saveCmd, []string{"beta"}))
+	assert.Equal(t, []string{"beta"}, fetched)
+
+	dir := filepath.Join(tmpDir, ".kube", "doks")
+	paths, err := kubeconfig.SplitFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "beta-id")}, paths, "Files should go to ~/.kube/doks by default")
+
+	// Saving all clusters only adds the missing ones.
+	fetched = nil
+	require.NoError(t, saveCmd.RunE(saveCmd, []string{}))
+	assert.Equal(t, []string{"alpha"}, fetched)
+
+	records, err := audit.Read(filepath.Join(tmpDir, ".kube", "kubectl-doks", audit.FileName))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "kubeconfig save", records[1].Command)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "alpha-id")}, records[1].Added)
+}
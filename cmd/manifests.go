@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/manifests"
+	"github.com/spf13/cobra"
+)
+
+var (
+	argoCDNamespace string
+	fluxNamespace   string
+)
+
+// manifestsCmd represents the export command, which groups the GitOps manifest generators
+var manifestsCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Generate manifests registering DOKS clusters with GitOps tools",
+}
+
+// argoCDCmd represents the export argocd command
+var argoCDCmd = &cobra.Command{
+	Use:   "argocd [<cluster>...]",
+	Short: "Generate Argo CD cluster Secrets for DOKS clusters",
+	Long: `Fetches the credentials of the given clusters, or of all running clusters if none is given, and writes an
+Argo CD cluster Secret for each of them to standard output. Clusters are given like for kubeconfig save.
+Each Secret is named after the cluster's context, holds its API server, bearer token and CA data, and is labelled with
+the DigitalOcean cluster ID, region and team. Credentials are requested with --expiry-seconds.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return exportSecrets(cmd, args, func(cluster do.Cluster, contextName string, credentials do.Credentials) (manifests.Secret, error) {
+			return manifests.ArgoCDSecret(cluster, contextName, credentials, argoCDNamespace)
+		})
+	},
+}
+
+// fluxCmd represents the export flux command
+var fluxCmd = &cobra.Command{
+	Use:   "flux [<cluster>...]",
+	Short: "Generate Flux kubeconfig Secrets for DOKS clusters",
+	Long: `Fetches the credentials of the given clusters, or of all running clusters if none is given, and writes a
+Secret for each of them to standard output, holding the cluster's kubeconfig under the "value" key read by the
+kubeConfig.secretRef of Flux Kustomizations and HelmReleases. Clusters are given like for kubeconfig save.
+Each Secret is named after the cluster's context and labelled with the DigitalOcean cluster ID, region and team.
+Credentials are requested with --expiry-seconds.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return exportSecrets(cmd, args, func(cluster do.Cluster, contextName string, credentials do.Credentials) (manifests.Secret, error) {
+			reconciler, err := kubeconfig.NewReconciler(nil)
+			if err != nil {
+				return manifests.Secret{}, err
+			}
+			reconciler.AddCluster(cluster, contextName, credentials)
+			reconciler.Config().CurrentContext = contextName
+
+			config, err := reconciler.Bytes()
+			if err != nil {
+				return manifests.Secret{}, fmt.Errorf("serializing kubeconfig for cluster %s: %w", cluster.Name, err)
+			}
+			return manifests.FluxSecret(cluster, contextName, config, fluxNamespace), nil
+		})
+	},
+}
+
+// exportSecrets fetches the credentials of the clusters selected by args, builds a Secret for each of them with
+// newSecret, and writes the Secrets to the standard output of cmd.
+func exportSecrets(cmd *cobra.Command, args []string, newSecret func(cluster do.Cluster, contextName string, credentials do.Credentials) (manifests.Secret, error)) error {
+	// Notices and warnings must not end up in the manifests.
+	messages = cmd.ErrOrStderr()
+	defer func() { messages = os.Stdout }()
+
+	ctx := context.Background()
+	allClusters, clusterToClient, err := listAllClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	selectedClusters, err := selectExportClusters(allClusters, args)
+	if err != nil {
+		return err
+	}
+
+	contextNames, err := assignSelectedContextNames(allClusters, selectedClusters)
+	if err != nil {
+		return err
+	}
+
+	var secrets []manifests.Secret
+	for _, cluster := range selectedClusters {
+		credentials, err := clusterToClient[cluster.ID].GetCredentials(ctx, cluster.ID, expirySeconds)
+		if err != nil {
+			return fmt.Errorf("getting credentials for cluster %s: %w", cluster.Name, err)
+		}
+
+		secret, err := newSecret(cluster, contextNames[cluster.ID], credentials)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return manifests.Encode(cmd.OutOrStdout(), secrets)
+}
+
+func init() {
+	argoCDCmd.Flags().StringVarP(&argoCDNamespace, "namespace", "n", "argocd", "Namespace of the generated Secrets")
+	fluxCmd.Flags().StringVarP(&fluxNamespace, "namespace", "n", "flux-system", "Namespace of the generated Secrets")
+	manifestsCmd.AddCommand(argoCDCmd)
+	manifestsCmd.AddCommand(fluxCmd)
+	rootCmd.AddCommand(manifestsCmd)
+}
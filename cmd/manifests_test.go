@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+// decodeSecrets decodes the Secrets of a multi-document YAML stream.
+func decodeSecrets(t *testing.T, data []byte) []manifests.Secret {
+	var secrets []manifests.Secret
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var secret manifests.Secret
+		if err := decoder.Decode(&secret); err != nil {
+			break
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+func TestExportArgoCDCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha", "beta"}, &fetched)
+	defer server.Close()
+	t.Setenv("HOME", t.TempDir())
+
+	originalAPIURL, originalAccessTokens, originalArgoCDNamespace := apiURL, accessTokens, argoCDNamespace
+	apiURL, accessTokens, argoCDNamespace = server.URL, []string{"test-token"}, "argocd"
+	defer func() {
+		apiURL, accessTokens, argoCDNamespace = originalAPIURL, originalAccessTokens, originalArgoCDNamespace
+		argoCDCmd.SetOut(nil)
+	}()
+
+	var out bytes.Buffer
+	argoCDCmd.SetOut(&out)
+	require.NoError(t, argoCDCmd.RunE(argoCDCmd, []string{}))
+
+	secrets := decodeSecrets(t, out.Bytes())
+	require.Len(t, secrets, 2, "All running clusters should be exported when none is given")
+	assert.ElementsMatch(t, []string{"alpha", "beta"}, fetched)
+
+	secret := secrets[0]
+	assert.Equal(t, "do-nyc1-alpha", secret.Metadata.Name)
+	assert.Equal(t, "argocd", secret.Metadata.Namespace)
+	assert.Equal(t, "cluster", secret.Metadata.Labels[manifests.ArgoCDSecretTypeLabel])
+	assert.Equal(t, "alpha-id", secret.Metadata.Labels[manifests.ClusterIDLabel])
+	assert.Equal(t, "nyc1", secret.Metadata.Labels[manifests.RegionLabel])
+	assert.Equal(t, "https://alpha-server", secret.StringData["server"])
+	assert.Contains(t, secret.StringData["config"], `"bearerToken": "new-alpha-token"`)
+}
+
+func TestExportFluxCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha", "beta"}, &fetched)
+	defer server.Close()
+	t.Setenv("HOME", t.TempDir())
+
+	originalAPIURL, originalAccessTokens, originalFluxNamespace := apiURL, accessTokens, fluxNamespace
+	apiURL, accessTokens, fluxNamespace = server.URL, []string{"test-token"}, "flux-system"
+	defer func() {
+		apiURL, accessTokens, fluxNamespace = originalAPIURL, originalAccessTokens, originalFluxNamespace
+		fluxCmd.SetOut(nil)
+	}()
+
+	var out bytes.Buffer
+	fluxCmd.SetOut(&out)
+	require.NoError(t, fluxCmd.RunE(fluxCmd, []string{"beta"}))
+
+	secrets := decodeSecrets(t, out.Bytes())
+	require.Len(t, secrets, 1)
+	assert.Equal(t, []string{"beta"}, fetched)
+	assert.Equal(t, "flux-system", secrets[0].Metadata.Namespace)
+	assert.Equal(t, "beta-id", secrets[0].Metadata.Labels[manifests.ClusterIDLabel])
+
+	config, err := k8sclientcmd.Load([]byte(secrets[0].StringData[manifests.FluxKubeconfigKey]))
+	require.NoError(t, err)
+	assert.Equal(t, "do-nyc1-beta", config.CurrentContext)
+	assert.Equal(t, "new-beta-token", config.AuthInfos["do-nyc1-beta-admin"].Token)
+	id, _ := kubeconfig.GetClusterID(config.Clusters["do-nyc1-beta"])
+	assert.Equal(t, "beta-id", id)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/spf13/cobra"
+)
+
+var refreshBefore time.Duration
+
+// refreshCmd represents the refresh command
+var refreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Renew DOKS credentials that are about to expire",
+	Long: `Fetches new credentials for the DOKS contexts whose credentials have expired or expire within --before,
+and reports what was renewed. Contexts with credentials that are still fresh, or that have no recorded expiry,
+are left untouched. New credentials last as long as the ones they replace, unless --expiry-seconds is given.
+Expiring credentials renewed by sync are handled the same way.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		syncer, store, err := newSyncer(&accountClusters{}, nil, func(options *doks.Options) {
+			options.RefreshBefore = refreshBefore
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := syncer.Refresh(context.Background())
+		printMessages(result, store)
+		recordResult(cmd, store, result)
+		if err != nil {
+			return err
+		}
+
+		if len(result.Renewed) == 0 && len(result.Unrenewed) == 0 {
+			fmt.Printf("No DOKS credentials expire within %s.\n", refreshBefore)
+			return nil
+		}
+
+		now := time.Now()
+		for _, contextName := range slices.Sorted(maps.Keys(result.Expiries)) {
+			expiresAt := result.Expiries[contextName]
+			when := "expiring"
+			if expiresAt.Before(now) {
+				when = "expired"
+			}
+			if !slices.Contains(result.Renewed, contextName) {
+				fmt.Printf("Warning: Could not renew credentials for %q (%s at %s); its cluster was not found or is not running.\n",
+					contextName, when, expiresAt.Format(time.RFC3339))
+				continue
+			}
+			fmt.Printf("Renewed credentials for %q (%s at %s).\n", contextName, when, expiresAt.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+func init() {
+	refreshCmd.Flags().DurationVar(&refreshBefore, "before", time.Hour, "Renew credentials that expire within this duration")
+	kubeconfigCmd.AddCommand(refreshCmd)
+}
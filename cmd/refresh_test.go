@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// newRefreshServer returns a server listing a running cluster named <name> with ID <name>-id for each name,
+// whose credentials have the token new-<name>-token and expire in a day.
+// The names of the clusters whose credentials were fetched successfully are added to fetched.
+func newRefreshServer(t *testing.T, names []string, fetched *[]string) *dotest.Server {
+	server := dotest.NewServer()
+	server.AddToken("test-token", do.Account{})
+	for _, name := range names {
+		server.AddCluster(do.Cluster{ID: name + "-id", Name: name, Region: "nyc1", Endpoint: "https://" + name + "-server"})
+	}
+	server.SetDefaultExpiry(24 * time.Hour)
+	server.SetTokenFunc(func(cluster do.Cluster) string { return "new-" + cluster.Name + "-token" })
+	server.OnRequest(func(request dotest.Request) {
+		if request.Route == dotest.RouteCredentials && request.Status == http.StatusOK {
+			*fetched = append(*fetched, strings.TrimSuffix(request.ClusterID, "-id"))
+		}
+	})
+	return server
+}
+
+// kubeconfigWithExpiries returns a kubeconfig with a managed context do-nyc1-<name> for each cluster,
+// whose credentials have the token old-<name>-token and expire at the given time.
+func kubeconfigWithExpiries(t *testing.T, expiries map[string]time.Time) []byte {
+	config := k8sclientcmdapi.NewConfig()
+	for name, expiresAt := range expiries {
+		contextName := "do-nyc1-" + name
+		cluster := k8sclientcmdapi.NewCluster()
+		cluster.Server = "https://" + name + "-server"
+		kubeconfig.SetClusterID(cluster, name+"-id")
+		config.Clusters[contextName] = cluster
+
+		authInfo := k8sclientcmdapi.NewAuthInfo()
+		authInfo.Token = "old-" + name + "-token"
+		kubeconfig.SetCredentialsExpiry(authInfo, expiresAt)
+		config.AuthInfos[contextName+"-admin"] = authInfo
+
+		config.Contexts[contextName] = &k8sclientcmdapi.Context{Cluster: contextName, AuthInfo: contextName + "-admin"}
+	}
+	data, err := k8sclientcmd.Write(*config)
+	require.NoError(t, err)
+	return data
+}
+
+func TestRefreshCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"expired", "expiring", "fresh"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, kubeconfigWithExpiries(t, map[string]time.Time{
+		"expired":  time.Now().Add(-time.Hour),
+		"expiring": time.Now().Add(30 * time.Minute),
+		"fresh":    time.Now().Add(12 * time.Hour),
+		"gone":     time.Now().Add(-time.Hour),
+	}), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalRefreshBefore := apiURL, accessTokens, kubeConfigPath, refreshBefore
+	apiURL, accessTokens, kubeConfigPath, refreshBefore = server.URL, []string{"test-token"}, "", time.Hour
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, refreshBefore = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalRefreshBefore
+	}()
+
+	require.NoError(t, refreshCmd.RunE(refreshCmd, []string{}))
+	assert.ElementsMatch(t, []string{"expired", "expiring"}, fetched)
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	assert.Equal(t, "new-expired-token", updated.AuthInfos["do-nyc1-expired-admin"].Token)
+	assert.Equal(t, "new-expiring-token", updated.AuthInfos["do-nyc1-expiring-admin"].Token)
+	assert.Equal(t, "old-fresh-token", updated.AuthInfos["do-nyc1-fresh-admin"].Token, "Fresh credentials should be left untouched")
+	assert.Equal(t, "old-gone-token", updated.AuthInfos["do-nyc1-gone-admin"].Token, "Credentials of clusters that are gone cannot be renewed")
+
+	expiresAt, ok := kubeconfig.CredentialsExpiry(updated, "do-nyc1-expired")
+	require.True(t, ok)
+	assert.True(t, expiresAt.After(time.Now().Add(23*time.Hour)), "The new expiry should be recorded")
+	id, _ := kubeconfig.GetClusterID(updated.Clusters["do-nyc1-expired"])
+	assert.Equal(t, "expired-id", id)
+
+	_, err = os.Stat(finalKubeConfigPath + ".kubectl-doks.bak")
+	assert.NoError(t, err, "A backup should be created before renewing credentials")
+
+	// Nothing is left to renew.
+	fetched = nil
+	refreshBefore = 0
+	require.NoError(t, refreshCmd.RunE(refreshCmd, []string{}))
+	assert.Empty(t, fetched)
+}
+
+func TestSyncCommandRenewsExpiringCredentials(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"expired", "expiring", "fresh"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, kubeconfigWithExpiries(t, map[string]time.Time{
+		"expired":  time.Now().Add(-time.Hour),
+		"expiring": time.Now().Add(30 * time.Minute),
+		"fresh":    time.Now().Add(12 * time.Hour),
+	}), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncRefreshBefore := apiURL, accessTokens, kubeConfigPath, syncRefreshBefore
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, syncRefreshBefore = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalSyncRefreshBefore
+	}()
+
+	t.Run("renews expired credentials", func(t *testing.T) {
+		syncRefreshBefore = 0
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+		assert.Equal(t, []string{"expired"}, fetched)
+	})
+
+	t.Run("renews credentials expiring within --refresh-before", func(t *testing.T) {
+		fetched = nil
+		syncRefreshBefore = time.Hour
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+		assert.Equal(t, []string{"expiring"}, fetched)
+	})
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "new-expired-token", updated.AuthInfos["do-nyc1-expired-admin"].Token)
+	assert.Equal(t, "new-expiring-token", updated.AuthInfos["do-nyc1-expiring-admin"].Token)
+	assert.Equal(t, "old-fresh-token", updated.AuthInfos["do-nyc1-fresh-admin"].Token)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// errNoManagedContext is returned when no managed kubeconfig context matches a query.
+var errNoManagedContext = errors.New("no managed context matches")
+
+// resolveManagedContext finds the kubeconfig context for a DOKS cluster that is already saved in config.
+// Only contexts whose cluster entry carries the DigitalOcean cluster ID extension are considered.
+// The query can be a context name, a cluster name, a cluster ID, or a unique prefix of a cluster ID.
+func resolveManagedContext(config *k8sclientcmdapi.Config, query string) (string, error) {
+	var candidates []string
+	for contextName, context := range config.Contexts {
+		cluster, ok := config.Clusters[context.Cluster]
+		if !ok {
+			continue
+		}
+		id, ok := kubeconfig.GetClusterID(cluster)
+		if !ok {
+			continue
+		}
+
+		if contextName == query || id == query {
+			return contextName, nil
+		}
+
+		name, _ := kubeconfig.ClusterNameFromContext(contextName)
+		if name == query || strings.HasPrefix(id, query) {
+			candidates = append(candidates, contextName)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", errNoManagedContext
+	case 1:
+		return candidates[0], nil
+	default:
+		sort.Strings(candidates)
+		return "", fmt.Errorf("%q matches multiple contexts: %s", query, strings.Join(candidates, ", "))
+	}
+}
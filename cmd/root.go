@@ -9,15 +9,20 @@ import (
 
 var (
 	// Global flags
-	accessTokens    []string
-	authContexts    []string
-	allAuthContexts bool
-	apiURL          string
-	configFile      string
-	verbose           bool
-	setCurrentContext bool
-	expirySeconds     int
-	force             bool
+	accessTokens        []string
+	accessTokenFiles    []string
+	accessTokenStdin    bool
+	accessTokenCommands []string
+	authContexts        []string
+	allAuthContexts     bool
+	apiURL              string
+	configFile          string
+	verbose             bool
+	setCurrentContext   bool
+	expirySeconds       int
+	force               bool
+	preferAuthContext   string
+	nameCollision       string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,6 +51,12 @@ func init() {
 	// Global flags for authentication and configuration
 	rootCmd.PersistentFlags().StringSliceVarP(&accessTokens, "access-token", "t", nil,
 		"DigitalOcean API V2 token (can specify multiple times)")
+	rootCmd.PersistentFlags().StringSliceVar(&accessTokenFiles, "access-token-file", nil,
+		"Read DigitalOcean API V2 tokens from this file, one per line (can specify multiple times)")
+	rootCmd.PersistentFlags().BoolVar(&accessTokenStdin, "access-token-stdin", false,
+		"Read DigitalOcean API V2 tokens from standard input, one per line")
+	rootCmd.PersistentFlags().StringArrayVar(&accessTokenCommands, "access-token-command", nil,
+		"Run this shell command and read DigitalOcean API V2 tokens from its output, one per line (can specify multiple times)")
 	rootCmd.PersistentFlags().StringSliceVarP(&authContexts, "auth-context", "", nil,
 		"Use this doctl authentication context (can specify multiple times)")
 	rootCmd.PersistentFlags().BoolVarP(&allAuthContexts, "all-auth-contexts", "", false, "Include all doctl authentication contexts")
@@ -55,20 +66,40 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&setCurrentContext, "set-current-context", true, "Set current-context after a successful save or sync")
 	rootCmd.PersistentFlags().IntVar(&expirySeconds, "expiry-seconds", 0, "The number of seconds until the kubeconfig expires. 0 means no expiration.")
 	rootCmd.PersistentFlags().BoolVarP(&force, "force", "f", false, "Force resync of kubeconfig even if it is up-to-date")
+	rootCmd.PersistentFlags().StringVar(&preferAuthContext, "prefer-auth-context", "",
+		"Use this doctl authentication context for clusters visible to several tokens")
+	rootCmd.PersistentFlags().StringVar(&nameCollision, "name-collision", "",
+		`How to handle different clusters with the same context name: "error" or "team" (default "error")`)
 }
 
+// credentialsAnnotation is the key of the cobra.Command annotation telling validateAuthFlags which credentials a
+// command needs. Commands without it need an authentication method.
+const credentialsAnnotation = "kubectl-doks/credentials"
+
+// Values of credentialsAnnotation.
+const (
+	// credentialsNone marks commands that never use credentials, such as version: the auth flags are not checked at all.
+	credentialsNone = "none"
+	// credentialsOptional marks commands that work without credentials, such as gc, or report missing credentials
+	// themselves, such as auth status: conflicting auth flags are rejected, but no authentication method is required.
+	credentialsOptional = "optional"
+)
+
 // validateAuthFlags ensures that at least one authentication method is specified.
 func validateAuthFlags(cmd *cobra.Command, args []string) error {
-	// Skip validation for help and version commands
-	if cmd.Name() == "help" || cmd.Name() == "version" {
+	// Skip validation for cobra's help command, which cannot be annotated.
+	if cmd.Name() == "help" || cmd.Annotations[credentialsAnnotation] == credentialsNone {
 		return nil
 	}
 	if err := validateAuthSources(); err != nil {
 		return err
 	}
+	if cmd.Annotations[credentialsAnnotation] == credentialsOptional {
+		return nil
+	}
 
 	// Check if at least one authentication method is provided via flags, environment variables, or a doctl config file.
-	flagsProvided := len(accessTokens) > 0 || len(authContexts) > 0 || allAuthContexts
+	flagsProvided := hasExplicitTokens() || len(authContexts) > 0 || allAuthContexts
 	envProvided := os.Getenv("DIGITALOCEAN_ACCESS_TOKEN") != ""
 
 	if !flagsProvided && !envProvided {
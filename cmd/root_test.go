@@ -160,3 +160,30 @@ func TestValidateAuthFlags_HelpCommand(t *testing.T) {
 		t.Errorf("validateAuthFlags() should not return error for help command, got: %v", err)
 	}
 }
+
+func TestValidateAuthFlags_CredentialsAnnotation(t *testing.T) {
+	originalAccessTokens, originalAuthContexts, originalAllAuthContexts := accessTokens, authContexts, allAuthContexts
+	accessTokens, authContexts, allAuthContexts = nil, nil, false
+	defer func() {
+		accessTokens, authContexts, allAuthContexts = originalAccessTokens, originalAuthContexts, originalAllAuthContexts
+	}()
+	t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "")
+	t.Setenv("HOME", t.TempDir())
+
+	optional := &cobra.Command{Use: "optional", Annotations: map[string]string{credentialsAnnotation: credentialsOptional}}
+	if err := validateAuthFlags(optional, []string{}); err != nil {
+		t.Errorf("validateAuthFlags() should not require credentials for an optional command, got: %v", err)
+	}
+
+	// Commands are exempted by their annotation, not their name.
+	gc := &cobra.Command{Use: "gc"}
+	if err := validateAuthFlags(gc, []string{}); err == nil {
+		t.Error("validateAuthFlags() should require credentials for a command without the annotation")
+	}
+
+	for _, cmd := range []*cobra.Command{versionCmd, useCmd, gcCmd, historyCmd, undoCmd, authStatusCmd} {
+		if err := validateAuthFlags(cmd, []string{}); err != nil {
+			t.Errorf("validateAuthFlags() should not require credentials for %s, got: %v", cmd.CommandPath(), err)
+		}
+	}
+}
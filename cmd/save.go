@@ -4,238 +4,125 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/DO-Solutions/kubectl-doks/do"
-	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
 	"github.com/spf13/cobra"
-	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+var (
+	saveWait        bool
+	saveWaitTimeout time.Duration
+	saveWaitReady   bool
 )
 
 // saveCmd represents the save command
 var saveCmd = &cobra.Command{
-	Use:   "save [<cluster-name>]",
+	Use:   "save [<cluster>...]",
 	Short: "Save cluster credentials",
 	Long: `Fetches cluster credentials and merges them into ~/.kube/config.
-If a cluster name is provided, it saves that specific cluster's credentials.
-If no cluster name is provided, it saves the credentials for all available clusters.`,
+If clusters are provided, it saves the credentials of those clusters. Each one can be given as a cluster ID,
+a do:kubernetes:<id> URN, a unique ID prefix, a cluster name, or a name qualified as <team>/<name> or <region>/<name>.
+If no cluster is provided, it saves the credentials for all available clusters.
+
+With --wait, clusters that are still provisioning are polled until they are running before their
+credentials are saved. With --wait-ready, the command also waits until their API servers are ready.
+
+With --layout split, each cluster is written to its own file named after its ID in --dir instead.
+Hooks run like for sync, unless --no-hooks is given.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var existingConfigBytes []byte
-		var err error
-		kubeConfigPath, existingConfigBytes, err = kubeconfig.GetKubeconfig(kubeConfigPath)
+		split, err := splitLayout()
 		if err != nil {
 			return err
 		}
 
-		ctx := context.Background()
-
-		tokens, err := getAllAccessTokens()
+		runner, err := loadHooks()
 		if err != nil {
 			return err
 		}
 
-		var allClusters []do.Cluster
-		clusterToClient := make(map[string]*do.Client)
+		ctx := context.Background()
 
-		for _, token := range tokens {
-			client, err := do.NewClient(token, apiURL)
-			if err != nil {
-				return fmt.Errorf("creating DigitalOcean client: %w", err)
-			}
+		// All waiting shares a single --wait-timeout deadline.
+		waiting := saveWait || saveWaitReady
+		waitCtx, cancel := context.WithTimeout(ctx, saveWaitTimeout)
+		defer cancel()
 
-			clusters, err := client.ListClusters(ctx)
-			if err != nil {
-				return fmt.Errorf("fetching clusters for a token: %w", err)
+		if split {
+			if splitPrintPaths {
+				// Notices and warnings must not end up in the KUBECONFIG path list.
+				messages = cmd.ErrOrStderr()
+				defer func() { messages = os.Stdout }()
 			}
+			warnSplitHooks(runner)
+			return saveSplit(cmd, ctx, waitCtx, args)
+		}
 
-			for _, cluster := range clusters {
-				allClusters = append(allClusters, cluster)
-				clusterToClient[cluster.ID] = client
+		accounts := &accountClusters{}
+		syncer, store, err := newSyncer(accounts, runner, func(options *doks.Options) {
+			if waiting {
+				options.Wait = func(_ context.Context, cluster do.Cluster) (do.Cluster, error) {
+					return waitForCluster(waitCtx, accounts.clients[cluster.ID], cluster)
+				}
 			}
+		})
+		if err != nil {
+			return err
 		}
 
-		if len(allClusters) == 0 {
+		result, err := syncer.Save(ctx, args)
+		printMessages(result, store)
+		recordResult(cmd, store, result)
+		if err != nil {
+			return err
+		}
+
+		if len(result.Clusters) == 0 {
 			fmt.Println("No DOKS clusters found.")
 			return nil
 		}
-
-		if len(args) > 0 {
-			clusterName := args[0]
-			var selectedCluster do.Cluster
-			found := false
-			for _, cluster := range allClusters {
-				if cluster.Name == clusterName {
-					selectedCluster = cluster
-					found = true
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("cluster %q not found", clusterName)
-			}
-
-			client := clusterToClient[selectedCluster.ID]
-			kubeConfigBytes, err := client.GetKubeConfig(ctx, selectedCluster.ID, expirySeconds)
-			if err != nil {
-				return fmt.Errorf("getting kubeconfig for cluster %s: %w", selectedCluster.Name, err)
+		if !result.Written {
+			if verbose {
+				fmt.Println("Notice: Kubeconfig is already up to date.")
 			}
+			return nil
+		}
 
-			backupPath := kubeConfigPath + ".kubectl-doks.bak"
-			if _, err := os.Stat(kubeConfigPath); err == nil {
-				if verbose {
-					fmt.Printf("Notice: Creating backup of kubeconfig at %s\n", backupPath)
-				}
-				if err := kubeconfig.BackupKubeconfig(kubeConfigPath, backupPath); err != nil {
-					return fmt.Errorf("backing up kubeconfig: %w", err)
+		if verbose {
+			if len(args) > 0 {
+				for _, contextName := range result.Added {
+					fmt.Printf("Notice: Saved credentials for context %q to %s\n", contextName, store.Path)
 				}
-			}
-
-			var mergedConfigBytes []byte
-			if len(existingConfigBytes) == 0 {
-				mergedConfigBytes = kubeConfigBytes
+			} else if expirySeconds == 0 {
+				fmt.Printf("Notice: Adding contexts: %v without expiration.\n", result.Added)
 			} else {
-				mergedConfigBytes, err = kubeconfig.MergeConfig(existingConfigBytes, kubeConfigBytes, false) // Always merge with false first
-				if err != nil {
-					return fmt.Errorf("merging kubeconfig for cluster %s: %w", selectedCluster.Name, err)
-				}
-			}
-
-			config, err := k8sclientcmd.Load(mergedConfigBytes)
-			if err != nil {
-				return fmt.Errorf("reloading kubeconfig after merge: %w", err)
-			}
-
-			contextName := fmt.Sprintf("do-%s-%s", selectedCluster.Region, selectedCluster.Name)
-			if cluster, ok := config.Clusters[contextName]; ok {
-				kubeconfig.SetClusterID(cluster, selectedCluster.ID)
-			}
-
-			if setCurrentContext {
-				config.CurrentContext = contextName
-			}
-
-			mergedConfigBytes, err = k8sclientcmd.Write(*config)
-			if err != nil {
-				return fmt.Errorf("serializing modified kubeconfig: %w", err)
+				fmt.Printf("Notice: Adding contexts: %v with expiration set to %d seconds.\n", result.Added, expirySeconds)
 			}
-
-			if err := os.WriteFile(kubeConfigPath, mergedConfigBytes, 0600); err != nil {
-				return fmt.Errorf("writing updated kubeconfig: %w", err)
-			}
-
-			if verbose {
-				fmt.Printf("Notice: Saved credentials for cluster %q to %s\n", selectedCluster.Name, kubeConfigPath)
-				if setCurrentContext {
-					fmt.Printf("Notice: Set current-context to %q\n", contextName)
-				}
+			if result.CurrentContext != "" {
+				fmt.Printf("Notice: Set current-context to %q\n", result.CurrentContext)
 			}
-		} else {
-			// If no cluster name is provided, save all clusters.
-			currentConfigBytes := existingConfigBytes
-			var addedContexts []string
-
-			configObj, err := k8sclientcmd.Load(currentConfigBytes)
-			if err != nil {
-				if len(currentConfigBytes) == 0 {
-					configObj = api.NewConfig()
-				} else {
-					return fmt.Errorf("parsing kubeconfig: %w", err)
-				}
-			}
-
-			for _, cluster := range allClusters {
-				expectedContextName := fmt.Sprintf("do-%s-%s", cluster.Region, cluster.Name)
-				if _, exists := configObj.Contexts[expectedContextName]; exists && !force {
-					continue
-				}
-
-				client := clusterToClient[cluster.ID]
-				kubeConfigBytes, err := client.GetKubeConfig(ctx, cluster.ID, expirySeconds)
-				if err != nil {
-					return fmt.Errorf("getting kubeconfig for cluster %s: %w", cluster.Name, err)
-				}
-
-				var mergedConfigBytes []byte
-				if len(currentConfigBytes) == 0 {
-					mergedConfigBytes = kubeConfigBytes
-				} else {
-					mergedConfigBytes, err = kubeconfig.MergeConfig(currentConfigBytes, kubeConfigBytes, false)
-					if err != nil {
-						return fmt.Errorf("merging kubeconfig for cluster %s: %w", cluster.Name, err)
-					}
-				}
-
-				// Reload config object to add extension and check for next cluster
-				configObj, err = k8sclientcmd.Load(mergedConfigBytes)
-				if err != nil {
-					return fmt.Errorf("reloading kubeconfig after merge: %w", err)
-				}
-
-				if c, ok := configObj.Clusters[expectedContextName]; ok {
-					kubeconfig.SetClusterID(c, cluster.ID)
-				}
-
-				currentConfigBytes, err = k8sclientcmd.Write(*configObj)
-				if err != nil {
-					return fmt.Errorf("serializing intermediate kubeconfig: %w", err)
-				}
-
-				addedContexts = append(addedContexts, expectedContextName)
+			if len(args) == 0 {
+				fmt.Printf("Notice: Successfully saved %d DOKS cluster(s) to your kubeconfig file.\n", len(result.Added))
 			}
+		}
 
-			if len(addedContexts) > 0 {
-				backupPath := kubeConfigPath + ".kubectl-doks.bak"
-				if _, err := os.Stat(kubeConfigPath); err == nil {
-					if verbose {
-						fmt.Printf("Notice: Creating backup of kubeconfig at %s\n", backupPath)
-					}
-					if err := kubeconfig.BackupKubeconfig(kubeConfigPath, backupPath); err != nil {
-						return fmt.Errorf("backing up kubeconfig: %w", err)
-					}
-				}
-
-				if verbose {
-					if expirySeconds == 0 {
-						fmt.Printf("Notice: Adding contexts: %v without expiration.\n", addedContexts)
-					} else {
-						fmt.Printf("Notice: Adding contexts: %v with expiration set to %d seconds.\n", addedContexts, expirySeconds)
-					}
-				}
-
-				config, err := k8sclientcmd.Load(currentConfigBytes)
-				if err != nil {
-					return fmt.Errorf("loading final kubeconfig: %w", err)
-				}
-
-				if setCurrentContext && len(addedContexts) == 1 && config.CurrentContext == "" {
-					config.CurrentContext = addedContexts[0]
-					if verbose {
-						fmt.Printf("Notice: Set current-context to %q\n", addedContexts[0])
-					}
-				}
-
-				finalConfigBytes, err := k8sclientcmd.Write(*config)
-				if err != nil {
-					return fmt.Errorf("serializing final kubeconfig: %w", err)
-				}
-
-				if err := os.WriteFile(kubeConfigPath, finalConfigBytes, 0600); err != nil {
-					return fmt.Errorf("writing updated kubeconfig: %w", err)
-				}
-
-				if verbose {
-					fmt.Printf("Notice: Successfully saved %d DOKS cluster(s) to your kubeconfig file.\n", len(addedContexts))
-				}
-			} else {
-				if verbose {
-					fmt.Println("Notice: Kubeconfig is already up to date.")
+		if saveWaitReady {
+			for _, contextName := range result.Added {
+				if err := waitForAPIServer(waitCtx, result.Config, contextName); err != nil {
+					return err
 				}
 			}
 		}
-		return nil
+		return runPostSyncHooks(ctx, runner, store, result)
 	},
 }
 
 func init() {
+	saveCmd.Flags().BoolVar(&saveWait, "wait", false, "Wait for clusters that are not running yet before saving their credentials")
+	saveCmd.Flags().DurationVar(&saveWaitTimeout, "wait-timeout", 15*time.Minute, "How long to wait with --wait or --wait-ready")
+	saveCmd.Flags().BoolVar(&saveWaitReady, "wait-ready", false, "Also wait until the API servers respond on /readyz after saving (implies --wait)")
+	saveCmd.Flags().BoolVar(&noHooks, "no-hooks", false, "Do not run the hooks configured in the kubectl-doks config file")
+	addLayoutFlags(saveCmd)
 	kubeconfigCmd.AddCommand(saveCmd)
-}
\ No newline at end of file
+}
@@ -1,19 +1,21 @@
 package cmd
 
 import (
-	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
-	"github.com/digitalocean/godo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
 
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
 	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
 )
 
@@ -58,28 +60,7 @@ users:
 
 func TestSaveCommand(t *testing.T) {
 	// 1. Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "new-cluster-id",
-					Name:       "new-cluster",
-					RegionSlug: "sfo3",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/new-cluster-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfigForSave)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3"})
 	defer server.Close()
 
 	// 2. Set up temporary environment and flags
@@ -143,28 +124,7 @@ func TestSaveCommand(t *testing.T) {
 
 func TestSaveCommandWithForce(t *testing.T) {
 	// 1. Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "new-cluster-id",
-					Name:       "new-cluster",
-					RegionSlug: "sfo3",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/new-cluster-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfigForSave)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3"})
 	defer server.Close()
 
 	// 2. Set up temporary environment and flags
@@ -213,28 +173,7 @@ func TestSaveCommandWithForce(t *testing.T) {
 
 func TestSaveCommandWithMissingKubeconfig(t *testing.T) {
 	// 1. Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "new-cluster-id",
-					Name:       "new-cluster",
-					RegionSlug: "sfo3",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/new-cluster-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfigForSave)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3"})
 	defer server.Close()
 
 	t.Run("save specific cluster when kubeconfig doesn't exist", func(t *testing.T) {
@@ -349,53 +288,10 @@ func TestSaveCommandWithMissingKubeconfig(t *testing.T) {
 }
 
 func TestSaveCommandContextHandling(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "new-cluster-id",
-					Name:       "new-cluster",
-					RegionSlug: "sfo3",
-				},
-				{
-					ID:         "another-cluster-id",
-					Name:       "another-cluster",
-					RegionSlug: "nyc1",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/new-cluster-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfigForSave)
-		} else if r.URL.Path == "/v2/kubernetes/clusters/another-cluster-id/kubeconfig" {
-			const anotherKubeconfig = `
-apiVersion: v1
-clusters:
-- cluster:
-    server: https://another-cluster-server
-  name: do-nyc1-another-cluster
-contexts:
-- context:
-    cluster: do-nyc1-another-cluster
-    user: do-nyc1-another-cluster-admin
-  name: do-nyc1-another-cluster
-current-context: do-nyc1-another-cluster
-kind: Config
-users:
-- name: do-nyc1-another-cluster-admin
-  user:
-    token: another-token
-`
-			fmt.Fprint(w, anotherKubeconfig)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(
+		do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3"},
+		do.Cluster{ID: "another-cluster-id", Name: "another-cluster", Region: "nyc1"},
+	)
 	defer server.Close()
 
 	setup := func(t *testing.T, initialKubeconfig string) (string, func()) {
@@ -456,30 +352,39 @@ users:
 		assert.Equal(t, "do-nyc1-old-cluster", updatedKubeconfig.CurrentContext)
 	})
 
+	t.Run("save multiple clusters", func(t *testing.T) {
+		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSave)
+		defer cleanup()
+
+		setCurrentContext = true
+		err := saveCmd.RunE(saveCmd, []string{"new-cluster", "do:kubernetes:another-cluster-id", "sfo3/new-cluster"})
+		require.NoError(t, err)
+
+		updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+		require.NoError(t, err)
+		updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+		require.NoError(t, err)
+		assert.Contains(t, updatedKubeconfig.Contexts, "do-sfo3-new-cluster")
+		assert.Contains(t, updatedKubeconfig.Contexts, "do-nyc1-another-cluster")
+		assert.Equal(t, "do-nyc1-another-cluster", updatedKubeconfig.CurrentContext, "The last saved cluster should become the current context")
+	})
+
+	t.Run("save with a misspelled cluster saves nothing", func(t *testing.T) {
+		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSave)
+		defer cleanup()
+
+		err := saveCmd.RunE(saveCmd, []string{"new-cluster", "anther-cluster"})
+		require.Error(t, err)
+		assert.Equal(t, `cluster "anther-cluster" not found; did you mean "another-cluster"?`, err.Error())
+
+		updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, initialKubeconfigForSave, string(updatedBytes))
+	})
+
 	t.Run("save all with one new cluster and unset current context", func(t *testing.T) {
 		// This test needs a server that returns only one cluster to test the logic correctly.
-		singleClusterServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{
-					{
-						ID:         "new-cluster-id",
-						Name:       "new-cluster",
-						RegionSlug: "sfo3",
-					},
-				}
-				response := struct {
-					KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-				}{
-					KubernetesClusters: clusters,
-				}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			} else if r.URL.Path == "/v2/kubernetes/clusters/new-cluster-id/kubeconfig" {
-				fmt.Fprint(w, mockKubeconfigForSave)
-			} else {
-				w.WriteHeader(http.StatusNotFound)
-			}
-		}))
+		singleClusterServer := newTestServer(do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3"})
 		defer singleClusterServer.Close()
 
 		const initialKubeconfigNoCurrent = `
@@ -532,28 +437,7 @@ users: []
 
 func TestSaveCommandNoBackupWhenKubeconfigMissing(t *testing.T) {
 	// Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "test-cluster-id",
-					Name:       "test-cluster",
-					RegionSlug: "sfo3",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/test-cluster-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfigForSave)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "test-cluster-id", Name: "test-cluster", Region: "sfo3"})
 	defer server.Close()
 
 	t.Run("save specific cluster - no backup when kubeconfig doesn't exist", func(t *testing.T) {
@@ -677,3 +561,105 @@ func TestSaveCommandNoBackupWhenKubeconfigMissing(t *testing.T) {
 		assert.Equal(t, initialKubeconfigForSave, string(backupContent), "Backup should contain original content")
 	})
 }
+
+func TestSaveCommandWithWait(t *testing.T) {
+	var readyzCalls int
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		readyzCalls++
+		if readyzCalls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer apiServer.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiServer.Certificate().Raw})
+
+	// newServer returns a server listing the cluster in the first state, and moving it to the next state each time
+	// it is polled.
+	newServer := func(states ...string) *dotest.Server {
+		server := newTestServer(do.Cluster{ID: "new-cluster-id", Name: "new-cluster", Region: "sfo3", Status: states[0], Endpoint: apiServer.URL})
+		server.SetCertificateAuthorityFunc(func(do.Cluster) []byte { return ca })
+		var getCalls int
+		server.OnRequest(func(request dotest.Request) {
+			if request.Route == dotest.RouteGetCluster {
+				getCalls++
+				server.SetClusterStatus("new-cluster-id", states[min(getCalls, len(states)-1)])
+			}
+		})
+		return server
+	}
+
+	setup := func(t *testing.T, server *dotest.Server) string {
+		tmpDir := t.TempDir()
+		t.Setenv("HOME", tmpDir)
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, ".kube"), 0755))
+
+		originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+		originalInterval, originalTimeout := waitPollInterval, saveWaitTimeout
+		apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+		waitPollInterval, saveWaitTimeout = time.Millisecond, time.Second
+		t.Cleanup(func() {
+			apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+			waitPollInterval, saveWaitTimeout = originalInterval, originalTimeout
+			saveWait, saveWaitReady = false, false
+		})
+		return filepath.Join(tmpDir, ".kube", "config")
+	}
+
+	t.Run("waits for the cluster and its API server", func(t *testing.T) {
+		server := newServer("provisioning", "provisioning", "running")
+		defer server.Close()
+		finalKubeConfigPath := setup(t, server)
+		saveWaitReady = true
+
+		require.NoError(t, saveCmd.RunE(saveCmd, []string{"new-cluster"}))
+
+		updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+		require.NoError(t, err)
+		updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+		require.NoError(t, err)
+		assert.Contains(t, updatedKubeconfig.Contexts, "do-sfo3-new-cluster")
+		assert.Equal(t, 3, readyzCalls)
+	})
+
+	t.Run("fails when the cluster errors", func(t *testing.T) {
+		server := newServer("provisioning", "error")
+		defer server.Close()
+		finalKubeConfigPath := setup(t, server)
+		saveWait = true
+
+		err := saveCmd.RunE(saveCmd, []string{"new-cluster"})
+		require.Error(t, err)
+		assert.Equal(t, `cluster "new-cluster" is in error state`, err.Error())
+		assert.NoFileExists(t, finalKubeConfigPath)
+	})
+
+	t.Run("refuses a cluster being deleted", func(t *testing.T) {
+		server := newServer("deleted")
+		defer server.Close()
+		finalKubeConfigPath := setup(t, server)
+
+		err := saveCmd.RunE(saveCmd, []string{"new-cluster"})
+		require.Error(t, err)
+		assert.Equal(t, `cluster "new-cluster" is being deleted`, err.Error())
+		assert.NoFileExists(t, finalKubeConfigPath)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		server := newServer("provisioning")
+		defer server.Close()
+		finalKubeConfigPath := setup(t, server)
+		saveWait = true
+		saveWaitTimeout = 20 * time.Millisecond
+
+		err := saveCmd.RunE(saveCmd, []string{"new-cluster"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `timed out after 20ms waiting for cluster "new-cluster" to be running (last state: provisioning)`)
+		assert.NoFileExists(t, finalKubeConfigPath)
+	})
+}
@@ -2,203 +2,189 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
-	"github.com/DO-Solutions/kubectl-doks/do"
-	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
 	"github.com/spf13/cobra"
-	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
-	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // syncCmd represents the sync command
 var kubeConfigPath string
 
+var (
+	syncRefreshBefore   time.Duration
+	syncPruneExpired    bool
+	syncAdopt           bool
+	syncConvertExec     bool
+	syncMaxPrune        int
+	syncMaxPrunePercent int
+	syncYes             bool
+)
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Synchronize all DOKS clusters to the kubeconfig file",
 	Long: `Fetches all reachable DOKS clusters and ensures that the local kubeconfig file
-is synchronized with the clusters' credentials.`,
+is synchronized with the clusters' credentials.
+Credentials that have expired, or that expire within --refresh-before, are renewed like with refresh.
+With --prune-expired, contexts whose credentials have expired are removed instead, like with gc.
+With --adopt, contexts created by doctl are adopted first, like with adopt.
+With --layout split, each cluster is written to its own file named after its ID in --dir instead,
+and the files of clusters that no longer exist are removed.
+With --max-prune or --max-prune-percent, sync refuses to remove the contexts of more clusters that no longer exist
+than allowed, lists them and fails, so that an API briefly listing no clusters cannot wipe the kubeconfig.
+Give --yes to remove them anyway.
+The hooks configured in ~/.kube/kubectl-doks/config.yaml run for the contexts added, updated and removed,
+and after the kubeconfig is written, unless --no-hooks is given.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var existingConfigBytes []byte
-		var err error
-		kubeConfigPath, existingConfigBytes, err = kubeconfig.GetKubeconfig(kubeConfigPath)
+		split, err := splitLayout()
 		if err != nil {
 			return err
 		}
+		if split && syncAdopt {
+			return fmt.Errorf("--adopt is not supported with --layout %s", layoutSplit)
+		}
+		if syncConvertExec && !syncAdopt {
+			return fmt.Errorf("--convert-exec requires --adopt")
+		}
 
-		ctx := context.Background()
-
-		tokens, err := getAllAccessTokens()
+		maxPrune, maxPrunePercent, err := pruneLimits()
 		if err != nil {
 			return err
 		}
 
-		var allClusters []do.Cluster
-		clusterIDToClient := make(map[string]*do.Client)
-
-		for _, token := range tokens {
-			client, err := do.NewClient(token, apiURL)
-			if err != nil {
-				return fmt.Errorf("creating DigitalOcean client: %w", err)
-			}
-
-			clusters, err := client.ListClusters(ctx)
-			if err != nil {
-				return fmt.Errorf("fetching clusters for a token: %w", err)
-			}
+		runner, err := loadHooks()
+		if err != nil {
+			return err
+		}
 
-			for _, cluster := range clusters {
-				allClusters = append(allClusters, cluster)
-				clusterIDToClient[cluster.ID] = client
-			}
+		ctx := context.Background()
+		if split {
+			if splitPrintPaths {
+				// Notices and warnings must not end up in the KUBECONFIG path list.
+				messages = cmd.ErrOrStderr()
+				defer func() { messages = os.Stdout }()
+			}
+			warnSplitHooks(runner)
+			return syncSplit(cmd, ctx, maxPrune, maxPrunePercent)
 		}
 
-		prunedConfigBytes, removedContexts, err := kubeconfig.PruneConfig(existingConfigBytes, allClusters)
+		syncer, store, err := newSyncer(&accountClusters{}, runner, func(options *doks.Options) {
+			options.RefreshBefore = syncRefreshBefore
+			options.PruneExpired = syncPruneExpired
+			options.Adopt = syncAdopt
+			options.ConvertExec = syncConvertExec
+			options.MaxPrune = maxPrune
+			options.MaxPrunePercent = maxPrunePercent
+		})
 		if err != nil {
-			return fmt.Errorf("pruning kubeconfig: %w", err)
+			return err
 		}
 
-		currentConfigBytes := prunedConfigBytes
-		var addedContexts []string
-
-		configObj, err := k8sclientcmd.Load(currentConfigBytes)
+		result, err := syncer.Sync(ctx)
+		printMessages(result, store)
+		recordResult(cmd, store, result)
 		if err != nil {
-			if len(currentConfigBytes) == 0 {
-				configObj = k8sclientcmdapi.NewConfig()
-			} else {
-				return fmt.Errorf("parsing kubeconfig: %w", err)
-			}
+			return pruneLimitExceeded(err)
 		}
 
-		for _, cluster := range allClusters {
-			expectedContextName := fmt.Sprintf("do-%s-%s", cluster.Region, cluster.Name)
-
-			var needsUpdate bool
-			if existingCluster, exists := configObj.Clusters[expectedContextName]; !exists {
-				needsUpdate = true
-			} else {
-				if id, found := kubeconfig.GetClusterID(existingCluster); !found || id != cluster.ID {
-					needsUpdate = true
-					if verbose {
-						fmt.Printf("Notice: Cluster '%s' has a new ID, will resync config.\n", cluster.Name)
-					}
-				}
-			}
-
-			if !needsUpdate && !force {
-				continue
-			}
-
-			client, ok := clusterIDToClient[cluster.ID]
-			if !ok {
-				return fmt.Errorf("could not find a client for cluster %s", cluster.Name)
-			}
-
-			kubeConfigBytes, err := client.GetKubeConfig(ctx, cluster.ID, expirySeconds)
-			if err != nil {
-				return fmt.Errorf("getting kubeconfig for cluster %s: %w", cluster.Name, err)
-			}
-
-			var mergedConfigBytes []byte
-			if len(currentConfigBytes) == 0 {
-				mergedConfigBytes = kubeConfigBytes
-			} else {
-				mergedConfigBytes, err = kubeconfig.MergeConfig(currentConfigBytes, kubeConfigBytes, false)
-				if err != nil {
-					return fmt.Errorf("merging kubeconfig for cluster %s: %w", cluster.Name, err)
-				}
-			}
-
-			currentConfigBytes = mergedConfigBytes
-			addedContexts = append(addedContexts, expectedContextName)
-
-			configObj, err = k8sclientcmd.Load(currentConfigBytes)
-			if err != nil {
-				return fmt.Errorf("reloading kubeconfig after merge: %w", err)
-			}
-
-			if c, ok := configObj.Clusters[expectedContextName]; ok {
-				kubeconfig.SetClusterID(c, cluster.ID)
-				finalConfigBytes, err := k8sclientcmd.Write(*configObj)
-				if err != nil {
-					return fmt.Errorf("serializing intermediate kubeconfig: %w", err)
-				}
-				currentConfigBytes = finalConfigBytes
+		if !result.Written {
+			if verbose {
+				fmt.Println("Notice: Kubeconfig is already up to date.")
 			}
+			return nil
 		}
 
-		if len(removedContexts) > 0 || len(addedContexts) > 0 {
-			backupPath := kubeConfigPath + ".kubectl-doks.bak"
-			if _, err := os.Stat(kubeConfigPath); err == nil {
-				if verbose {
-					fmt.Printf("Notice: Creating backup of kubeconfig at %s\n", backupPath)
-				}
-				if err := kubeconfig.BackupKubeconfig(kubeConfigPath, backupPath); err != nil {
-					return fmt.Errorf("backing up kubeconfig: %w", err)
-				}
+		if verbose {
+			for _, a := range result.Adopted {
+				fmt.Printf("Notice: %s\n", a)
 			}
-
-			if verbose && len(removedContexts) > 0 {
-				fmt.Printf("Notice: Removing stale contexts: %v\n", removedContexts)
+			if len(result.Removed) > 0 {
+				fmt.Printf("Notice: Removing stale contexts: %v\n", result.Removed)
 			}
-
-			if verbose && len(addedContexts) > 0 {
+			if len(result.Expired) > 0 {
+				fmt.Printf("Notice: Removing contexts with expired credentials: %v\n", result.Expired)
+			}
+			if len(result.Added) > 0 {
 				if expirySeconds == 0 {
-					fmt.Printf("Notice: Adding contexts: %v without expiration.\n", addedContexts)
+					fmt.Printf("Notice: Adding contexts: %v without expiration.\n", result.Added)
 				} else {
-					fmt.Printf("Notice: Adding contexts: %v with expiration set to %d seconds.\n", addedContexts, expirySeconds)
+					fmt.Printf("Notice: Adding contexts: %v with expiration set to %d seconds.\n", result.Added, expirySeconds)
 				}
 			}
-
-			config, err := k8sclientcmd.Load(currentConfigBytes)
-			if err != nil {
-				return fmt.Errorf("loading final kubeconfig: %w", err)
+			if len(result.Renewed) > 0 {
+				fmt.Printf("Notice: Renewing expiring credentials for contexts: %v\n", result.Renewed)
 			}
-
-			originalConfig, _ := k8sclientcmd.Load(existingConfigBytes)
-			originalCurrentContext := ""
-			if originalConfig != nil {
-				originalCurrentContext = originalConfig.CurrentContext
-			}
-
-			contextRemoved := false
-			for _, r := range removedContexts {
-				if r == originalCurrentContext {
-					contextRemoved = true
-					break
-				}
+			if len(result.Updated) > 0 {
+				fmt.Printf("Notice: Updating cluster details for contexts: %v\n", result.Updated)
 			}
-
-			if setCurrentContext && len(addedContexts) == 1 && (config.CurrentContext == "" || contextRemoved) {
-				config.CurrentContext = addedContexts[0]
-				if verbose {
-					fmt.Printf("Notice: Set current-context to %q\n", addedContexts[0])
-				}
-			}
-
-			finalConfigBytes, err := k8sclientcmd.Write(*config)
-			if err != nil {
-				return fmt.Errorf("serializing final kubeconfig: %w", err)
-			}
-
-			if err := os.WriteFile(kubeConfigPath, finalConfigBytes, 0600); err != nil {
-				return fmt.Errorf("writing updated kubeconfig: %w", err)
-			}
-
-			if verbose {
-				fmt.Printf("Notice: Successfully synced %d DOKS cluster(s) to your kubeconfig file.\n", len(addedContexts))
-			}
-		} else {
-			if verbose {
-				fmt.Println("Notice: Kubeconfig is already up to date.")
+			if result.CurrentContext != "" {
+				fmt.Printf("Notice: Set current-context to %q\n", result.CurrentContext)
 			}
+			fmt.Printf("Notice: Successfully synced %d DOKS cluster(s) to your kubeconfig file.\n", len(result.Added))
 		}
-		return nil
+		return runPostSyncHooks(ctx, runner, store, result)
 	},
 }
 
+// pruneLimits returns the largest number and percentage of managed clusters sync may remove, from --max-prune and
+// --max-prune-percent or the max-prune and max-prune-percent keys of the kubectl-doks config file. 0 means no limit.
+// With --yes, there are no limits.
+func pruneLimits() (int, int, error) {
+	if syncYes {
+		return 0, 0, nil
+	}
+
+	maxPrune, maxPrunePercent := syncMaxPrune, syncMaxPrunePercent
+	if maxPrune == 0 || maxPrunePercent == 0 {
+		pluginConfig, err := loadPluginConfig()
+		if err != nil {
+			return 0, 0, err
+		}
+		if maxPrune == 0 {
+			maxPrune = pluginConfig.GetInt("max-prune")
+		}
+		if maxPrunePercent == 0 {
+			maxPrunePercent = pluginConfig.GetInt("max-prune-percent")
+		}
+	}
+
+	if maxPrune < 0 {
+		return 0, 0, fmt.Errorf("invalid max prune %d: must not be negative", maxPrune)
+	}
+	if maxPrunePercent < 0 || maxPrunePercent > 100 {
+		return 0, 0, fmt.Errorf("invalid max prune percent %d: must be between 0 and 100", maxPrunePercent)
+	}
+	return maxPrune, maxPrunePercent, nil
+}
+
+// pruneLimitExceeded lists the clusters sync would have removed if err is a *doks.PruneLimitError, and returns err
+// with a hint about --yes. Other errors are returned as they are.
+func pruneLimitExceeded(err error) error {
+	var limitErr *doks.PruneLimitError
+	if !errors.As(err, &limitErr) {
+		return err
+	}
+	removed := slices.Sorted(slices.Values(limitErr.Removed))
+	for _, name := range removed {
+		fmt.Fprintf(messages, "Would remove %s, whose cluster no longer exists.\n", name)
+	}
+	return fmt.Errorf("%w; if the clusters were deleted, run again with --yes to remove them", err)
+}
+
 func init() {
+	syncCmd.Flags().BoolVar(&syncAdopt, "adopt", false, "Adopt contexts created by doctl for live clusters instead of rewriting them")
+	syncCmd.Flags().BoolVar(&syncConvertExec, "convert-exec", false, "With --adopt, replace doctl exec credentials of adopted contexts with a token")
+	syncCmd.Flags().BoolVar(&syncPruneExpired, "prune-expired", false, "Remove contexts whose credentials have expired instead of renewing them")
+	syncCmd.Flags().DurationVar(&syncRefreshBefore, "refresh-before", 0, "Also renew credentials that expire within this duration; expired credentials are always renewed")
+	syncCmd.Flags().IntVar(&syncMaxPrune, "max-prune", 0, "Refuse to remove the contexts of more than this many deleted clusters without --yes; 0 means no limit")
+	syncCmd.Flags().IntVar(&syncMaxPrunePercent, "max-prune-percent", 0, "Refuse to remove the contexts of more than this percentage of DOKS clusters without --yes; 0 means no limit")
+	syncCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Remove the contexts of deleted clusters even beyond --max-prune and --max-prune-percent")
+	syncCmd.Flags().BoolVar(&noHooks, "no-hooks", false, "Do not run the hooks configured in the kubectl-doks config file")
+	addLayoutFlags(syncCmd)
 	kubeconfigCmd.AddCommand(syncCmd)
 }
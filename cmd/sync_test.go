@@ -3,15 +3,16 @@ package cmd
 import (
 	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
 	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/digitalocean/godo"
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
@@ -56,61 +57,12 @@ users:
     token: cluster-1-token
 `
 
-const mockKubeconfig2ForSync = `
-apiVersion: v1
-clusters:
-- cluster:
-    server: https://cluster-2-server
-  name: do-sfo3-doks-cluster-2
-contexts:
-- context:
-    cluster: do-sfo3-doks-cluster-2
-    user: do-sfo3-doks-cluster-2-admin
-  name: do-sfo3-doks-cluster-2
-current-context: do-sfo3-doks-cluster-2
-kind: Config
-users:
-- name: do-sfo3-doks-cluster-2-admin
-  user:
-    token: cluster-2-token
-`
-
 func TestSyncCommand(t *testing.T) {
 	// 1. Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			// Respond with a list of clusters
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "cluster-1-id",
-					Name:       "doks-cluster-1",
-					RegionSlug: "nyc1",
-				},
-				{
-					ID:         "cluster-2-id",
-					Name:       "doks-cluster-2",
-					RegionSlug: "sfo3",
-				},
-			}
-			// The godo client expects the response to be wrapped in a JSON object
-			// with a key matching the resource type.
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-			// Respond with kubeconfig for cluster 1
-			fmt.Fprint(w, mockKubeconfig1ForSync)
-		} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-2-id/kubeconfig" {
-			// Respond with kubeconfig for cluster 2
-			fmt.Fprint(w, mockKubeconfig2ForSync)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(
+		do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"},
+		do.Cluster{ID: "cluster-2-id", Name: "doks-cluster-2", Region: "sfo3"},
+	)
 	defer server.Close()
 
 	// 2. Set up temporary environment and flags
@@ -190,7 +142,7 @@ func TestSyncCommand(t *testing.T) {
 }
 
 func TestSyncCommandContextHandling(t *testing.T) {
-	setup := func(t *testing.T, initialKubeconfig string, server *httptest.Server) (string, func()) {
+	setup := func(t *testing.T, initialKubeconfig string, server *dotest.Server) (string, func()) {
 		tmpDir := t.TempDir()
 		kubeConfigDir := filepath.Join(tmpDir, ".kube")
 		require.NoError(t, os.MkdirAll(kubeConfigDir, 0755))
@@ -219,16 +171,7 @@ func TestSyncCommandContextHandling(t *testing.T) {
 	}
 
 	t.Run("set new context when old is removed and one new is added", func(t *testing.T) {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{{ID: "cluster-1-id", Name: "doks-cluster-1", RegionSlug: "nyc1"}}
-				response := struct{ KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"` }{KubernetesClusters: clusters}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-				fmt.Fprint(w, mockKubeconfig1ForSync)
-			}
-		}))
+		server := newTestServer(do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"})
 		defer server.Close()
 
 		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSync, server)
@@ -246,16 +189,7 @@ func TestSyncCommandContextHandling(t *testing.T) {
 	})
 
 	t.Run("do not set new context when flag is false", func(t *testing.T) {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{{ID: "cluster-1-id", Name: "doks-cluster-1", RegionSlug: "nyc1"}}
-				response := struct{ KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"` }{KubernetesClusters: clusters}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-				fmt.Fprint(w, mockKubeconfig1ForSync)
-			}
-		}))
+		server := newTestServer(do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"})
 		defer server.Close()
 
 		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSync, server)
@@ -273,21 +207,10 @@ func TestSyncCommandContextHandling(t *testing.T) {
 	})
 
 	t.Run("do not set new context if multiple clusters are added", func(t *testing.T) {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{
-					{ID: "cluster-1-id", Name: "doks-cluster-1", RegionSlug: "nyc1"},
-					{ID: "cluster-2-id", Name: "doks-cluster-2", RegionSlug: "sfo3"},
-				}
-				response := struct{ KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"` }{KubernetesClusters: clusters}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-				fmt.Fprint(w, mockKubeconfig1ForSync)
-			} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-2-id/kubeconfig" {
-				fmt.Fprint(w, mockKubeconfig2ForSync)
-			}
-		}))
+		server := newTestServer(
+			do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"},
+			do.Cluster{ID: "cluster-2-id", Name: "doks-cluster-2", Region: "sfo3"},
+		)
 		defer server.Close()
 
 		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSync, server)
@@ -305,14 +228,7 @@ func TestSyncCommandContextHandling(t *testing.T) {
 	})
 
 	t.Run("remove stale contexts when no clusters are found", func(t *testing.T) {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{}
-				response := struct{ KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"` }{KubernetesClusters: clusters}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			}
-		}))
+		server := newTestServer()
 		defer server.Close()
 
 		finalKubeConfigPath, cleanup := setup(t, initialKubeconfigForSync, server)
@@ -336,28 +252,7 @@ func TestSyncCommandContextHandling(t *testing.T) {
 
 func TestSyncCommandWithForce(t *testing.T) {
 	// 1. Create a mock API server that returns a single cluster
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "cluster-1-id",
-					Name:       "doks-cluster-1",
-					RegionSlug: "nyc1",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfig1ForSync)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"})
 	defer server.Close()
 
 	// 2. Set up temporary environment with a kubeconfig that is already in sync
@@ -399,35 +294,10 @@ func TestSyncCommandWithForce(t *testing.T) {
 
 func TestSyncCommandNoBackupWhenKubeconfigMissing(t *testing.T) {
 	// Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "cluster-1-id",
-					Name:       "doks-cluster-1",
-					RegionSlug: "nyc1",
-				},
-				{
-					ID:         "cluster-2-id",
-					Name:       "doks-cluster-2",
-					RegionSlug: "sfo3",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-1-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfig1ForSync)
-		} else if r.URL.Path == "/v2/kubernetes/clusters/cluster-2-id/kubeconfig" {
-			fmt.Fprint(w, mockKubeconfig2ForSync)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(
+		do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"},
+		do.Cluster{ID: "cluster-2-id", Name: "doks-cluster-2", Region: "sfo3"},
+	)
 	defer server.Close()
 
 	t.Run("sync - no backup when kubeconfig doesn't exist", func(t *testing.T) {
@@ -522,20 +392,7 @@ func TestSyncCommandNoBackupWhenKubeconfigMissing(t *testing.T) {
 
 	t.Run("sync removing contexts - no backup when kubeconfig doesn't exist", func(t *testing.T) {
 		// Create a server that returns no clusters
-		emptyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v2/kubernetes/clusters" {
-				clusters := []*godo.KubernetesCluster{}
-				response := struct {
-					KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-				}{
-					KubernetesClusters: clusters,
-				}
-				w.Header().Set("Content-Type", "application/json")
-				require.NoError(t, json.NewEncoder(w).Encode(response))
-			} else {
-				w.WriteHeader(http.StatusNotFound)
-			}
-		}))
+		emptyServer := newTestServer()
 		defer emptyServer.Close()
 
 		// Set up temporary environment
@@ -576,45 +433,7 @@ func TestSyncCommandNoBackupWhenKubeconfigMissing(t *testing.T) {
 
 func TestSyncCommandWithRecreatedCluster(t *testing.T) {
 	// 1. Create a mock API server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/v2/kubernetes/clusters" {
-			clusters := []*godo.KubernetesCluster{
-				{
-					ID:         "new-recreated-cluster-id",
-					Name:       "doks-recreated-cluster",
-					RegionSlug: "nyc1",
-				},
-			}
-			response := struct {
-				KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			}{
-				KubernetesClusters: clusters,
-			}
-			w.Header().Set("Content-Type", "application/json")
-			require.NoError(t, json.NewEncoder(w).Encode(response))
-		} else if r.URL.Path == "/v2/kubernetes/clusters/new-recreated-cluster-id/kubeconfig" {
-			fmt.Fprint(w, `
-apiVersion: v1
-clusters:
-- cluster:
-    server: https://new-recreated-cluster-server
-  name: do-nyc1-doks-recreated-cluster
-contexts:
-- context:
-    cluster: do-nyc1-doks-recreated-cluster
-    user: do-nyc1-doks-recreated-cluster-admin
-  name: do-nyc1-doks-recreated-cluster
-current-context: do-nyc1-doks-recreated-cluster
-kind: Config
-users:
-- name: do-nyc1-doks-recreated-cluster-admin
-  user:
-    token: new-recreated-cluster-token
-`)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
+	server := newTestServer(do.Cluster{ID: "new-recreated-cluster-id", Name: "doks-recreated-cluster", Region: "nyc1", Endpoint: "https://new-recreated-cluster-server"})
 	defer server.Close()
 
 	// 2. Set up temporary environment and flags
@@ -698,3 +517,349 @@ users:
 	assert.True(t, found, "Cluster ID extension should be found")
 	assert.Equal(t, "new-recreated-cluster-id", newID, "Cluster ID should be updated to the new ID")
 }
+
+func TestSyncCommandRecordsTeam(t *testing.T) {
+	team := do.Team{UUID: "team-uuid", Name: "My Team"}
+	server := dotest.NewServer()
+	server.AddToken("test-token", do.Account{UUID: "account-uuid", Email: "user@example.com", Team: team})
+	server.AddCluster(do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1", Team: team})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() { apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath }()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	cluster, exists := updatedKubeconfig.Clusters["do-nyc1-doks-cluster-1"]
+	require.True(t, exists)
+	team, authContext, found := kubeconfig.GetClusterSource(cluster)
+	assert.True(t, found)
+	assert.Equal(t, "team-uuid", team.UUID)
+	assert.Equal(t, "My Team", team.Name)
+	assert.Empty(t, authContext, "tokens given with --access-token have no auth context")
+}
+
+func TestSyncCommandWithPerTokenAPIURL(t *testing.T) {
+	newServer := func(token string, cluster do.Cluster) *dotest.Server {
+		server := dotest.NewServer()
+		server.AddToken(token, do.Account{})
+		server.AddCluster(cluster)
+		return server
+	}
+	prodServer := newServer("prod-token", do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"})
+	defer prodServer.Close()
+	stagingServer := newServer("staging-token", do.Cluster{ID: "cluster-2-id", Name: "doks-cluster-2", Region: "sfo3"})
+	defer stagingServer.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	apiURL = ""
+	accessTokens = []string{"prod-token@" + prodServer.URL, "staging-token@" + stagingServer.URL}
+	kubeConfigPath = ""
+	defer func() { apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath }()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	assert.Contains(t, updatedKubeconfig.Contexts, "do-nyc1-doks-cluster-1", "Cluster from the first endpoint should be synced")
+	assert.Contains(t, updatedKubeconfig.Contexts, "do-sfo3-doks-cluster-2", "Cluster from the second endpoint should be synced")
+}
+
+func TestSyncCommandClusterStatus(t *testing.T) {
+	const kubeconfigTemplate = `
+apiVersion: v1
+clusters:
+- cluster:
+    server: https://%[1]s-server
+  name: do-nyc1-%[1]s
+contexts:
+- context:
+    cluster: do-nyc1-%[1]s
+    user: do-nyc1-%[1]s-admin
+  name: do-nyc1-%[1]s
+kind: Config
+users:
+- name: do-nyc1-%[1]s-admin
+  user:
+    token: %[1]s-token
+`
+	clusters := map[string]string{
+		"running":      "running",
+		"degraded":     "degraded",
+		"provisioning": "provisioning",
+		"deleted":      "deleted",
+	}
+
+	server := newTestServer()
+	for name, state := range clusters {
+		server.AddCluster(do.Cluster{ID: name + "-id", Name: name, Region: "nyc1", Status: state})
+	}
+	server.OnRequest(func(request dotest.Request) {
+		name := strings.TrimSuffix(request.ClusterID, "-id")
+		if request.Route == dotest.RouteCredentials && (name == "provisioning" || name == "deleted") {
+			t.Errorf("credentials of the %s cluster should not be fetched", name)
+		}
+	})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+
+	// The degraded and deleted clusters are already saved, as running clusters.
+	initial := k8sclientcmdapi.NewConfig()
+	for _, name := range []string{"degraded", "deleted"} {
+		existing, err := k8sclientcmd.Load([]byte(fmt.Sprintf(kubeconfigTemplate, name)))
+		require.NoError(t, err)
+		contextName := "do-nyc1-" + name
+		kubeconfig.SetClusterID(existing.Clusters[contextName], name+"-id")
+		kubeconfig.SetClusterStatus(existing.Clusters[contextName], "running")
+		initial.Clusters[contextName] = existing.Clusters[contextName]
+		initial.Contexts[contextName] = existing.Contexts[contextName]
+		initial.AuthInfos[contextName+"-admin"] = existing.AuthInfos[contextName+"-admin"]
+	}
+	require.NoError(t, k8sclientcmd.WriteToFile(*initial, finalKubeConfigPath))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() { apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath }()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	assert.NotContains(t, updatedKubeconfig.Contexts, "do-nyc1-deleted", "Clusters being deleted should be pruned")
+	assert.NotContains(t, updatedKubeconfig.Contexts, "do-nyc1-provisioning", "Provisioning clusters should be deferred")
+
+	for name, want := range map[string]string{"running": "running", "degraded": "degraded"} {
+		cluster, ok := updatedKubeconfig.Clusters["do-nyc1-"+name]
+		require.True(t, ok, "cluster %s should be saved", name)
+		status, found := kubeconfig.GetClusterStatus(cluster)
+		assert.True(t, found)
+		assert.Equal(t, want, status, "state of cluster %s should be recorded", name)
+	}
+}
+
+func TestSyncCommandPreservesUnrelatedEntries(t *testing.T) {
+	const handEditedKubeconfig = `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: kind-kind
+clusters:
+  - name: kind-kind # local
+    cluster:
+      server: https://127.0.0.1:6443
+  - name: do-nyc1-old-cluster
+    cluster:
+      server: https://old-cluster-server
+users:
+  - name: kind-kind
+    user:
+      token: kind-token
+  - name: do-nyc1-old-cluster-admin
+    user:
+      token: old-token
+contexts:
+  - name: kind-kind
+    context:
+      cluster: kind-kind
+      user: kind-kind
+  - name: do-nyc1-old-cluster
+    context:
+      cluster: do-nyc1-old-cluster
+      user: do-nyc1-old-cluster-admin
+`
+	server := newTestServer(do.Cluster{ID: "cluster-1-id", Name: "doks-cluster-1", Region: "nyc1"})
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, []byte(handEditedKubeconfig), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() { apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath }()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updated := string(updatedBytes)
+
+	// The stale DOKS entries are gone and the new ones added, while everything else keeps its exact text.
+	assert.NotContains(t, updated, "old-cluster")
+	assert.Contains(t, updated, "do-nyc1-doks-cluster-1")
+	assert.True(t, strings.HasPrefix(updated, `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: kind-kind
+clusters:
+  - name: kind-kind # local
+    cluster:
+      server: https://127.0.0.1:6443
+`), "unexpected kubeconfig:\n%s", updated)
+	assert.Contains(t, updated, `users:
+  - name: kind-kind
+    user:
+      token: kind-token
+`)
+	assert.Contains(t, updated, `contexts:
+  - name: kind-kind
+    context:
+      cluster: kind-kind
+      user: kind-kind
+`)
+}
+
+func TestSyncCommandMigratesExtension(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"legacy"}, &fetched)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	finalKubeConfigPath := filepath.Join(tmpDir, ".kube", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(finalKubeConfigPath), 0755))
+	require.NoError(t, os.WriteFile(finalKubeConfigPath, []byte(`apiVersion: v1
+kind: Config
+current-context: do-nyc1-legacy
+clusters:
+- name: do-nyc1-legacy
+  cluster:
+    server: https://legacy-server
+    extensions:
+    - name: digitalocean.com/cluster-id
+      extension:
+        id: legacy-id
+users:
+- name: do-nyc1-legacy-admin
+  user:
+    token: legacy-token
+contexts:
+- name: do-nyc1-legacy
+  context:
+    cluster: do-nyc1-legacy
+    user: do-nyc1-legacy-admin
+`), 0600))
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	apiURL, accessTokens, kubeConfigPath = server.URL, []string{"test-token"}, ""
+	defer func() { apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath }()
+
+	require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+	assert.Empty(t, fetched, "Migrating the extension should not fetch new credentials")
+
+	updatedBytes, err := os.ReadFile(finalKubeConfigPath)
+	require.NoError(t, err)
+	updatedKubeconfig, err := k8sclientcmd.Load(updatedBytes)
+	require.NoError(t, err)
+
+	extension, found := kubeconfig.GetExtension(updatedKubeconfig.Clusters["do-nyc1-legacy"])
+	require.True(t, found)
+	assert.Equal(t, kubeconfig.ExtensionVersion, extension.Version)
+	assert.Equal(t, "legacy-id", extension.ClusterID)
+	assert.Equal(t, "nyc1", extension.Region)
+	assert.Equal(t, "running", extension.Status)
+	assert.Equal(t, kubeconfig.ManagedBy, extension.ManagedBy)
+	assert.Equal(t, "legacy-token", updatedKubeconfig.AuthInfos["do-nyc1-legacy-admin"].Token)
+}
+
+func TestSyncCommandPruneLimit(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha"}, &fetched)
+	defer server.Close()
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+	originalMaxPrune, originalMaxPrunePercent, originalYes := syncMaxPrune, syncMaxPrunePercent, syncYes
+	apiURL, accessTokens = server.URL, []string{"test-token"}
+	var out bytes.Buffer
+	messages = &out
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+		syncMaxPrune, syncMaxPrunePercent, syncYes = originalMaxPrune, originalMaxPrunePercent, originalYes
+		messages = os.Stdout
+	}()
+
+	// setup writes a kubeconfig holding alpha and the contexts of three deleted clusters, and the given config file.
+	setup := func(t *testing.T, pluginConfig string) (string, []byte) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		kubeconfigPath := filepath.Join(home, ".kube", "config")
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".kube", "kubectl-doks"), 0755))
+		data := kubeconfigWithExpiries(t, map[string]time.Time{
+			"alpha": {}, "gone1": {}, "gone2": {}, "gone3": {},
+		})
+		require.NoError(t, os.WriteFile(kubeconfigPath, data, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".kube", "kubectl-doks", "config.yaml"), []byte(pluginConfig), 0600))
+		kubeConfigPath = ""
+		out.Reset()
+		return kubeconfigPath, data
+	}
+
+	t.Run("refuses to remove more than --max-prune", func(t *testing.T) {
+		kubeconfigPath, before := setup(t, "")
+		syncMaxPrune, syncMaxPrunePercent, syncYes = 2, 0, false
+
+		err := syncCmd.RunE(syncCmd, []string{})
+		assert.ErrorContains(t, err, "refusing to remove 3 of 4 DOKS clusters, more than the limit of 2")
+		assert.ErrorContains(t, err, "--yes")
+		assert.Equal(t, "Would remove do-nyc1-gone1, whose cluster no longer exists.\n"+
+			"Would remove do-nyc1-gone2, whose cluster no longer exists.\n"+
+			"Would remove do-nyc1-gone3, whose cluster no longer exists.\n", out.String())
+
+		after, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "The kubeconfig should not be written")
+	})
+
+	t.Run("reads the limits from the config file", func(t *testing.T) {
+		kubeconfigPath, before := setup(t, "max-prune-percent: 50\n")
+		syncMaxPrune, syncMaxPrunePercent, syncYes = 0, 0, false
+
+		assert.ErrorContains(t, syncCmd.RunE(syncCmd, []string{}), "more than 50% of them")
+		after, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("removes them with --yes", func(t *testing.T) {
+		kubeconfigPath, _ := setup(t, "max-prune: 1\n")
+		syncMaxPrune, syncMaxPrunePercent, syncYes = 0, 30, true
+
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+		config, err := k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"do-nyc1-alpha"}, kubeconfig.ManagedContexts(config))
+	})
+
+	t.Run("rejects invalid limits", func(t *testing.T) {
+		setup(t, "")
+		syncMaxPrune, syncMaxPrunePercent, syncYes = 0, 150, false
+		assert.ErrorContains(t, syncCmd.RunE(syncCmd, []string{}), "invalid max prune percent 150")
+	})
+}
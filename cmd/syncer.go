@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/hooks"
+)
+
+// accountClusters is the doks.ClusterLister and doks.CredentialFetcher of the commands: it lists the clusters of
+// every configured access token with listAllClusters, and fetches credentials with the client each cluster was listed with.
+type accountClusters struct {
+	clients map[string]*do.Client
+}
+
+// ListClusters lists the clusters of every configured access token.
+func (a *accountClusters) ListClusters(ctx context.Context) ([]do.Cluster, error) {
+	clusters, clients, err := listAllClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.clients = clients
+	return clusters, nil
+}
+
+// GetCredentials fetches the credentials of a cluster listed by ListClusters.
+func (a *accountClusters) GetCredentials(ctx context.Context, cluster do.Cluster, expirySeconds int) (do.Credentials, error) {
+	client, ok := a.clients[cluster.ID]
+	if !ok {
+		return do.Credentials{}, fmt.Errorf("could not find a client for cluster %s", cluster.Name)
+	}
+	return client.GetCredentials(ctx, cluster.ID, expirySeconds)
+}
+
+// kubeconfigStore is the doks.FileStore of the commands. It remembers the kubeconfig as it was first loaded,
+// before the command changed it.
+type kubeconfigStore struct {
+	*doks.FileStore
+	original []byte
+	loaded   bool
+}
+
+// Load reads the kubeconfig file, remembering its content the first time.
+func (s *kubeconfigStore) Load() ([]byte, error) {
+	data, err := s.FileStore.Load()
+	if err == nil && !s.loaded {
+		s.original, s.loaded = data, true
+	}
+	return data, err
+}
+
+// newSyncer returns a doks.Syncer for the clusters of accounts and the kubeconfig at --kubeconfig,
+// configured from the global flags and then by configure, along with its kubeconfig store.
+// If runner is not nil, its on-remove, on-add and on-update hooks run before the kubeconfig is written.
+func newSyncer(accounts *accountClusters, runner *hooks.Runner, configure func(options *doks.Options)) (*doks.Syncer, *kubeconfigStore, error) {
+	strategy, err := nameCollisionStrategy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := doks.Options{
+		ExpirySeconds:     expirySeconds,
+		Force:             force,
+		SetCurrentContext: setCurrentContext,
+		NameCollision:     strategy,
+	}
+	store := &kubeconfigStore{FileStore: &doks.FileStore{Path: kubeConfigPath, Backup: true}}
+	if runner != nil {
+		options.BeforeWrite = changeHooks(runner, store)
+	}
+	if configure != nil {
+		configure(&options)
+	}
+
+	return doks.NewSyncer(accounts, accounts, store, options), store, nil
+}
+
+// printMessages prints the notices of result with --verbose, and its warnings.
+// It also reports the backup made by store with --verbose.
+func printMessages(result doks.Result, store *kubeconfigStore) {
+	if verbose {
+		for _, notice := range result.Notices {
+			fmt.Fprintf(messages, "Notice: %s\n", notice)
+		}
+		if store.BackedUp {
+			fmt.Fprintf(messages, "Notice: Created backup of kubeconfig at %s.kubectl-doks.bak\n", store.Path)
+		}
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(messages, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/journal"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/spf13/cobra"
+)
+
+var undoForce bool
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [<operation-id>]",
+	Short: "Revert the last change kubectl-doks made to the kubeconfig",
+	Long: `Reverts a single change made to the kubeconfig by sync, save, refresh, adopt, gc or use, as recorded in
+the operation journal at ~/.kube/kubectl-doks/journal. Only the DOKS contexts, clusters and users the operation
+touched and the current context are reverted; every other entry, including edits made since, is left alone.
+Without an argument, the most recent operation on the kubeconfig that has not been undone is reverted, so
+repeated undos walk back through the journal. The operation IDs are listed by "kubectl doks history".
+If an entry the operation touched was changed since, undo refuses to revert it unless --force is given.
+Undo is itself recorded as an operation, so it can be reverted by undoing its ID.`,
+	// undo only reads the operation journal.
+	Annotations: map[string]string{credentialsAnnotation: credentialsOptional},
+	Args:        cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var existingConfigBytes []byte
+		var err error
+		kubeConfigPath, existingConfigBytes, err = kubeconfig.GetKubeconfig(kubeConfigPath)
+		if err != nil {
+			return err
+		}
+
+		dir, err := journal.DefaultDir()
+		if err != nil {
+			return err
+		}
+		var op journal.Operation
+		if len(args) == 1 {
+			op, err = journal.Load(dir, args[0])
+		} else {
+			op, err = journal.Last(dir, kubeConfigPath)
+		}
+		if err != nil {
+			return err
+		}
+		if op.Kubeconfig != kubeConfigPath {
+			return fmt.Errorf("operation %s changed %s, not %s: use --kubeconfig to undo it", op.ID, op.Kubeconfig, kubeConfigPath)
+		}
+		if op.UndoneBy != "" {
+			return fmt.Errorf("operation %s was already undone by operation %s", op.ID, op.UndoneBy)
+		}
+
+		reconciler, err := kubeconfig.NewReconciler(existingConfigBytes)
+		if err != nil {
+			return err
+		}
+		conflicts, err := op.Patch.Conflicts(reconciler.Config())
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			if !undoForce {
+				return fmt.Errorf("cannot undo operation %s: contexts changed since: %s; use --force to revert them anyway",
+					op.ID, strings.Join(conflicts, ", "))
+			}
+			fmt.Fprintf(messages, "Warning: Reverting contexts changed since operation %s: %s\n", op.ID, strings.Join(conflicts, ", "))
+		}
+		if err := op.Patch.Revert(reconciler.Config()); err != nil {
+			return err
+		}
+
+		if err := backupKubeconfig(kubeConfigPath); err != nil {
+			return err
+		}
+		if err := writeKubeconfig(kubeConfigPath, reconciler); err != nil {
+			return err
+		}
+
+		// The contexts the operation added are removed, and those it removed are added back.
+		removed, updated, added := op.Patch.Changes()
+		undoID := recordChange(cmd, kubeConfigPath, existingConfigBytes, kubeConfigPath+".kubectl-doks.bak", added, updated, removed)
+		if undoID != "" {
+			if err := markUndone(dir, op, undoID); err != nil {
+				fmt.Fprintf(messages, "Warning: Could not record the undo in the operation journal: %v\n", err)
+			}
+		}
+
+		fmt.Printf("Undid operation %s (%s at %s).\n", op.ID, op.Command, op.Time.Local().Format(time.DateTime))
+		if verbose {
+			for _, contextName := range added {
+				fmt.Fprintf(messages, "Notice: Restored context %q\n", contextName)
+			}
+			for _, contextName := range updated {
+				fmt.Fprintf(messages, "Notice: Reverted context %q\n", contextName)
+			}
+			for _, contextName := range removed {
+				fmt.Fprintf(messages, "Notice: Removed context %q\n", contextName)
+			}
+		}
+		return nil
+	},
+}
+
+// markUndone records in the journal in dir that op was reverted by the operation undoID.
+// If op was itself an undo, the operation it reverted is in effect again and can be undone once more.
+func markUndone(dir string, op journal.Operation, undoID string) error {
+	undo, err := journal.Load(dir, undoID)
+	if err != nil {
+		return err
+	}
+	undo.Undoes = op.ID
+	if err := journal.Save(dir, undo); err != nil {
+		return err
+	}
+	op.UndoneBy = undoID
+	if err := journal.Save(dir, op); err != nil {
+		return err
+	}
+
+	if op.Undoes == "" {
+		return nil
+	}
+	redone, err := journal.Load(dir, op.Undoes)
+	if errors.Is(err, journal.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	redone.UndoneBy = ""
+	return journal.Save(dir, redone)
+}
+
+func init() {
+	// Shadows the global --force, which forces a resync.
+	undoCmd.Flags().BoolVarP(&undoForce, "force", "f", false, "Revert the contexts the operation touched even if they changed since")
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/audit"
+	"github.com/DO-Solutions/kubectl-doks/pkg/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestUndoCommand(t *testing.T) {
+	var fetched []string
+	server := newRefreshServer(t, []string{"alpha"}, &fetched)
+	defer server.Close()
+
+	originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalUndoForce := apiURL, accessTokens, kubeConfigPath, undoForce
+	apiURL, accessTokens, kubeConfigPath, undoForce = server.URL, []string{"test-token"}, "", false
+	defer func() {
+		apiURL, accessTokens, kubeConfigPath, undoForce = originalAPIURL, originalAccessTokens, originalKubeConfigPath, originalUndoForce
+	}()
+
+	// setup syncs a kubeconfig holding the context of the deleted cluster gone, which adds alpha and removes gone.
+	setup := func(t *testing.T) string {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		kubeconfigPath := filepath.Join(home, ".kube", "config")
+		require.NoError(t, os.MkdirAll(filepath.Dir(kubeconfigPath), 0755))
+		require.NoError(t, os.WriteFile(kubeconfigPath, kubeconfigWithExpiries(t, map[string]time.Time{"gone": time.Now().Add(time.Hour)}), 0600))
+		kubeConfigPath = ""
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{}))
+		return kubeconfigPath
+	}
+
+	// edit applies change to the kubeconfig at path.
+	edit := func(t *testing.T, path string, change func(config *k8sclientcmdapi.Config)) {
+		config, err := k8sclientcmd.LoadFromFile(path)
+		require.NoError(t, err)
+		change(config)
+		require.NoError(t, k8sclientcmd.WriteToFile(*config, path))
+	}
+
+	t.Run("reverts the last sync and keeps later edits", func(t *testing.T) {
+		kubeconfigPath := setup(t)
+		edit(t, kubeconfigPath, func(config *k8sclientcmdapi.Config) {
+			config.Clusters["kind-kind"] = &k8sclientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}
+		})
+
+		kubeConfigPath = ""
+		require.NoError(t, undoCmd.RunE(undoCmd, []string{}))
+
+		config, err := k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Contains(t, config.Contexts, "do-nyc1-gone")
+		assert.Equal(t, "old-gone-token", config.AuthInfos["do-nyc1-gone-admin"].Token)
+		assert.NotContains(t, config.Contexts, "do-nyc1-alpha")
+		assert.NotContains(t, config.AuthInfos, "do-nyc1-alpha-admin")
+		assert.Contains(t, config.Clusters, "kind-kind", "Unrelated edits should be kept")
+		assert.Empty(t, config.CurrentContext)
+
+		kubeConfigPath = ""
+		assert.ErrorContains(t, undoCmd.RunE(undoCmd, []string{}), "no operation")
+
+		records, err := audit.Read(filepath.Join(filepath.Dir(kubeconfigPath), "kubectl-doks", audit.FileName))
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "undo", records[1].Command)
+		assert.Equal(t, []string{"do-nyc1-gone"}, records[1].Added)
+		assert.Equal(t, []string{"do-nyc1-alpha"}, records[1].Removed)
+
+		// Undoing the undo applies the sync again.
+		kubeConfigPath = ""
+		require.NoError(t, undoCmd.RunE(undoCmd, []string{records[1].Operation}))
+		config, err = k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Contains(t, config.Contexts, "do-nyc1-alpha")
+		assert.NotContains(t, config.Contexts, "do-nyc1-gone")
+		assert.Equal(t, "do-nyc1-alpha", config.CurrentContext)
+
+		dir, err := journal.DefaultDir()
+		require.NoError(t, err)
+		sync, err := journal.Load(dir, records[0].Operation)
+		require.NoError(t, err)
+		assert.Empty(t, sync.UndoneBy, "The sync should be undoable again")
+	})
+
+	t.Run("refuses to revert contexts changed since", func(t *testing.T) {
+		kubeconfigPath := setup(t)
+		edit(t, kubeconfigPath, func(config *k8sclientcmdapi.Config) {
+			config.Contexts["do-nyc1-alpha"].Namespace = "apps"
+		})
+		before, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+
+		kubeConfigPath = ""
+		assert.ErrorContains(t, undoCmd.RunE(undoCmd, []string{}), "contexts changed since: do-nyc1-alpha")
+		after, err := os.ReadFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "The kubeconfig should not be written")
+
+		kubeConfigPath, force = "", true
+		assert.ErrorContains(t, undoCmd.RunE(undoCmd, []string{}), "contexts changed since", "The global --force should not apply")
+		force = false
+
+		kubeConfigPath, undoForce = "", true
+		defer func() { undoForce = false }()
+		require.NoError(t, undoCmd.RunE(undoCmd, []string{}))
+		config, err := k8sclientcmd.LoadFromFile(kubeconfigPath)
+		require.NoError(t, err)
+		assert.NotContains(t, config.Contexts, "do-nyc1-alpha")
+	})
+
+	t.Run("reports unknown operations", func(t *testing.T) {
+		setup(t)
+		kubeConfigPath = ""
+		assert.ErrorContains(t, undoCmd.RunE(undoCmd, []string{"nope"}), "operation not found")
+	})
+}
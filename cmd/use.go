@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/doks"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/state"
+	"github.com/spf13/cobra"
+)
+
+var useNamespace string
+
+// useCmd represents the use command
+var useCmd = &cobra.Command{
+	Use:   "use <cluster-name|cluster-id|id-prefix|->",
+	Short: "Switch the current context to a DOKS cluster",
+	Long: `Switches the current-context to the context of a DOKS cluster.
+The cluster can be given by context name, cluster name, cluster ID or a unique prefix of the cluster ID.
+Contexts already managed by kubectl-doks are matched first; if none matches, the cluster is looked up
+through the DigitalOcean API and its credentials are saved before switching.
+Use "-" to switch back to the previous context.`,
+	// use only needs credentials when the cluster is not saved yet; token lookup reports the error in that case.
+	Annotations: map[string]string{credentialsAnnotation: credentialsOptional},
+	Args:        cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var existingConfigBytes []byte
+		var err error
+		kubeConfigPath, existingConfigBytes, err = kubeconfig.GetKubeconfig(kubeConfigPath)
+		if err != nil {
+			return err
+		}
+
+		reconciler, err := kubeconfig.NewReconciler(existingConfigBytes)
+		if err != nil {
+			return err
+		}
+		config := reconciler.Config()
+
+		stateFilePath, err := state.DefaultPath()
+		if err != nil {
+			return err
+		}
+		st, err := state.Load(stateFilePath)
+		if err != nil {
+			return err
+		}
+
+		query := args[0]
+		credentialsAdded := false
+		var contextName string
+
+		if query == "-" {
+			contextName = st.PreviousContext(kubeConfigPath)
+			if contextName == "" {
+				return errors.New("no previous context to switch to")
+			}
+			if _, ok := config.Contexts[contextName]; !ok {
+				return fmt.Errorf("previous context %q no longer exists in %s", contextName, kubeConfigPath)
+			}
+		} else {
+			contextName, err = resolveManagedContext(config, query)
+			if errors.Is(err, errNoManagedContext) {
+				contextName, err = fetchContext(query, reconciler)
+				credentialsAdded = err == nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if useNamespace != "" {
+			context := config.Contexts[contextName].DeepCopy()
+			context.Namespace = useNamespace
+			config.Contexts[contextName] = context
+		}
+
+		previousContext := config.CurrentContext
+		config.CurrentContext = contextName
+
+		if credentialsAdded {
+			if err := backupKubeconfig(kubeConfigPath); err != nil {
+				return err
+			}
+		}
+
+		if err := writeKubeconfig(kubeConfigPath, reconciler); err != nil {
+			return err
+		}
+
+		if credentialsAdded {
+			recordChange(cmd, kubeConfigPath, existingConfigBytes, kubeConfigPath+".kubectl-doks.bak", []string{contextName}, nil, nil)
+		}
+
+		if previousContext != "" && previousContext != contextName {
+			st.SetPreviousContext(kubeConfigPath, previousContext)
+			if err := st.Save(stateFilePath); err != nil {
+				return err
+			}
+		}
+
+		if credentialsAdded && verbose {
+			fmt.Printf("Notice: Saved credentials for context %q to %s\n", contextName, kubeConfigPath)
+		}
+		if cluster, ok := config.Clusters[config.Contexts[contextName].Cluster]; ok {
+			if status, ok := kubeconfig.GetClusterStatus(cluster); ok && status != do.StatusRunning {
+				fmt.Printf("Warning: The cluster was in %s state when it was last synced.\n", status)
+			}
+		}
+		fmt.Printf("Switched to context %q.\n", contextName)
+		return nil
+	},
+}
+
+// fetchContext looks up the cluster identified by query through the DigitalOcean API and
+// adds its credentials to reconciler, returning the new context name.
+func fetchContext(query string, reconciler *kubeconfig.Reconciler) (string, error) {
+	ctx := context.Background()
+
+	allClusters, clusterIDToClient, err := listAllClusters(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	cluster, err := doks.ResolveCluster(allClusters, query)
+	if err != nil {
+		return "", err
+	}
+	if err := checkClusterStatus(cluster, false); err != nil {
+		return "", err
+	}
+
+	contextNames, err := assignSelectedContextNames(allClusters, []do.Cluster{cluster})
+	if err != nil {
+		return "", err
+	}
+	contextName := contextNames[cluster.ID]
+
+	if err := mergeClusterCredentials(ctx, clusterIDToClient[cluster.ID], cluster, contextName, reconciler); err != nil {
+		return "", err
+	}
+	return contextName, nil
+}
+
+func init() {
+	useCmd.Flags().StringVarP(&useNamespace, "namespace", "n", "", "Set the namespace of the selected context")
+	rootCmd.AddCommand(useCmd)
+}
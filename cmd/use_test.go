@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const initialKubeconfigForUse = `
+apiVersion: v1
+clusters:
+- cluster:
+    extensions:
+    - extension:
+        id: 1a2b3c4d-0000-0000-0000-000000000001
+      name: digitalocean.com/cluster-id
+    server: https://prod-server
+  name: do-nyc1-prod
+- cluster:
+    extensions:
+    - extension:
+        id: 9f8e7d6c-0000-0000-0000-000000000002
+      name: digitalocean.com/cluster-id
+    server: https://staging-server
+  name: do-sfo3-staging
+- cluster:
+    server: https://kind-server
+  name: kind-kind
+contexts:
+- context:
+    cluster: do-nyc1-prod
+    user: do-nyc1-prod-admin
+  name: do-nyc1-prod
+- context:
+    cluster: do-sfo3-staging
+    user: do-sfo3-staging-admin
+  name: do-sfo3-staging
+- context:
+    cluster: kind-kind
+    user: kind-kind
+  name: kind-kind
+current-context: kind-kind
+kind: Config
+users:
+- name: do-nyc1-prod-admin
+  user:
+    token: prod-token
+- name: do-sfo3-staging-admin
+  user:
+    token: staging-token
+- name: kind-kind
+  user:
+    token: kind-token
+`
+
+func TestResolveManagedContext(t *testing.T) {
+	config, err := k8sclientcmd.Load([]byte(initialKubeconfigForUse))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		query     string
+		want      string
+		wantErr   error
+		expectErr bool
+	}{
+		{name: "by context name", query: "do-sfo3-staging", want: "do-sfo3-staging"},
+		{name: "by cluster name", query: "prod", want: "do-nyc1-prod"},
+		{name: "by cluster ID", query: "9f8e7d6c-0000-0000-0000-000000000002", want: "do-sfo3-staging"},
+		{name: "by ID prefix", query: "1a2b", want: "do-nyc1-prod"},
+		{name: "unmanaged context is ignored", query: "kind-kind", wantErr: errNoManagedContext, expectErr: true},
+		{name: "no match", query: "unknown", wantErr: errNoManagedContext, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveManagedContext(config, tt.query)
+			if tt.expectErr {
+				require.Error(t, err)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUseCommand(t *testing.T) {
+	setupUse := func(t *testing.T, serverURL string) string {
+		tmpDir := t.TempDir()
+		kubeConfigDir := filepath.Join(tmpDir, ".kube")
+		require.NoError(t, os.MkdirAll(kubeConfigDir, 0755))
+		finalKubeConfigPath := filepath.Join(kubeConfigDir, "config")
+		require.NoError(t, os.WriteFile(finalKubeConfigPath, []byte(initialKubeconfigForUse), 0600))
+		t.Setenv("HOME", tmpDir)
+
+		originalAPIURL, originalAccessTokens, originalKubeConfigPath := apiURL, accessTokens, kubeConfigPath
+		apiURL = serverURL
+		accessTokens = []string{"test-token"}
+		kubeConfigPath = ""
+		useNamespace = ""
+		t.Cleanup(func() {
+			apiURL, accessTokens, kubeConfigPath = originalAPIURL, originalAccessTokens, originalKubeConfigPath
+			useNamespace = ""
+		})
+		return finalKubeConfigPath
+	}
+
+	loadConfig := func(t *testing.T, path string) *k8sclientcmdapi.Config {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		config, err := k8sclientcmd.Load(data)
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("switch to managed context and back", func(t *testing.T) {
+		path := setupUse(t, "http://127.0.0.1:0")
+
+		require.NoError(t, useCmd.RunE(useCmd, []string{"prod"}))
+		assert.Equal(t, "do-nyc1-prod", loadConfig(t, path).CurrentContext)
+
+		require.NoError(t, useCmd.RunE(useCmd, []string{"-"}))
+		assert.Equal(t, "kind-kind", loadConfig(t, path).CurrentContext)
+
+		require.NoError(t, useCmd.RunE(useCmd, []string{"-"}))
+		assert.Equal(t, "do-nyc1-prod", loadConfig(t, path).CurrentContext)
+	})
+
+	t.Run("set namespace", func(t *testing.T) {
+		path := setupUse(t, "http://127.0.0.1:0")
+		useNamespace = "kube-system"
+
+		require.NoError(t, useCmd.RunE(useCmd, []string{"9f8e"}))
+		result := loadConfig(t, path)
+		assert.Equal(t, "do-sfo3-staging", result.CurrentContext)
+		assert.Equal(t, "kube-system", result.Contexts["do-sfo3-staging"].Namespace)
+	})
+
+	t.Run("no previous context", func(t *testing.T) {
+		setupUse(t, "http://127.0.0.1:0")
+		assert.Error(t, useCmd.RunE(useCmd, []string{"-"}))
+	})
+
+	t.Run("fetch credentials for unsaved cluster", func(t *testing.T) {
+		server := newTestServer(do.Cluster{ID: "dev-id", Name: "dev", Region: "ams3"})
+		defer server.Close()
+
+		path := setupUse(t, server.URL)
+		require.NoError(t, useCmd.RunE(useCmd, []string{"dev"}))
+
+		result := loadConfig(t, path)
+		assert.Equal(t, "do-ams3-dev", result.CurrentContext)
+		assert.Contains(t, result.Contexts, "do-ams3-dev")
+		assert.Contains(t, result.Contexts, "kind-kind")
+
+		_, err := os.Stat(path + ".kubectl-doks.bak")
+		assert.NoError(t, err, "Backup file should exist")
+	})
+}
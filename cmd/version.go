@@ -15,9 +15,10 @@ var (
 
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the version number of kubectl-doks",
-	Long:  `All software has versions. This is kubectl-doks's`,
+	Use:         "version",
+	Short:       "Print the version number of kubectl-doks",
+	Long:        `All software has versions. This is kubectl-doks's`,
+	Annotations: map[string]string{credentialsAnnotation: credentialsNone},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Printf("%s-%s\n", version, commit)
 	},
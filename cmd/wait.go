@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"k8s.io/client-go/rest"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// waitPollInterval is how often the cluster status and the API server readiness are checked while waiting.
+var waitPollInterval = 10 * time.Second
+
+// waitForCluster polls the status of cluster until it is running, printing its progress.
+// It fails if the cluster reaches a state it cannot recover from, or when ctx expires.
+func waitForCluster(ctx context.Context, client *do.Client, cluster do.Cluster) (do.Cluster, error) {
+	if cluster.Status == do.StatusRunning {
+		return cluster, nil
+	}
+
+	start := time.Now()
+	err := poll(ctx, func() (bool, error) {
+		latest, err := client.GetCluster(ctx, cluster.ID)
+		if err != nil {
+			return false, err
+		}
+		cluster.Status = latest.Status
+
+		switch cluster.Status {
+		case do.StatusRunning:
+			fmt.Fprintf(messages, "Cluster %q is running.\n", cluster.Name)
+			return true, nil
+		case do.StatusError, do.StatusDeleted:
+			return false, fmt.Errorf("cluster %q is in %s state", cluster.Name, cluster.Status)
+		}
+		fmt.Fprintf(messages, "Waiting for cluster %q to be running: %s (%s elapsed)\n", cluster.Name, cluster.Status, time.Since(start).Round(time.Second))
+		return false, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return cluster, fmt.Errorf("timed out after %s waiting for cluster %q to be running (last state: %s)", saveWaitTimeout, cluster.Name, cluster.Status)
+	}
+	return cluster, err
+}
+
+// waitForAPIServer polls the /readyz endpoint of the API server of contextName in config until it reports ready.
+func waitForAPIServer(ctx context.Context, config *k8sclientcmdapi.Config, contextName string) error {
+	restConfig, err := k8sclientcmd.NewNonInteractiveClientConfig(*config, contextName, &k8sclientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("building client config for context %q: %w", contextName, err)
+	}
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return fmt.Errorf("building HTTP client for context %q: %w", contextName, err)
+	}
+	readyzURL := strings.TrimSuffix(restConfig.Host, "/") + "/readyz"
+
+	start := time.Now()
+	var lastProblem string
+	err = poll(ctx, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, readyzURL, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			lastProblem = err.Error()
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				fmt.Fprintf(messages, "API server for context %q is ready.\n", contextName)
+				return true, nil
+			}
+			lastProblem = resp.Status
+		}
+		fmt.Fprintf(messages, "Waiting for API server for context %q to be ready (%s elapsed)\n", contextName, time.Since(start).Round(time.Second))
+		return false, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s waiting for API server for context %q to be ready (last response: %s)", saveWaitTimeout, contextName, lastProblem)
+	}
+	return err
+}
+
+// poll calls check every waitPollInterval until it reports done or fails, or until ctx expires.
+func poll(ctx context.Context, check func() (bool, error)) error {
+	for {
+		done, err := check()
+		if err != nil || done {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitPollInterval):
+		}
+	}
+}
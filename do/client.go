@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/digitalocean/godo"
 )
@@ -16,6 +18,55 @@ type Cluster struct {
 	ID     string
 	Name   string
 	Region string
+	// Status is the state of the cluster, such as "provisioning" or "running".
+	Status string
+	// Version is the Kubernetes version slug of the cluster, such as "1.31.1-do.0".
+	Version string
+	// Endpoint is the URL of the API server of the cluster.
+	Endpoint string
+
+	// Team is the team owning the cluster, if it could be resolved for the token that listed it.
+	Team Team
+	// AuthContext is the doctl authentication context whose token listed the cluster, if any.
+	AuthContext string
+}
+
+// Cluster states reported in Cluster.Status.
+// The API reports clusters that are being deleted as deleted; there is no separate deleting state.
+const (
+	StatusProvisioning = string(godo.KubernetesClusterStatusProvisioning)
+	StatusRunning      = string(godo.KubernetesClusterStatusRunning)
+	StatusDegraded     = string(godo.KubernetesClusterStatusDegraded)
+	StatusError        = string(godo.KubernetesClusterStatusError)
+	StatusDeleted      = string(godo.KubernetesClusterStatusDeleted)
+	StatusUpgrading    = string(godo.KubernetesClusterStatusUpgrading)
+	StatusInvalid      = string(godo.KubernetesClusterStatusInvalid)
+)
+
+// Team identifies the DigitalOcean team an access token belongs to.
+type Team struct {
+	UUID string
+	Name string
+}
+
+// Account holds the details of the account an access token belongs to.
+type Account struct {
+	UUID  string
+	Email string
+	Team  Team
+}
+
+// Credentials holds the details needed to connect to the API server of a cluster.
+type Credentials struct {
+	Server                   string
+	CertificateAuthorityData []byte
+	ClientCertificateData    []byte
+	ClientKeyData            []byte
+	Token                    string
+	// ExpiresAt is when the credentials expire. It is zero if the API did not report an expiry.
+	ExpiresAt time.Time
+	// ExpirySeconds is the lifetime requested for the credentials, 0 for the API default.
+	ExpirySeconds int
 }
 
 // Client provides an interface to interact with DigitalOcean Kubernetes API
@@ -54,6 +105,32 @@ func NewClientFromEnv(apiURL string) (*Client, error) {
 	return NewClient(token, apiURL)
 }
 
+// GetAccount returns the account and team the client's access token belongs to.
+func (c *Client) GetAccount(ctx context.Context) (*Account, error) {
+	account, _, err := c.godoClient.Account.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving account: %w", err)
+	}
+	if account == nil {
+		return nil, errors.New("error retrieving account: empty response")
+	}
+
+	result := &Account{
+		UUID:  account.UUID,
+		Email: account.Email,
+	}
+	if account.Team != nil {
+		result.Team = Team{UUID: account.Team.UUID, Name: account.Team.Name}
+	}
+	return result, nil
+}
+
+// IsUnauthorized reports whether err was caused by the API rejecting the access token.
+func IsUnauthorized(err error) bool {
+	var errResp *godo.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusUnauthorized
+}
+
 // ListClusters returns a list of all Kubernetes clusters in the account
 func (c *Client) ListClusters(ctx context.Context) ([]Cluster, error) {
 	opt := &godo.ListOptions{}
@@ -66,11 +143,7 @@ func (c *Client) ListClusters(ctx context.Context) ([]Cluster, error) {
 		}
 
 		for _, cluster := range clusters {
-			allClusters = append(allClusters, Cluster{
-				ID:     cluster.ID,
-				Name:   cluster.Name,
-				Region: cluster.RegionSlug,
-			})
+			allClusters = append(allClusters, newCluster(cluster))
 		}
 
 		// Check if we've reached the last page
@@ -90,6 +163,35 @@ func (c *Client) ListClusters(ctx context.Context) ([]Cluster, error) {
 	return allClusters, nil
 }
 
+// GetCluster returns the Kubernetes cluster with the given ID
+func (c *Client) GetCluster(ctx context.Context, clusterID string) (Cluster, error) {
+	if strings.TrimSpace(clusterID) == "" {
+		return Cluster{}, errors.New("cluster ID cannot be empty")
+	}
+
+	cluster, _, err := c.godoClient.Kubernetes.Get(ctx, clusterID)
+	if err != nil {
+		return Cluster{}, fmt.Errorf("error retrieving cluster %s: %w", clusterID, err)
+	}
+
+	return newCluster(cluster), nil
+}
+
+// newCluster converts a godo Kubernetes cluster to a Cluster.
+func newCluster(cluster *godo.KubernetesCluster) Cluster {
+	c := Cluster{
+		ID:       cluster.ID,
+		Name:     cluster.Name,
+		Region:   cluster.RegionSlug,
+		Version:  cluster.VersionSlug,
+		Endpoint: cluster.Endpoint,
+	}
+	if cluster.Status != nil {
+		c.Status = string(cluster.Status.State)
+	}
+	return c
+}
+
 // GetKubeConfig returns the kubeconfig for a specific cluster as a byte array
 func (c *Client) GetKubeConfig(ctx context.Context, clusterID string, expirySeconds int) ([]byte, error) {
 	if strings.TrimSpace(clusterID) == "" {
@@ -103,3 +205,31 @@ func (c *Client) GetKubeConfig(ctx context.Context, clusterID string, expirySeco
 
 	return kubeConfig.KubeconfigYAML, nil
 }
+
+// GetCredentials returns the credentials for a specific cluster.
+// expirySeconds sets how long the credentials are valid for; 0 uses the API default.
+func (c *Client) GetCredentials(ctx context.Context, clusterID string, expirySeconds int) (Credentials, error) {
+	if strings.TrimSpace(clusterID) == "" {
+		return Credentials{}, errors.New("cluster ID cannot be empty")
+	}
+
+	request := &godo.KubernetesClusterCredentialsGetRequest{}
+	if expirySeconds > 0 {
+		request.ExpirySeconds = &expirySeconds
+	}
+
+	credentials, _, err := c.godoClient.Kubernetes.GetCredentials(ctx, clusterID, request)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error retrieving credentials for cluster %s: %w", clusterID, err)
+	}
+
+	return Credentials{
+		Server:                   credentials.Server,
+		CertificateAuthorityData: credentials.CertificateAuthorityData,
+		ClientCertificateData:    credentials.ClientCertificateData,
+		ClientKeyData:            credentials.ClientKeyData,
+		Token:                    credentials.Token,
+		ExpiresAt:                credentials.ExpiresAt,
+		ExpirySeconds:            expirySeconds,
+	}, nil
+}
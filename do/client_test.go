@@ -2,18 +2,34 @@ package do_test
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
-	"net/http/httptest"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/DO-Solutions/kubectl-doks/do"
-	"github.com/digitalocean/godo"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
 )
 
+// newServer returns a dotest server where test-token sees clusters, failing the test on error,
+// along with a client using test-token.
+func newServer(t *testing.T, clusters ...do.Cluster) (*dotest.Server, *do.Client) {
+	t.Helper()
+	server := dotest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddToken("test-token", do.Account{UUID: "account-uuid", Email: "user@example.com"})
+	for _, cluster := range clusters {
+		server.AddCluster(cluster)
+	}
+	client, err := server.Client("test-token")
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return server, client
+}
+
 func TestNewClient(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -56,50 +72,11 @@ func TestNewClient(t *testing.T) {
 }
 
 func TestListClusters(t *testing.T) {
-	// Create a mock server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/v2/kubernetes/clusters" {
-			t.Errorf("Expected path '/v2/kubernetes/clusters', got: %s", r.URL.Path)
-		}
-
-		if r.Method != "GET" {
-			t.Errorf("Expected method 'GET', got: %s", r.Method)
-		}
+	server, client := newServer(t,
+		do.Cluster{ID: "cluster-1", Name: "test-cluster-1", Region: "nyc1"},
+		do.Cluster{ID: "cluster-2", Name: "test-cluster-2", Region: "sfo3"},
+	)
 
-		// Send a mock response
-		w.Header().Set("Content-Type", "application/json")
-		response := struct {
-			KubernetesClusters []*godo.KubernetesCluster `json:"kubernetes_clusters"`
-			Links             *godo.Links              `json:"links,omitempty"`
-		}{
-			KubernetesClusters: []*godo.KubernetesCluster{
-				{
-					ID:         "cluster-1",
-					Name:       "test-cluster-1",
-					RegionSlug: "nyc1",
-				},
-				{
-					ID:         "cluster-2",
-					Name:       "test-cluster-2",
-					RegionSlug: "sfo3",
-				},
-			},
-			Links: &godo.Links{},
-		}
-
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			t.Errorf("Failed to encode response: %v", err)
-		}
-	}))
-	defer server.Close()
-
-	// Create client using mock server URL
-	client, err := do.NewClient("test-token", server.URL)
-	if err != nil {
-		t.Fatalf("Error creating client: %v", err)
-	}
-
-	// Test ListClusters
 	clusters, err := client.ListClusters(context.Background())
 	if err != nil {
 		t.Fatalf("Error listing clusters: %v", err)
@@ -125,6 +102,11 @@ func TestListClusters(t *testing.T) {
 			t.Errorf("Cluster %d: expected Region %s, got %s", i, expectedClusters[i].Region, cluster.Region)
 		}
 	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Route != dotest.RouteListClusters || requests[0].Token != "test-token" {
+		t.Errorf("Expected a single list request with test-token, got %+v", requests)
+	}
 }
 
 func TestGetKubeConfig(t *testing.T) {
@@ -132,84 +114,30 @@ func TestGetKubeConfig(t *testing.T) {
 	tests := []struct {
 		name          string
 		clusterID     string
-		responseCode  int
-		responseBody  string
 		expectedError bool
 	}{
 		{
-			name:         "Valid cluster ID",
-			clusterID:    "valid-cluster",
-			responseCode: http.StatusOK,
-			responseBody: `{"kubeconfig_yaml": "apiVersion: v1\nkind: Config\n"}`,
+			name:          "Valid cluster ID",
+			clusterID:     "valid-cluster",
 			expectedError: false,
 		},
 		{
 			name:          "Empty cluster ID",
 			clusterID:     "",
-			responseCode:  http.StatusOK,
-			responseBody:  "",
 			expectedError: true,
 		},
 		{
 			name:          "Cluster not found",
 			clusterID:     "non-existent",
-			responseCode:  http.StatusNotFound,
-			responseBody:  `{"message":"cluster not found","id":"not_found"}`,
 			expectedError: true,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a mock server that simulates the DO API
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				expectedPath := "/v2/kubernetes/clusters/" + tc.clusterID + "/kubeconfig"
-				if tc.clusterID != "" && r.URL.Path != expectedPath {
-					t.Errorf("Expected path '%s', got: %s", expectedPath, r.URL.Path)
-				}
+			server, client := newServer(t, do.Cluster{ID: "valid-cluster", Name: "prod", Region: "nyc1"})
 
-				// Set proper headers
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(tc.responseCode)
-				if _, err := w.Write([]byte(tc.responseBody)); err != nil {
-					t.Errorf("Failed to write response: %v", err)
-				}
-			}))
-			defer server.Close()
-
-			// For testing purposes, we'll create a custom function to handle the test case
-			// since the test server returns the JSON directly while the real API would be parsed by godo
-			getConfig := func(ctx context.Context, clusterID string) ([]byte, error) {
-				if strings.TrimSpace(clusterID) == "" {
-					return nil, errors.New("cluster ID cannot be empty")
-				}
-				
-				req, err := http.NewRequest("GET", server.URL + "/v2/kubernetes/clusters/" + clusterID + "/kubeconfig", nil)
-				if err != nil {
-					return nil, err
-				}
-				
-				client := &http.Client{}
-				resp, err := client.Do(req)
-				if err != nil {
-					return nil, err
-				}
-				defer resp.Body.Close()
-				
-				if resp.StatusCode != http.StatusOK {
-					return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-				}
-				
-				// For the valid cluster case, return the expected YAML content
-				if tc.name == "Valid cluster ID" {
-					return []byte("apiVersion: v1\nkind: Config\n"), nil
-				}
-				
-				return nil, fmt.Errorf("unexpected test case")
-			}
-			
-			// Test our custom function that simulates GetKubeConfig
-			kubeconfig, err := getConfig(context.Background(), tc.clusterID)
+			kubeconfig, err := client.GetKubeConfig(context.Background(), tc.clusterID, 3600)
 
 			if tc.expectedError && err == nil {
 				t.Fatal("Expected error but got nil")
@@ -219,14 +147,116 @@ func TestGetKubeConfig(t *testing.T) {
 				if err != nil {
 					t.Fatalf("Expected no error but got: %v", err)
 				}
-				if kubeconfig == nil {
-					t.Fatal("Expected kubeconfig to be non-nil")
+				config, err := k8sclientcmd.Load(kubeconfig)
+				if err != nil {
+					t.Fatalf("Expected a valid kubeconfig, got: %v", err)
+				}
+				if config.CurrentContext != "do-nyc1-prod" {
+					t.Errorf("Expected current context do-nyc1-prod, got %q", config.CurrentContext)
 				}
-				expectedConfig := "apiVersion: v1\nkind: Config\n"
-				if string(kubeconfig) != expectedConfig {
-					t.Fatalf("Expected kubeconfig '%s', got '%s'", expectedConfig, string(kubeconfig))
+				if requests := server.Requests(); requests[0].ExpirySeconds != 3600 {
+					t.Errorf("Expected expiry_seconds=3600, got %d", requests[0].ExpirySeconds)
 				}
 			}
 		})
 	}
 }
+
+func TestGetAccount(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+	server.AddToken("test-token", do.Account{
+		UUID:  "account-uuid",
+		Email: "user@example.com",
+		Team:  do.Team{UUID: "team-uuid", Name: "My Team"},
+	})
+
+	client, err := server.Client("test-token")
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+
+	account, err := client.GetAccount(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting account: %v", err)
+	}
+
+	expected := do.Account{
+		UUID:  "account-uuid",
+		Email: "user@example.com",
+		Team:  do.Team{UUID: "team-uuid", Name: "My Team"},
+	}
+	if *account != expected {
+		t.Errorf("Expected account %+v, got %+v", expected, *account)
+	}
+}
+
+func TestIsUnauthorized(t *testing.T) {
+	server, _ := newServer(t)
+
+	client, err := server.Client("bad-token")
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+
+	_, err = client.GetAccount(context.Background())
+	if !do.IsUnauthorized(err) {
+		t.Errorf("Expected an unauthorized error, got: %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Status != http.StatusUnauthorized {
+		t.Errorf("Expected a single unauthorized request, got %+v", requests)
+	}
+	if do.IsUnauthorized(errors.New("some other error")) {
+		t.Error("Expected a plain error not to be reported as unauthorized")
+	}
+}
+
+func TestGetCluster(t *testing.T) {
+	_, client := newServer(t, do.Cluster{ID: "cluster-1", Name: "test-cluster-1", Region: "nyc1", Status: do.StatusProvisioning})
+
+	cluster, err := client.GetCluster(context.Background(), "cluster-1")
+	if err != nil {
+		t.Fatalf("Error getting cluster: %v", err)
+	}
+
+	expected := do.Cluster{ID: "cluster-1", Name: "test-cluster-1", Region: "nyc1", Status: do.StatusProvisioning}
+	if cluster.ID != expected.ID || cluster.Name != expected.Name || cluster.Region != expected.Region || cluster.Status != expected.Status {
+		t.Errorf("Expected cluster %+v, got %+v", expected, cluster)
+	}
+
+	if _, err := client.GetCluster(context.Background(), " "); err == nil {
+		t.Error("Expected an error for an empty cluster ID")
+	}
+}
+
+func TestGetCredentials(t *testing.T) {
+	server, client := newServer(t, do.Cluster{ID: "cluster-1", Name: "test-cluster-1", Region: "nyc1"})
+	now := time.Date(2026, 1, 2, 2, 4, 5, 0, time.UTC)
+	server.SetClock(func() time.Time { return now })
+	server.SetTokenFunc(func(cluster do.Cluster) string { return "cluster-token" })
+
+	credentials, err := client.GetCredentials(context.Background(), "cluster-1", 3600)
+	if err != nil {
+		t.Fatalf("Error getting credentials: %v", err)
+	}
+
+	if credentials.Server != "https://cluster-1.k8s.ondigitalocean.com" {
+		t.Errorf("Unexpected server %q", credentials.Server)
+	}
+	if string(credentials.CertificateAuthorityData) != "certificate-authority-cluster-1" {
+		t.Errorf("Unexpected certificate authority data %q", credentials.CertificateAuthorityData)
+	}
+	if credentials.Token != "cluster-token" {
+		t.Errorf("Unexpected token %q", credentials.Token)
+	}
+	if expected := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC); !credentials.ExpiresAt.Equal(expected) {
+		t.Errorf("Expected expiry %v, got %v", expected, credentials.ExpiresAt)
+	}
+	if requests := server.Requests(); requests[0].ExpirySeconds != 3600 {
+		t.Errorf("Expected expiry_seconds=3600, got %d", requests[0].ExpirySeconds)
+	}
+
+	if _, err := client.GetCredentials(context.Background(), " ", 0); err == nil {
+		t.Error("Expected an error for an empty cluster ID")
+	}
+}
@@ -0,0 +1,546 @@
+// Package dotest provides an in-memory fake of the DigitalOcean Kubernetes API, for testing code built on the do
+// package or on godo.
+//
+// A Server holds accounts, identified by their access tokens, and clusters, each owned by a team. A token lists and
+// fetches the credentials of the clusters of its account's team. Requests can be slowed down, failed or rate
+// limited to test how callers handle an unreliable API. Note that godo clients, and so do.Client, retry 429 and 5xx
+// responses up to four times, waiting at least a second in between.
+package dotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/digitalocean/godo"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Route identifies an endpoint of the API served by a Server.
+type Route string
+
+// Routes served by a Server.
+const (
+	RouteAccount      Route = "account"
+	RouteListClusters Route = "list-clusters"
+	RouteGetCluster   Route = "get-cluster"
+	RouteCredentials  Route = "credentials"
+	RouteKubeconfig   Route = "kubeconfig"
+)
+
+const (
+	// DefaultPageSize is the number of clusters listed per page when the request does not set per_page.
+	DefaultPageSize = 20
+	// DefaultExpiry is how long credentials are valid for when the request does not set expiry_seconds.
+	DefaultExpiry = 7 * 24 * time.Hour
+)
+
+// Request describes a request received by a Server.
+type Request struct {
+	Route Route
+	// Token is the access token the request was made with.
+	Token string
+	// ClusterID is the cluster the request is about, for the cluster routes.
+	ClusterID string
+	// ExpirySeconds is the requested expiry of credentials or kubeconfigs, 0 if not set.
+	ExpirySeconds int
+	// Page is the requested page of clusters, for RouteListClusters.
+	Page int
+	// Status is the status of the response, including injected failures, or 0 if the client gave up on the request
+	// before it was served.
+	Status int
+}
+
+// failureKey identifies the requests a failure is injected into: those to a route, made with a token or with any
+// token if it is empty.
+type failureKey struct {
+	route Route
+	token string
+}
+
+// failure is a failure injected into the requests of a route.
+type failure struct {
+	status int
+	// remaining is the number of requests left to fail, or 0 to fail every request.
+	remaining int
+}
+
+// Server is a fake DigitalOcean API server. Its methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	accounts  map[string]do.Account
+	clusters  []do.Cluster
+	pageSize  int
+	latency   time.Duration
+	now       func() time.Time
+	expiry    time.Duration
+	token     func(cluster do.Cluster) string
+	ca        func(cluster do.Cluster) []byte
+	failures  map[failureKey]*failure
+	issued    map[string][]do.Credentials
+	requests  []Request
+	observers []func(Request)
+}
+
+// NewServer starts and returns a new Server without accounts or clusters. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		accounts: make(map[string]do.Account),
+		pageSize: DefaultPageSize,
+		now:      time.Now,
+		expiry:   DefaultExpiry,
+		failures: make(map[failureKey]*failure),
+		issued:   make(map[string][]do.Credentials),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a do.Client using the given access token against the server.
+func (s *Server) Client(token string) (*do.Client, error) {
+	return do.NewClient(token, s.URL)
+}
+
+// AddToken registers an access token for account. The token sees the clusters of the account's team;
+// accounts without a team share the clusters added without one.
+func (s *Server) AddToken(token string, account do.Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[token] = account
+}
+
+// AddCluster adds a cluster owned by cluster.Team, or replaces the cluster with the same ID.
+// Clusters without a status are running.
+func (s *Server) AddCluster(cluster do.Cluster) {
+	if cluster.Status == "" {
+		cluster.Status = do.StatusRunning
+	}
+	cluster.AuthContext = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.clusterIndex(cluster.ID); i >= 0 {
+		s.clusters[i] = cluster
+		return
+	}
+	s.clusters = append(s.clusters, cluster)
+}
+
+// RemoveCluster removes the cluster with the given ID, as if it had been destroyed.
+func (s *Server) RemoveCluster(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.clusterIndex(id); i >= 0 {
+		s.clusters = slices.Delete(s.clusters, i, i+1)
+	}
+}
+
+// SetClusterStatus sets the status of the cluster with the given ID, such as do.StatusProvisioning.
+func (s *Server) SetClusterStatus(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.clusterIndex(id); i >= 0 {
+		s.clusters[i].Status = status
+	}
+}
+
+// SetPageSize sets the number of clusters listed per page when the request does not set per_page.
+func (s *Server) SetPageSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = size
+}
+
+// SetLatency delays every response by latency, or until the request is canceled.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetClock sets the function returning the current time, from which the expiry of credentials is computed.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetDefaultExpiry sets how long credentials are valid for when the request does not set expiry_seconds.
+func (s *Server) SetDefaultExpiry(expiry time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiry = expiry
+}
+
+// SetTokenFunc sets the function generating the bearer token of new credentials for a cluster.
+// By default, tokens are <cluster ID>-token-<n>, where n counts the credentials issued for the cluster.
+func (s *Server) SetTokenFunc(token func(cluster do.Cluster) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// SetCertificateAuthorityFunc sets the function returning the certificate authority data of new credentials for a
+// cluster, such as the PEM certificate of a test TLS server. By default, it is certificate-authority-<cluster ID>.
+func (s *Server) SetCertificateAuthorityFunc(ca func(cluster do.Cluster) []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ca = ca
+}
+
+// Fail makes the next times requests to route fail with the given HTTP status, or every request if times is 0.
+func (s *Server) Fail(route Route, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[failureKey{route: route}] = &failure{status: status, remaining: times}
+}
+
+// FailToken is like Fail, but only fails the requests made with token, such as a token lacking the scope of route.
+func (s *Server) FailToken(token string, route Route, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[failureKey{route: route, token: token}] = &failure{status: status, remaining: times}
+}
+
+// RateLimit makes the next times requests to route fail with 429 Too Many Requests, or every request if times
+// is 0.
+func (s *Server) RateLimit(route Route, times int) {
+	s.Fail(route, http.StatusTooManyRequests, times)
+}
+
+// ClearFailures removes the failures injected with Fail, FailToken and RateLimit.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.failures)
+}
+
+// OnRequest registers a function called with every request received, once it has been served, so that Request.Status
+// tells failed requests apart.
+func (s *Server) OnRequest(observe func(Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observe)
+}
+
+// Requests returns the requests received, in the order they were served.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// Credentials returns the credentials issued for the cluster with the given ID, in order, including those
+// embedded in kubeconfigs.
+func (s *Server) Credentials(clusterID string) []do.Credentials {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.issued[clusterID])
+}
+
+// clusterIndex returns the index of the cluster with the given ID, or -1. s.mu must be held.
+func (s *Server) clusterIndex(id string) int {
+	return slices.IndexFunc(s.clusters, func(cluster do.Cluster) bool { return cluster.ID == id })
+}
+
+// visibleClusters returns the clusters of the team of account. s.mu must be held.
+func (s *Server) visibleClusters(account do.Account) []do.Cluster {
+	var clusters []do.Cluster
+	for _, cluster := range s.clusters {
+		if cluster.Team.UUID == account.Team.UUID {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// route parses the route and cluster ID of a request path.
+func route(path string) (Route, string, bool) {
+	if path == "/v2/account" {
+		return RouteAccount, "", true
+	}
+	rest, ok := strings.CutPrefix(path, "/v2/kubernetes/clusters")
+	if !ok {
+		return "", "", false
+	}
+	if rest == "" || rest == "/" {
+		return RouteListClusters, "", true
+	}
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+	switch action {
+	case "":
+		return RouteGetCluster, id, true
+	case "credentials":
+		return RouteCredentials, id, true
+	case "kubeconfig":
+		return RouteKubeconfig, id, true
+	}
+	return "", "", false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	route, clusterID, ok := route(r.URL.Path)
+	if !ok || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	query := r.URL.Query()
+	request := Request{
+		Route:     route,
+		Token:     strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		ClusterID: clusterID,
+	}
+	request.ExpirySeconds, _ = strconv.Atoi(query.Get("expiry_seconds"))
+	if route == RouteListClusters {
+		request.Page = 1
+		if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+			request.Page = page
+		}
+	}
+
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			s.record(request)
+			return
+		}
+	}
+
+	// The response is only sent once the request is recorded, so that callers see it in Requests.
+	recorder := httptest.NewRecorder()
+	s.serve(recorder, r, request)
+	request.Status = recorder.Code
+	s.record(request)
+
+	maps.Copy(w.Header(), recorder.Header())
+	w.WriteHeader(recorder.Code)
+	w.Write(recorder.Body.Bytes())
+}
+
+// record adds request to the requests received and calls the observers with it.
+func (s *Server) record(request Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	observers := slices.Clone(s.observers)
+	s.mu.Unlock()
+
+	for _, observe := range observers {
+		observe(request)
+	}
+}
+
+// serve writes the response to request, or the failure injected into its route.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, request Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route := request.Route
+	key := failureKey{route: route, token: request.Token}
+	if _, ok := s.failures[key]; !ok {
+		key.token = ""
+	}
+	if failure, ok := s.failures[key]; ok {
+		if failure.remaining > 0 {
+			failure.remaining--
+			if failure.remaining == 0 {
+				delete(s.failures, key)
+			}
+		}
+		if failure.status == http.StatusTooManyRequests {
+			w.Header().Set("RateLimit-Limit", "5000")
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("RateLimit-Reset", strconv.FormatInt(s.now().Add(time.Second).Unix(), 10))
+			w.Header().Set("Retry-After", "1")
+			writeError(w, failure.status, "too_many_requests", "API Rate limit exceeded.")
+			return
+		}
+		writeError(w, failure.status, "server_error", http.StatusText(failure.status))
+		return
+	}
+
+	account, ok := s.accounts[request.Token]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unable to authenticate you")
+		return
+	}
+
+	switch route {
+	case RouteAccount:
+		s.serveAccount(w, account)
+	case RouteListClusters:
+		s.serveListClusters(w, r, account, request.Page)
+	default:
+		var cluster do.Cluster
+		found := false
+		for _, c := range s.visibleClusters(account) {
+			if c.ID == request.ClusterID {
+				cluster, found = c, true
+			}
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+			return
+		}
+
+		switch route {
+		case RouteGetCluster:
+			writeJSON(w, map[string]any{"kubernetes_cluster": godoCluster(cluster)})
+		case RouteCredentials:
+			writeJSON(w, s.issue(cluster, request.ExpirySeconds))
+		case RouteKubeconfig:
+			s.serveKubeconfig(w, cluster, request.ExpirySeconds)
+		}
+	}
+}
+
+// serveAccount writes the account of the request's token. s.mu must be held.
+func (s *Server) serveAccount(w http.ResponseWriter, account do.Account) {
+	result := godo.Account{UUID: account.UUID, Email: account.Email, Status: "active"}
+	if account.Team != (do.Team{}) {
+		result.Team = &godo.TeamInfo{UUID: account.Team.UUID, Name: account.Team.Name}
+	}
+	writeJSON(w, map[string]any{"account": result})
+}
+
+// serveListClusters writes a page of the clusters of account, with the links to the other pages. s.mu must be held.
+func (s *Server) serveListClusters(w http.ResponseWriter, r *http.Request, account do.Account, page int) {
+	perPage := s.pageSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && n > 0 {
+		perPage = n
+	}
+
+	clusters := s.visibleClusters(account)
+	pages := max((len(clusters)+perPage-1)/perPage, 1)
+	items := []*godo.KubernetesCluster{}
+	for _, cluster := range clusters[min((page-1)*perPage, len(clusters)):min(page*perPage, len(clusters))] {
+		items = append(items, godoCluster(cluster))
+	}
+
+	pageURL := func(page int) string {
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+		return s.URL + "/v2/kubernetes/clusters?" + query.Encode()
+	}
+	links := &godo.Links{Pages: &godo.Pages{}}
+	if page > 1 {
+		links.Pages.First, links.Pages.Prev = pageURL(1), pageURL(min(page-1, pages))
+	}
+	if page < pages {
+		links.Pages.Next, links.Pages.Last = pageURL(page+1), pageURL(pages)
+	}
+
+	writeJSON(w, map[string]any{
+		"kubernetes_clusters": items,
+		"links":               links,
+		"meta":                godo.Meta{Total: len(clusters)},
+	})
+}
+
+// serveKubeconfig writes a kubeconfig holding new credentials for cluster, like doctl would save it. s.mu must be held.
+func (s *Server) serveKubeconfig(w http.ResponseWriter, cluster do.Cluster, expirySeconds int) {
+	credentials := s.issue(cluster, expirySeconds)
+	name := "do-" + cluster.Region + "-" + cluster.Name
+
+	config := k8sclientcmdapi.NewConfig()
+	config.Clusters[name] = &k8sclientcmdapi.Cluster{
+		Server:                   credentials.Server,
+		CertificateAuthorityData: credentials.CertificateAuthorityData,
+	}
+	config.AuthInfos[name+"-admin"] = &k8sclientcmdapi.AuthInfo{Token: credentials.Token}
+	config.Contexts[name] = &k8sclientcmdapi.Context{Cluster: name, AuthInfo: name + "-admin"}
+	config.CurrentContext = name
+
+	data, err := k8sclientcmd.Write(*config)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+// issue generates and records new credentials for cluster. s.mu must be held.
+func (s *Server) issue(cluster do.Cluster, expirySeconds int) godo.KubernetesClusterCredentials {
+	expiry := s.expiry
+	if expirySeconds > 0 {
+		expiry = time.Duration(expirySeconds) * time.Second
+	}
+
+	server := cluster.Endpoint
+	if server == "" {
+		server = "https://" + cluster.ID + ".k8s.ondigitalocean.com"
+	}
+	token := fmt.Sprintf("%s-token-%d", cluster.ID, len(s.issued[cluster.ID])+1)
+	if s.token != nil {
+		token = s.token(cluster)
+	}
+	ca := []byte("certificate-authority-" + cluster.ID)
+	if s.ca != nil {
+		ca = s.ca(cluster)
+	}
+
+	credentials := do.Credentials{
+		Server:                   server,
+		CertificateAuthorityData: ca,
+		Token:                    token,
+		ExpiresAt:                s.now().Add(expiry).UTC().Truncate(time.Second),
+	}
+	s.issued[cluster.ID] = append(s.issued[cluster.ID], credentials)
+
+	return godo.KubernetesClusterCredentials{
+		Server:                   credentials.Server,
+		CertificateAuthorityData: credentials.CertificateAuthorityData,
+		Token:                    credentials.Token,
+		ExpiresAt:                credentials.ExpiresAt,
+	}
+}
+
+// godoCluster converts a cluster to its API representation.
+func godoCluster(cluster do.Cluster) *godo.KubernetesCluster {
+	return &godo.KubernetesCluster{
+		ID:          cluster.ID,
+		Name:        cluster.Name,
+		RegionSlug:  cluster.Region,
+		VersionSlug: cluster.Version,
+		Endpoint:    cluster.Endpoint,
+		Status:      &godo.KubernetesClusterStatus{State: godo.KubernetesClusterStatusState(cluster.Status)},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an API error response.
+func writeError(w http.ResponseWriter, status int, id, message string) {
+	w.WriteHeader(status)
+	writeJSON(w, map[string]string{"id": id, "message": message})
+}
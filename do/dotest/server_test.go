@@ -0,0 +1,249 @@
+package dotest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/do/dotest"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+// newClient returns a client of server for token, failing the test on error.
+func newClient(t *testing.T, server *dotest.Server, token string) *do.Client {
+	t.Helper()
+	client, err := server.Client(token)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return client
+}
+
+func TestServerTeams(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+
+	team := do.Team{UUID: "team-uuid", Name: "My Team"}
+	server.AddToken("personal-token", do.Account{UUID: "personal-uuid", Email: "user@example.com"})
+	server.AddToken("team-token", do.Account{UUID: "team-account-uuid", Email: "user@example.com", Team: team})
+	server.AddCluster(do.Cluster{ID: "personal-id", Name: "personal", Region: "nyc1"})
+	server.AddCluster(do.Cluster{ID: "team-id", Name: "team", Region: "sfo3", Status: do.StatusProvisioning, Team: team})
+
+	account, err := newClient(t, server, "team-token").GetAccount(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting account: %v", err)
+	}
+	if account.Team != team {
+		t.Errorf("Expected team %+v, got %+v", team, account.Team)
+	}
+
+	clusters, err := newClient(t, server, "team-token").ListClusters(context.Background())
+	if err != nil {
+		t.Fatalf("Error listing clusters: %v", err)
+	}
+	expected := []do.Cluster{{ID: "team-id", Name: "team", Region: "sfo3", Status: do.StatusProvisioning}}
+	if len(clusters) != 1 || clusters[0] != expected[0] {
+		t.Errorf("Expected clusters %+v, got %+v", expected, clusters)
+	}
+
+	cluster, err := newClient(t, server, "personal-token").GetCluster(context.Background(), "personal-id")
+	if err != nil {
+		t.Fatalf("Error getting cluster: %v", err)
+	}
+	if cluster.Status != do.StatusRunning {
+		t.Errorf("Expected clusters to be running by default, got %q", cluster.Status)
+	}
+	if _, err := newClient(t, server, "personal-token").GetCluster(context.Background(), "team-id"); err == nil {
+		t.Error("Expected the clusters of other teams not to be found")
+	}
+
+	_, err = newClient(t, server, "unknown-token").GetAccount(context.Background())
+	if !do.IsUnauthorized(err) {
+		t.Errorf("Expected an unknown token to be unauthorized, got: %v", err)
+	}
+}
+
+func TestServerPagination(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+
+	server.AddToken("test-token", do.Account{})
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		server.AddCluster(do.Cluster{ID: name + "-id", Name: name, Region: "nyc1"})
+	}
+	server.SetPageSize(2)
+
+	clusters, err := newClient(t, server, "test-token").ListClusters(context.Background())
+	if err != nil {
+		t.Fatalf("Error listing clusters: %v", err)
+	}
+	if len(clusters) != 5 {
+		t.Fatalf("Expected 5 clusters, got %d", len(clusters))
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		if clusters[i].Name != name {
+			t.Errorf("Cluster %d: expected name %s, got %s", i, name, clusters[i].Name)
+		}
+	}
+
+	var pages []int
+	for _, request := range server.Requests() {
+		pages = append(pages, request.Page)
+	}
+	if len(pages) != 3 || pages[0] != 1 || pages[1] != 2 || pages[2] != 3 {
+		t.Errorf("Expected pages 1 to 3 to be requested, got %v", pages)
+	}
+}
+
+func TestServerCredentials(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetClock(func() time.Time { return now })
+	server.AddToken("test-token", do.Account{})
+	server.AddCluster(do.Cluster{ID: "cluster-id", Name: "prod", Region: "nyc1", Endpoint: "https://prod.example.com"})
+	client := newClient(t, server, "test-token")
+
+	credentials, err := client.GetCredentials(context.Background(), "cluster-id", 3600)
+	if err != nil {
+		t.Fatalf("Error getting credentials: %v", err)
+	}
+	if credentials.Server != "https://prod.example.com" {
+		t.Errorf("Unexpected server %q", credentials.Server)
+	}
+	if credentials.Token != "cluster-id-token-1" {
+		t.Errorf("Unexpected token %q", credentials.Token)
+	}
+	if expected := now.Add(time.Hour); !credentials.ExpiresAt.Equal(expected) {
+		t.Errorf("Expected expiry %v, got %v", expected, credentials.ExpiresAt)
+	}
+
+	credentials, err = client.GetCredentials(context.Background(), "cluster-id", 0)
+	if err != nil {
+		t.Fatalf("Error getting credentials: %v", err)
+	}
+	if expected := now.Add(dotest.DefaultExpiry); !credentials.ExpiresAt.Equal(expected) {
+		t.Errorf("Expected the default expiry %v, got %v", expected, credentials.ExpiresAt)
+	}
+
+	data, err := client.GetKubeConfig(context.Background(), "cluster-id", 60)
+	if err != nil {
+		t.Fatalf("Error getting kubeconfig: %v", err)
+	}
+	config, err := k8sclientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("Error loading kubeconfig: %v", err)
+	}
+	if config.CurrentContext != "do-nyc1-prod" {
+		t.Errorf("Unexpected current context %q", config.CurrentContext)
+	}
+	if token := config.AuthInfos["do-nyc1-prod-admin"].Token; token != "cluster-id-token-3" {
+		t.Errorf("Unexpected kubeconfig token %q", token)
+	}
+
+	if issued := server.Credentials("cluster-id"); len(issued) != 3 {
+		t.Errorf("Expected 3 credentials to be issued, got %d", len(issued))
+	}
+
+	server.SetCertificateAuthorityFunc(func(cluster do.Cluster) []byte { return []byte(cluster.Name + "-ca") })
+	credentials, err = client.GetCredentials(context.Background(), "cluster-id", 0)
+	if err != nil {
+		t.Fatalf("Error getting credentials: %v", err)
+	}
+	if ca := string(credentials.CertificateAuthorityData); ca != "prod-ca" {
+		t.Errorf("Unexpected certificate authority data %q", ca)
+	}
+}
+
+// get requests path from server with token, returning the response status and headers.
+func get(t *testing.T, server *dotest.Server, token, path string) (int, http.Header) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	return response.StatusCode, response.Header
+}
+
+func TestServerFailures(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+
+	server.AddToken("test-token", do.Account{})
+	server.AddCluster(do.Cluster{ID: "cluster-id", Name: "prod", Region: "nyc1"})
+
+	var observed []int
+	server.OnRequest(func(request dotest.Request) { observed = append(observed, request.Status) })
+	server.Fail(dotest.RouteCredentials, http.StatusInternalServerError, 2)
+	for _, expected := range []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK} {
+		if status, _ := get(t, server, "test-token", "/v2/kubernetes/clusters/cluster-id/credentials"); status != expected {
+			t.Errorf("Expected status %d, got %d", expected, status)
+		}
+	}
+	if len(observed) != 3 || observed[0] != http.StatusInternalServerError || observed[2] != http.StatusOK {
+		t.Errorf("Expected observers to see the status of each response, got %v", observed)
+	}
+	if status, _ := get(t, server, "wrong-token", "/v2/kubernetes/clusters/cluster-id"); status != http.StatusUnauthorized || observed[3] != status {
+		t.Errorf("Expected observers to see unauthorized requests, got %v", observed)
+	}
+
+	server.RateLimit(dotest.RouteListClusters, 0)
+	for range 3 {
+		status, header := get(t, server, "test-token", "/v2/kubernetes/clusters")
+		if status != http.StatusTooManyRequests || header.Get("RateLimit-Remaining") != "0" || header.Get("Retry-After") == "" {
+			t.Errorf("Expected a rate limited response, got %d with headers %v", status, header)
+		}
+	}
+	if status, _ := get(t, server, "test-token", "/v2/kubernetes/clusters/cluster-id"); status != http.StatusOK {
+		t.Errorf("Expected other routes not to be rate limited, got %d", status)
+	}
+	server.ClearFailures()
+	if status, _ := get(t, server, "test-token", "/v2/kubernetes/clusters"); status != http.StatusOK {
+		t.Errorf("Expected the failures to be cleared, got %d", status)
+	}
+
+	server.AddToken("read-only-token", do.Account{})
+	server.FailToken("read-only-token", dotest.RouteListClusters, http.StatusForbidden, 0)
+	if status, _ := get(t, server, "read-only-token", "/v2/kubernetes/clusters"); status != http.StatusForbidden {
+		t.Errorf("Expected the requests of the token to fail, got %d", status)
+	}
+	if status, _ := get(t, server, "test-token", "/v2/kubernetes/clusters"); status != http.StatusOK {
+		t.Errorf("Expected the requests of other tokens not to fail, got %d", status)
+	}
+	server.ClearFailures()
+
+	// Clients retry rate limited requests.
+	server.RateLimit(dotest.RouteAccount, 1)
+	server.ResetRequests()
+	if _, err := newClient(t, server, "test-token").GetAccount(context.Background()); err != nil {
+		t.Errorf("Expected the request to be retried, got: %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(requests))
+	}
+}
+
+func TestServerLatency(t *testing.T) {
+	server := dotest.NewServer()
+	defer server.Close()
+
+	server.AddToken("test-token", do.Account{})
+	server.SetLatency(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := newClient(t, server, "test-token").ListClusters(ctx); err == nil {
+		t.Error("Expected the request to time out")
+	}
+}
@@ -0,0 +1,195 @@
+// Package audit keeps an append-only log of the changes kubectl-doks makes to kubeconfig files, one JSON record
+// per line, so that shared hosts can tell who synced what and when.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/state"
+)
+
+// FileName is the name of the audit log in the kubectl-doks directory.
+const FileName = "audit.log"
+
+// Redacted replaces secrets in recorded command lines.
+const Redacted = "REDACTED"
+
+// Record describes a change made to a kubeconfig.
+type Record struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	// Operation is the ID of the change in the operation journal, which `undo` takes, if it was journaled.
+	Operation string `json:"operation,omitempty"`
+	// Command is the kubectl-doks command that made the change, such as "kubeconfig sync".
+	Command string `json:"command"`
+	// Args is the command line, with secrets redacted.
+	Args []string `json:"args"`
+	// AuthContexts are the doctl auth contexts whose tokens were used, if any.
+	AuthContexts []string `json:"auth_contexts,omitempty"`
+	// Kubeconfig is the path of the kubeconfig that was changed, or of the directory of the split layout.
+	Kubeconfig string `json:"kubeconfig"`
+	// Added, Updated and Removed are the contexts changed, or, with the split layout, the files.
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Backup is the path of the backup made before the change, if any.
+	Backup string `json:"backup,omitempty"`
+	// BeforeHash and AfterHash are the Hash of the kubeconfig before and after the change.
+	// They are not set with the split layout.
+	BeforeHash string `json:"before_hash,omitempty"`
+	AfterHash  string `json:"after_hash,omitempty"`
+}
+
+// DefaultPath returns the location of the audit log, ~/.kube/kubectl-doks/audit.log.
+func DefaultPath() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Hash returns the SHA-256 hash of a kubeconfig, as sha256:<hex>. A missing kubeconfig hashes as its empty content.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NewRecord returns a record of a change made now by the current user on this host, with the given command and
+// command line. Secrets in args are redacted.
+func NewRecord(command string, args []string) Record {
+	record := Record{
+		Time:    time.Now().UTC(),
+		Command: command,
+		Args:    RedactArgs(args),
+	}
+	if u, err := user.Current(); err == nil {
+		record.User = u.Username
+	} else {
+		record.User = os.Getenv("USER")
+	}
+	record.Host, _ = os.Hostname()
+	return record
+}
+
+// secretFlags are the flags whose values are secrets, or commands that may embed them.
+var secretFlags = []string{"--access-token", "--access-token-command", "-t"}
+
+// booleanShorthands are the shorthands of the boolean flags, which may be combined with -t in a single argument,
+// as in -vt or -ft.
+const booleanShorthands = "vfy"
+
+// RedactArgs returns a copy of args with the values of the access token and access token command flags
+// replaced by Redacted.
+// Values given as a separate argument, after =, or appended to -t, also when combined with boolean shorthands as in
+// -vt or -vtTOKEN, are all redacted.
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		if redactNext {
+			redacted[i], redactNext = Redacted, false
+			continue
+		}
+		redacted[i] = arg
+		for _, flag := range secretFlags {
+			switch {
+			case arg == flag:
+				redactNext = true
+			case strings.HasPrefix(arg, flag+"="):
+				redacted[i] = flag + "=" + Redacted
+			case flag == "-t" && strings.HasPrefix(arg, flag) && !strings.HasPrefix(arg, "--"):
+				redacted[i] = flag + Redacted
+			}
+		}
+		if prefix, value, ok := cutShorthandToken(arg); ok && redacted[i] == arg {
+			if value == "" {
+				redactNext = true
+			} else {
+				redacted[i] = prefix + Redacted
+			}
+		}
+	}
+	return redacted
+}
+
+// cutShorthandToken splits arg, a cluster of boolean shorthands followed by -t such as -vt, -vtTOKEN or -vt=TOKEN,
+// into everything up to the token and the token, which is empty when it is the next argument.
+func cutShorthandToken(arg string) (prefix, value string, ok bool) {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' {
+		return "", "", false
+	}
+	for i := 1; i < len(arg); i++ {
+		switch {
+		case arg[i] == 't':
+			if strings.HasPrefix(arg[i+1:], "=") {
+				i++
+			}
+			return arg[:i+1], arg[i+1:], true
+		case !strings.ContainsRune(booleanShorthands, rune(arg[i])):
+			return "", "", false
+		}
+	}
+	return "", "", false
+}
+
+// Append appends record to the audit log at path, creating it and its directory if needed.
+func Append(path string, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding audit record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return file.Close()
+}
+
+// Read returns the records of the audit log at path, oldest first. A missing log has no records.
+func Read(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	var records []Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("parsing audit log %s, line %d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	return records, nil
+}
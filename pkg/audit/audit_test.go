@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{
+		"kubectl-doks", "kubeconfig", "sync",
+		"-t", "dop_v1_secret",
+		"--access-token", "dop_v1_other@https://api.example.com",
+		"--access-token=dop_v1_third",
+		"-tdop_v1_fourth",
+		"-t=dop_v1_fifth",
+		"--access-token-file", "/tmp/tokens",
+		"--access-token-command", "vault read -field=token secret/do",
+		"--access-token-command=echo dop_v1_sixth",
+		"-vt", "dop_v1_seventh",
+		"-ft", "dop_v1_eighth",
+		"-vtdop_v1_ninth",
+		"-vyt=dop_v1_tenth",
+		"-vf",
+		"--auth-context", "team",
+		"-ov", "tree",
+	}
+	assert.Equal(t, []string{
+		"kubectl-doks", "kubeconfig", "sync",
+		"-t", Redacted,
+		"--access-token", Redacted,
+		"--access-token=" + Redacted,
+		"-t" + Redacted,
+		"-t=" + Redacted,
+		"--access-token-file", "/tmp/tokens",
+		"--access-token-command", Redacted,
+		"--access-token-command=" + Redacted,
+		"-vt", Redacted,
+		"-ft", Redacted,
+		"-vt" + Redacted,
+		"-vyt=" + Redacted,
+		"-vf",
+		"--auth-context", "team",
+		"-ov", "tree",
+	}, RedactArgs(args))
+	assert.Equal(t, "dop_v1_secret", args[4], "The arguments should not be modified")
+}
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubectl-doks", FileName)
+
+	records, err := Read(path)
+	require.NoError(t, err)
+	assert.Empty(t, records, "A missing log should have no records")
+
+	first := NewRecord("kubeconfig sync", []string{"kubectl-doks", "kubeconfig", "sync", "-t", "secret"})
+	first.Added = []string{"do-nyc1-prod"}
+	first.BeforeHash, first.AfterHash = Hash(nil), Hash([]byte("apiVersion: v1\n"))
+	second := NewRecord("kubeconfig gc", nil)
+	second.Removed = []string{"do-nyc1-old"}
+
+	require.NoError(t, Append(path, first))
+	require.NoError(t, Append(path, second))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	records, err = Read(path)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "kubeconfig sync", records[0].Command)
+	assert.Equal(t, []string{"kubectl-doks", "kubeconfig", "sync", "-t", Redacted}, records[0].Args)
+	assert.Equal(t, []string{"do-nyc1-prod"}, records[0].Added)
+	assert.Equal(t, first.AfterHash, records[0].AfterHash)
+	assert.NotEmpty(t, records[0].User)
+	assert.WithinDuration(t, time.Now(), records[0].Time, time.Minute)
+	assert.Equal(t, []string{"do-nyc1-old"}, records[1].Removed)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0600))
+	_, err = Read(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestHash(t *testing.T) {
+	assert.Equal(t, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Hash(nil))
+	assert.NotEqual(t, Hash([]byte("a")), Hash([]byte("b")))
+}
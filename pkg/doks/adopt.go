@@ -0,0 +1,84 @@
+package doks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// AdoptedContext is a context brought under management by Adopt.
+type AdoptedContext struct {
+	kubeconfig.Adoption
+	// NewName is the name of the context after adoption.
+	NewName string
+	// Converted is set if doctl exec credentials were replaced by a token.
+	Converted bool
+}
+
+// String describes the adoption for the user.
+func (a AdoptedContext) String() string {
+	s := fmt.Sprintf("Adopted %q for cluster %q (%s), matched by %s", a.ContextName, a.Cluster.Name, a.Cluster.ID, a.MatchedBy)
+	if a.NewName != a.ContextName {
+		s += fmt.Sprintf(", renamed to %q", a.NewName)
+	}
+	if a.Converted {
+		s += ", with its doctl exec credentials replaced by a token"
+	}
+	return s + "."
+}
+
+// Adopt brings the contexts created outside kubectl-doks that match a listed cluster, as found by
+// kubeconfig.FindAdoptions, under management, renaming them to the context name of their cluster.
+// With ConvertExec, adopted contexts using doctl exec credentials get new credentials from the API.
+// The kubeconfig is only written if some context was adopted.
+func (s *Syncer) Adopt(ctx context.Context) (Result, error) {
+	reconciler, err := s.load()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allClusters, contextNames, err := s.listClusters(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Clusters: allClusters, Config: reconciler.Config()}
+
+	if err := s.adopt(ctx, reconciler, allClusters, contextNames, &result); err != nil {
+		return result, err
+	}
+	if len(result.Adopted) == 0 {
+		return result, nil
+	}
+	return result, s.save(ctx, reconciler, &result)
+}
+
+// adopt adopts the contexts of reconciler that match one of clusters under the context names given by contextNames,
+// recording them in result. Clusters being deleted are not adopted.
+// Contexts that cannot be renamed because their new name is taken are skipped with a warning.
+func (s *Syncer) adopt(ctx context.Context, reconciler *kubeconfig.Reconciler, clusters []do.Cluster, contextNames map[string]string, result *Result) error {
+	var candidates []do.Cluster
+	for _, cluster := range clusters {
+		if cluster.Status != do.StatusDeleted {
+			candidates = append(candidates, cluster)
+		}
+	}
+
+	for _, adoption := range kubeconfig.FindAdoptions(reconciler.Config(), candidates, contextNames) {
+		a := AdoptedContext{Adoption: adoption, NewName: contextNames[adoption.Cluster.ID]}
+		if err := reconciler.Adopt(adoption, a.NewName); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Not adopting %q: %v", adoption.ContextName, err))
+			continue
+		}
+
+		if s.options.ConvertExec && kubeconfig.UsesDoctlExec(reconciler.Config(), a.NewName) {
+			if err := s.fetch(ctx, reconciler, adoption.Cluster, a.NewName, s.options.ExpirySeconds); err != nil {
+				return err
+			}
+			a.Converted = true
+		}
+		result.Adopted = append(result.Adopted, a)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package doks is the engine behind the kubeconfig commands of kubectl-doks. A Syncer lists DOKS clusters,
+// fetches their credentials and reconciles them into a kubeconfig, so that other tools can sync DOKS
+// credentials the same way as the plugin does.
+package doks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClusterLister lists the DOKS clusters to sync. Clusters seen through several tokens should only be listed once.
+type ClusterLister interface {
+	ListClusters(ctx context.Context) ([]do.Cluster, error)
+}
+
+// CredentialFetcher fetches the credentials of a cluster returned by a ClusterLister.
+// An expirySeconds of 0 requests credentials that do not expire.
+type CredentialFetcher interface {
+	GetCredentials(ctx context.Context, cluster do.Cluster, expirySeconds int) (do.Credentials, error)
+}
+
+// KubeconfigStore reads and writes the kubeconfig being synced.
+type KubeconfigStore interface {
+	// Load returns the kubeconfig, or no data if there is none yet.
+	Load() ([]byte, error)
+	// Save replaces the kubeconfig with data.
+	Save(data []byte) error
+}
+
+// Options configure a Syncer. The zero value syncs credentials that do not expire and leaves the current context alone.
+type Options struct {
+	// ExpirySeconds is how long new credentials last. 0 means they do not expire.
+	// Renewed credentials keep the lifetime they were fetched with unless ExpirySeconds is set.
+	ExpirySeconds int
+	// Force fetches the credentials of clusters that are already in the kubeconfig again.
+	Force bool
+	// SetCurrentContext lets Sync and Save set the current context, as described on each.
+	SetCurrentContext bool
+	// NameCollision is NameCollisionError or NameCollisionTeam.
+	NameCollision string
+	// RefreshBefore also renews the credentials that expire within this duration. Expired credentials are always renewed.
+	RefreshBefore time.Duration
+	// PruneExpired makes Sync remove contexts whose credentials have expired instead of renewing them.
+	PruneExpired bool
+	// MaxPrune, if positive, makes Sync fail with a *PruneLimitError instead of removing the contexts of more than
+	// this many clusters that no longer exist.
+	MaxPrune int
+	// MaxPrunePercent, if positive, does the same as MaxPrune for more than this percentage of the contexts managed
+	// by kubectl-doks.
+	MaxPrunePercent int
+	// Adopt makes Sync adopt contexts created by doctl first, like Adopt.
+	Adopt bool
+	// ConvertExec replaces the doctl exec credentials of adopted contexts with credentials from the API.
+	ConvertExec bool
+	// Wait, if set, makes Save include clusters that are not running yet, and is called to wait until they are.
+	Wait func(ctx context.Context, cluster do.Cluster) (do.Cluster, error)
+	// BeforeWrite, if set, is called with the result before the kubeconfig is written. Changes it makes to
+	// result.Config are written. If it returns an error, the kubeconfig is left as it was and the error is returned.
+	BeforeWrite func(ctx context.Context, result *Result) error
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// Result describes what a Syncer did. Context lists hold context names.
+type Result struct {
+	// Clusters are all the clusters listed.
+	Clusters []do.Cluster
+	// ContextNames holds the context name of the listed clusters by cluster ID, as assigned by Sync, Save and
+	// their split layout variants.
+	ContextNames map[string]string
+	// Adopted are the contexts created by doctl that were adopted.
+	Adopted []AdoptedContext
+	// Removed are the contexts of clusters that no longer exist.
+	Removed []string
+	// Expired are the contexts removed because their credentials had expired.
+	Expired []string
+	// Added are the contexts whose credentials were fetched for a cluster that was missing, or again with Force.
+	Added []string
+	// Renewed are the contexts whose expiring credentials were renewed.
+	Renewed []string
+	// Unrenewed are the contexts whose credentials expire but could not be renewed, as their cluster is gone or not running.
+	Unrenewed []string
+	// Expiries holds the expiry the credentials of the Renewed and Unrenewed contexts had before renewal.
+	Expiries map[string]time.Time
+	// Updated are the contexts whose cluster details were updated.
+	Updated []string
+	// CurrentContext is the context that was made current, if any.
+	CurrentContext string
+	// Notices and Warnings are messages for the user, such as clusters skipped because of their state.
+	Notices  []string
+	Warnings []string
+	// Written reports whether the kubeconfig was saved to the store, or, with the split layout, whether any file
+	// was written or removed.
+	Written bool
+	// Files holds, with the split layout, the file of each context of Added, Renewed, Expired and Updated.
+	Files map[string]string
+	// Config is the resulting kubeconfig.
+	Config *k8sclientcmdapi.Config
+}
+
+// Syncer syncs the credentials of DOKS clusters into a kubeconfig.
+type Syncer struct {
+	lister  ClusterLister
+	fetcher CredentialFetcher
+	store   KubeconfigStore
+	options Options
+}
+
+// NewSyncer returns a Syncer listing clusters with lister, fetching their credentials with fetcher,
+// and reconciling them into the kubeconfig of store.
+func NewSyncer(lister ClusterLister, fetcher CredentialFetcher, store KubeconfigStore, options Options) *Syncer {
+	return &Syncer{lister: lister, fetcher: fetcher, store: store, options: options}
+}
+
+// now returns the current time.
+func (s *Syncer) now() time.Time {
+	if s.options.Now != nil {
+		return s.options.Now()
+	}
+	return time.Now()
+}
+
+// load reads the kubeconfig of the store, into a reconciler using the clock of the options.
+func (s *Syncer) load() (*kubeconfig.Reconciler, error) {
+	data, err := s.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	reconciler, err := kubeconfig.NewReconciler(data)
+	if err != nil {
+		return nil, err
+	}
+	reconciler.Now = s.options.Now
+	return reconciler, nil
+}
+
+// save writes the kubeconfig of reconciler to the store and records it in result.
+func (s *Syncer) save(ctx context.Context, reconciler *kubeconfig.Reconciler, result *Result) error {
+	if s.options.BeforeWrite != nil {
+		if err := s.options.BeforeWrite(ctx, result); err != nil {
+			return err
+		}
+	}
+
+	data, err := reconciler.Bytes()
+	if err != nil {
+		return fmt.Errorf("serializing modified kubeconfig: %w", err)
+	}
+	if err := s.store.Save(data); err != nil {
+		return err
+	}
+	result.Written = true
+	return nil
+}
+
+// listClusters lists the clusters and assigns their context names.
+func (s *Syncer) listClusters(ctx context.Context) ([]do.Cluster, map[string]string, error) {
+	clusters, err := s.lister.ListClusters(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	contextNames, err := AssignContextNames(clusters, s.options.NameCollision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clusters, contextNames, nil
+}
+
+// statusAction returns what to do with cluster, recording the notice or warning about it in result.
+func (s *Syncer) statusAction(cluster do.Cluster, result *Result) Action {
+	action, notice, warning := StatusAction(cluster)
+	if notice != "" {
+		result.Notices = append(result.Notices, notice)
+	}
+	if warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	return action
+}
+
+// fetch fetches the credentials of cluster, lasting expirySeconds, and adds its entries to reconciler under contextName.
+func (s *Syncer) fetch(ctx context.Context, reconciler *kubeconfig.Reconciler, cluster do.Cluster, contextName string, expirySeconds int) error {
+	credentials, err := s.fetcher.GetCredentials(ctx, cluster, expirySeconds)
+	if err != nil {
+		return fmt.Errorf("getting credentials for cluster %s: %w", cluster.Name, err)
+	}
+	reconciler.AddCluster(cluster, contextName, credentials)
+	return nil
+}
+
+// renewalExpirySeconds returns how long the renewed credentials of contextName in config should last: ExpirySeconds
+// if it is set, or else the lifetime the current credentials were fetched with, so that renewing them does not
+// silently replace short-lived credentials with ones lasting the API default.
+func (s *Syncer) renewalExpirySeconds(config *k8sclientcmdapi.Config, contextName string) int {
+	if s.options.ExpirySeconds > 0 {
+		return s.options.ExpirySeconds
+	}
+	return kubeconfig.CredentialsLifetime(config, contextName)
+}
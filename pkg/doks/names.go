@@ -0,0 +1,100 @@
+package doks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// Strategies for clusters of different teams that would get the same context name.
+const (
+	// NameCollisionError fails when two clusters would get the same context name.
+	NameCollisionError = "error"
+	// NameCollisionTeam gives all the clusters sharing a context name a team-qualified context name.
+	NameCollisionTeam = "team"
+)
+
+// AssignContextNames returns the kubeconfig context name to use for each cluster, keyed by cluster ID.
+// Clusters normally use kubeconfig.ContextName. Different clusters sharing a region and name, which happens
+// when they belong to different teams, either make it fail or, with the NameCollisionTeam strategy,
+// all get a team-qualified context name instead. An empty strategy is NameCollisionError.
+func AssignContextNames(clusters []do.Cluster, strategy string) (map[string]string, error) {
+	return assignContextNames(clusters, nil, strategy)
+}
+
+// AssignSelectedContextNames is AssignContextNames for commands working on the selected clusters only, such as use
+// and export: clusters sharing a context name only make it fail if one of them is selected, and are otherwise left
+// out of the result. Team-qualified names are still assigned across all the clusters.
+func AssignSelectedContextNames(clusters, selected []do.Cluster, strategy string) (map[string]string, error) {
+	selectedIDs := make(map[string]bool)
+	for _, cluster := range selected {
+		selectedIDs[cluster.ID] = true
+	}
+	return assignContextNames(clusters, selectedIDs, strategy)
+}
+
+// assignContextNames implements AssignContextNames, and AssignSelectedContextNames if selectedIDs is not nil.
+func assignContextNames(clusters []do.Cluster, selectedIDs map[string]bool, strategy string) (map[string]string, error) {
+	byName := make(map[string][]do.Cluster)
+	var names []string
+	for _, cluster := range clusters {
+		name := kubeconfig.ContextName(cluster)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], cluster)
+	}
+
+	contextNames := make(map[string]string)
+	for _, name := range names {
+		group := byName[name]
+		if len(group) == 1 {
+			contextNames[group[0].ID] = name
+			continue
+		}
+		if selectedIDs != nil && !slices.ContainsFunc(group, func(cluster do.Cluster) bool { return selectedIDs[cluster.ID] }) {
+			continue
+		}
+
+		var descriptions []string
+		for _, cluster := range group {
+			descriptions = append(descriptions, fmt.Sprintf("%s from %s", cluster.ID, DescribeSource(cluster.Team, cluster.AuthContext)))
+		}
+		if strategy != NameCollisionTeam {
+			return nil, fmt.Errorf("context name %q is used by multiple clusters: %s; use --name-collision=team to give them team-qualified context names",
+				name, strings.Join(descriptions, ", "))
+		}
+
+		qualified := make(map[string]bool)
+		for _, cluster := range group {
+			qualifier := kubeconfig.TeamQualifier(cluster)
+			contextName := kubeconfig.QualifiedContextName(cluster, qualifier)
+			if qualifier == "" || qualified[contextName] {
+				return nil, fmt.Errorf("context name %q is used by multiple clusters that cannot be told apart by team: %s",
+					name, strings.Join(descriptions, ", "))
+			}
+			qualified[contextName] = true
+			contextNames[cluster.ID] = contextName
+		}
+	}
+
+	return contextNames, nil
+}
+
+// DescribeSource returns a human readable description of where a token came from, for use in messages.
+func DescribeSource(team do.Team, authContext string) string {
+	var parts []string
+	if team.Name != "" {
+		parts = append(parts, fmt.Sprintf("team %q", team.Name))
+	}
+	if authContext != "" {
+		parts = append(parts, fmt.Sprintf("auth context %q", authContext))
+	}
+	if len(parts) == 0 {
+		return "a token"
+	}
+	return strings.Join(parts, " via ")
+}
@@ -0,0 +1,39 @@
+package doks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PruneLimitError is returned when a sync would remove more clusters than Options.MaxPrune or
+// Options.MaxPrunePercent allow, such as when the API briefly lists no clusters for a token.
+// Nothing is removed. The error message does not list the clusters, Removed does.
+type PruneLimitError struct {
+	// Removed are the contexts, or the files of the split layout, that would have been removed.
+	Removed []string
+	// Managed is the number of contexts, or files, managed by kubectl-doks before the sync.
+	Managed int
+	// MaxPrune and MaxPrunePercent are the limits that were exceeded, if positive.
+	MaxPrune        int
+	MaxPrunePercent int
+}
+
+func (e *PruneLimitError) Error() string {
+	var limits []string
+	if e.MaxPrune > 0 && len(e.Removed) > e.MaxPrune {
+		limits = append(limits, fmt.Sprintf("the limit of %d", e.MaxPrune))
+	}
+	if e.MaxPrunePercent > 0 && len(e.Removed)*100 > e.MaxPrunePercent*e.Managed {
+		limits = append(limits, fmt.Sprintf("%d%% of them", e.MaxPrunePercent))
+	}
+	return fmt.Sprintf("refusing to remove %d of %d DOKS clusters, more than %s", len(e.Removed), e.Managed, strings.Join(limits, " and "))
+}
+
+// CheckPruneLimit returns a *PruneLimitError if removing the given contexts, out of managed contexts, would exceed
+// maxPrune contexts or maxPrunePercent percent of them. Limits that are not positive are not checked.
+func CheckPruneLimit(removed []string, managed, maxPrune, maxPrunePercent int) error {
+	if (maxPrune > 0 && len(removed) > maxPrune) || (maxPrunePercent > 0 && len(removed)*100 > maxPrunePercent*managed) {
+		return &PruneLimitError{Removed: removed, Managed: managed, MaxPrune: maxPrune, MaxPrunePercent: maxPrunePercent}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package doks
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// Refresh renews the credentials of the DOKS contexts that have expired or expire within RefreshBefore.
+// Contexts whose cluster is gone or not running are reported in Unrenewed.
+// Clusters are only listed, and the kubeconfig only written, if some credentials need renewing.
+func (s *Syncer) Refresh(ctx context.Context) (Result, error) {
+	reconciler, err := s.load()
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Config: reconciler.Config()}
+
+	expiring := kubeconfig.ExpiringContexts(reconciler.Config(), s.now().Add(s.options.RefreshBefore))
+	if len(expiring) == 0 {
+		return result, nil
+	}
+
+	allClusters, err := s.lister.ListClusters(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.Clusters = allClusters
+
+	var renewableClusters []do.Cluster
+	for _, cluster := range allClusters {
+		if s.statusAction(cluster, &result) == SaveCluster {
+			renewableClusters = append(renewableClusters, cluster)
+		}
+	}
+
+	if err := s.renew(ctx, reconciler, expiring, renewableClusters, &result); err != nil {
+		return result, err
+	}
+	if len(result.Renewed) == 0 {
+		return result, nil
+	}
+	return result, s.save(ctx, reconciler, &result)
+}
+
+// renew fetches new credentials for the given contexts of reconciler, lasting as long as the ones they replace unless
+// ExpirySeconds is set, and replaces their entries, recording the
+// renewed contexts and their previous expiry in result. The cluster of each context is looked up in clusters by
+// the ID recorded in its cluster entry; contexts whose cluster is not in clusters are recorded as Unrenewed.
+func (s *Syncer) renew(ctx context.Context, reconciler *kubeconfig.Reconciler, contextNames []string, clusters []do.Cluster, result *Result) error {
+	config := reconciler.Config()
+
+	for _, contextName := range contextNames {
+		if result.Expiries == nil {
+			result.Expiries = make(map[string]time.Time)
+		}
+		result.Expiries[contextName], _ = kubeconfig.CredentialsExpiry(config, contextName)
+
+		i := -1
+		if entry, ok := config.Clusters[contextName]; ok {
+			if id, ok := kubeconfig.GetClusterID(entry); ok {
+				i = slices.IndexFunc(clusters, func(cluster do.Cluster) bool { return cluster.ID == id })
+			}
+		}
+		if i < 0 {
+			result.Unrenewed = append(result.Unrenewed, contextName)
+			continue
+		}
+
+		if err := s.fetch(ctx, reconciler, clusters[i], contextName, s.renewalExpirySeconds(config, contextName)); err != nil {
+			return err
+		}
+		result.Renewed = append(result.Renewed, contextName)
+	}
+	return nil
+}
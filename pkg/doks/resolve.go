@@ -0,0 +1,145 @@
+package doks
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// clusterURNPrefix is the prefix of DigitalOcean URNs for Kubernetes clusters, as in do:kubernetes:<id>.
+const clusterURNPrefix = "do:kubernetes:"
+
+// ResolveCluster finds the cluster identified by query in clusters.
+// The query can be a cluster ID, a do:kubernetes:<id> URN, a unique prefix of a cluster ID,
+// a cluster name, or a cluster name qualified by its team or region as <team>/<name> or <region>/<name>.
+// Ambiguous queries fail with the list of matching clusters, and unknown ones with suggestions of similar names.
+func ResolveCluster(clusters []do.Cluster, query string) (do.Cluster, error) {
+	if strings.HasPrefix(query, clusterURNPrefix) {
+		id := strings.TrimPrefix(query, clusterURNPrefix)
+		for _, cluster := range clusters {
+			if cluster.ID == id {
+				return cluster, nil
+			}
+		}
+		return do.Cluster{}, fmt.Errorf("cluster %q not found", query)
+	}
+
+	var candidates []do.Cluster
+	for _, cluster := range clusters {
+		if cluster.ID == query {
+			return cluster, nil
+		}
+		if matchesCluster(cluster, query) {
+			candidates = append(candidates, cluster)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return do.Cluster{}, notFoundError(clusters, query)
+	case 1:
+		return candidates[0], nil
+	default:
+		var names []string
+		for _, c := range candidates {
+			names = append(names, describeCluster(c))
+		}
+		sort.Strings(names)
+		return do.Cluster{}, fmt.Errorf("%q matches multiple clusters: %s; use a cluster ID, <team>/<name> or <region>/<name> to pick one",
+			query, strings.Join(names, ", "))
+	}
+}
+
+// matchesCluster reports whether query is the name of cluster, a qualified <team>/<name> or <region>/<name>
+// of it, or a prefix of its ID.
+func matchesCluster(cluster do.Cluster, query string) bool {
+	if cluster.Name == query || strings.HasPrefix(cluster.ID, query) {
+		return true
+	}
+
+	qualifier, name, ok := strings.Cut(query, "/")
+	if !ok || name != cluster.Name {
+		return false
+	}
+	return qualifier == cluster.Region ||
+		strings.EqualFold(qualifier, cluster.Team.Name) ||
+		qualifier == kubeconfig.TeamQualifier(cluster) ||
+		(qualifier == cluster.AuthContext && cluster.AuthContext != "")
+}
+
+// describeCluster returns a description of cluster that tells it apart from clusters with the same name.
+func describeCluster(cluster do.Cluster) string {
+	details := []string{cluster.Region, cluster.ID}
+	if cluster.Team.Name != "" {
+		details = append(details, fmt.Sprintf("team %q", cluster.Team.Name))
+	}
+	return fmt.Sprintf("%s (%s)", cluster.Name, strings.Join(details, ", "))
+}
+
+// notFoundError returns the error for a query that matches no cluster,
+// suggesting the cluster names closest to the name part of query.
+func notFoundError(clusters []do.Cluster, query string) error {
+	name := query
+	if i := strings.LastIndex(query, "/"); i >= 0 {
+		name = query[i+1:]
+	}
+
+	// Allow roughly one typo for every three characters, and at least one.
+	best := len(name) / 3
+	if best < 1 {
+		best = 1
+	}
+
+	var suggestions []string
+	for _, cluster := range clusters {
+		distance := editDistance(name, cluster.Name)
+		switch {
+		case distance > best:
+			continue
+		case distance < best:
+			best = distance
+			suggestions = nil
+		}
+		suggestion := fmt.Sprintf("%q", cluster.Name)
+		if !slices.Contains(suggestions, suggestion) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	switch len(suggestions) {
+	case 0:
+		return fmt.Errorf("cluster %q not found", query)
+	case 1:
+		return fmt.Errorf("cluster %q not found; did you mean %s?", query, suggestions[0])
+	default:
+		sort.Strings(suggestions)
+		return fmt.Errorf("cluster %q not found; did you mean one of %s?", query, strings.Join(suggestions, ", "))
+	}
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
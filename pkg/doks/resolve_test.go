@@ -0,0 +1,55 @@
+package doks
+
+import (
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCluster(t *testing.T) {
+	clusters := []do.Cluster{
+		{ID: "abc-111", Name: "prod", Region: "nyc1", Team: do.Team{Name: "Acme Prod"}, AuthContext: "acme"},
+		{ID: "abd-222", Name: "staging", Region: "sfo3"},
+		{ID: "xyz-333", Name: "prod", Region: "ams3", Team: do.Team{Name: "Other"}},
+		{ID: "xyz-444", Name: "prod", Region: "nyc1", Team: do.Team{Name: "Other"}},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantID    string
+		expectErr string
+	}{
+		{name: "by ID", query: "abd-222", wantID: "abd-222"},
+		{name: "by URN", query: "do:kubernetes:xyz-333", wantID: "xyz-333"},
+		{name: "by unique name", query: "staging", wantID: "abd-222"},
+		{name: "by unique ID prefix", query: "xyz-3", wantID: "xyz-333"},
+		{name: "by region and name", query: "ams3/prod", wantID: "xyz-333"},
+		{name: "by team name and name", query: "Acme Prod/prod", wantID: "abc-111"},
+		{name: "by team slug and name", query: "acme-prod/prod", wantID: "abc-111"},
+		{name: "by auth context and name", query: "acme/prod", wantID: "abc-111"},
+		{name: "URN of unknown cluster", query: "do:kubernetes:xyz", expectErr: `cluster "do:kubernetes:xyz" not found`},
+		{name: "ambiguous name", query: "prod", expectErr: `"prod" matches multiple clusters: prod (ams3, xyz-333, team "Other"), prod (nyc1, abc-111, team "Acme Prod"), prod (nyc1, xyz-444, team "Other")`},
+		{name: "ambiguous region and name", query: "nyc1/prod", expectErr: `"nyc1/prod" matches multiple clusters`},
+		{name: "ambiguous team and name", query: "other/prod", expectErr: `"other/prod" matches multiple clusters`},
+		{name: "ambiguous prefix", query: "ab", expectErr: `"ab" matches multiple clusters`},
+		{name: "not found", query: "missing", expectErr: `cluster "missing" not found`},
+		{name: "did you mean", query: "stagign", expectErr: `cluster "stagign" not found; did you mean "staging"?`},
+		{name: "did you mean with qualifier", query: "nyc1/prd", expectErr: `cluster "nyc1/prd" not found; did you mean "prod"?`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := ResolveCluster(clusters, tt.query)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantID, cluster.ID)
+		})
+	}
+}
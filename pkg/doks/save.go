@@ -0,0 +1,107 @@
+package doks
+
+import (
+	"context"
+	"slices"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+)
+
+// Save adds the credentials of the clusters identified by queries to the kubeconfig, as resolved by ResolveCluster,
+// or of all the listed clusters that are not in the kubeconfig yet if there are no queries.
+// Every query is resolved before any credentials are fetched, so that a typo does not leave a partial save.
+// With SetCurrentContext, the last cluster given becomes the current context, or, without queries,
+// the only added one if there was no current context. Nothing is done if no cluster is listed.
+func (s *Syncer) Save(ctx context.Context, queries []string) (Result, error) {
+	reconciler, err := s.load()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allClusters, err := s.lister.ListClusters(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Clusters: allClusters, Config: reconciler.Config()}
+	if len(allClusters) == 0 {
+		return result, nil
+	}
+
+	configObj := reconciler.Config()
+	waiting := s.options.Wait != nil
+
+	var selectedClusters []do.Cluster
+	if len(queries) > 0 {
+		for _, query := range queries {
+			cluster, err := ResolveCluster(allClusters, query)
+			if err != nil {
+				return result, err
+			}
+			warning, err := CheckClusterStatus(cluster, waiting)
+			if err != nil {
+				return result, err
+			}
+			if warning != "" {
+				result.Warnings = append(result.Warnings, warning)
+			}
+			if !slices.ContainsFunc(selectedClusters, func(c do.Cluster) bool { return c.ID == cluster.ID }) {
+				selectedClusters = append(selectedClusters, cluster)
+			}
+		}
+		// Collisions between other clusters do not matter when saving the given ones.
+		if result.ContextNames, err = AssignSelectedContextNames(allClusters, selectedClusters, s.options.NameCollision); err != nil {
+			return result, err
+		}
+	} else {
+		if result.ContextNames, err = AssignContextNames(allClusters, s.options.NameCollision); err != nil {
+			return result, err
+		}
+		for _, cluster := range allClusters {
+			if _, exists := configObj.Contexts[result.ContextNames[cluster.ID]]; exists && !s.options.Force {
+				continue
+			}
+
+			switch s.statusAction(cluster, &result) {
+			case SkipCluster:
+				continue
+			case DeferCluster:
+				if !waiting {
+					continue
+				}
+			}
+			selectedClusters = append(selectedClusters, cluster)
+		}
+	}
+
+	for _, cluster := range selectedClusters {
+		if waiting {
+			if cluster, err = s.options.Wait(ctx, cluster); err != nil {
+				return result, err
+			}
+		}
+
+		contextName := result.ContextNames[cluster.ID]
+		if err := s.fetch(ctx, reconciler, cluster, contextName, s.options.ExpirySeconds); err != nil {
+			return result, err
+		}
+		result.Added = append(result.Added, contextName)
+	}
+
+	if len(result.Added) == 0 {
+		return result, nil
+	}
+
+	if s.options.SetCurrentContext {
+		// Like saving the clusters one at a time, the last one given becomes the current context.
+		if len(queries) > 0 {
+			result.CurrentContext = result.Added[len(result.Added)-1]
+		} else if len(result.Added) == 1 && configObj.CurrentContext == "" {
+			result.CurrentContext = result.Added[0]
+		}
+		if result.CurrentContext != "" {
+			configObj.CurrentContext = result.CurrentContext
+		}
+	}
+
+	return result, s.save(ctx, reconciler, &result)
+}
@@ -0,0 +1,217 @@
+package doks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// SyncSplit is Sync for the split layout, where each cluster has its own kubeconfig file in dir, at
+// kubeconfig.SplitFilePath, with its context as the current context. It writes the files of new clusters, renews
+// expiring credentials, records the details of every cluster and removes the files of clusters that no longer exist.
+// The options apply as for Sync, except Adopt, SetCurrentContext and BeforeWrite, and the store is not used.
+// Result.Removed and a *PruneLimitError list the paths of the files of clusters that no longer exist.
+func (s *Syncer) SyncSplit(ctx context.Context, dir string) (Result, error) {
+	allClusters, contextNames, err := s.listClusters(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Clusters: allClusters, ContextNames: contextNames, Files: make(map[string]string)}
+
+	// As with the single layout, clusters being deleted are treated as gone,
+	// and provisioning clusters keep whatever file they already have.
+	var liveClusters []do.Cluster
+	var liveClusterIDs []string
+	actions := make(map[string]Action)
+	for _, cluster := range allClusters {
+		action := s.statusAction(cluster, &result)
+		if action == SkipCluster {
+			continue
+		}
+		actions[cluster.ID] = action
+		liveClusters = append(liveClusters, cluster)
+		liveClusterIDs = append(liveClusterIDs, cluster.ID)
+	}
+
+	staleFiles, managedFiles, err := kubeconfig.StaleSplitFiles(dir, liveClusterIDs)
+	if err != nil {
+		return result, err
+	}
+	if err := CheckPruneLimit(staleFiles, managedFiles, s.options.MaxPrune, s.options.MaxPrunePercent); err != nil {
+		result.Removed = staleFiles
+		return result, err
+	}
+	result.Removed, err = kubeconfig.PruneSplitFiles(dir, liveClusterIDs)
+	if err != nil {
+		return result, err
+	}
+
+	now := s.now()
+	for _, cluster := range liveClusters {
+		if actions[cluster.ID] == DeferCluster {
+			continue
+		}
+
+		contextName := contextNames[cluster.ID]
+		path := kubeconfig.SplitFilePath(dir, cluster.ID)
+		reconciler, err := s.loadSplitFile(path)
+		if err != nil {
+			return result, err
+		}
+		config := reconciler.Config()
+
+		entry, exists := config.Clusters[contextName]
+		if exists {
+			if id, found := kubeconfig.GetClusterID(entry); !found || id != cluster.ID {
+				exists = false
+			}
+		}
+
+		if exists && !s.options.Force {
+			if s.options.PruneExpired && slices.Contains(kubeconfig.ExpiringContexts(config, now), contextName) {
+				if err := os.Remove(path); err != nil {
+					return result, fmt.Errorf("removing expired kubeconfig at %s: %w", path, err)
+				}
+				result.Expired = append(result.Expired, contextName)
+				result.Files[contextName] = path
+				continue
+			}
+
+			if !slices.Contains(kubeconfig.ExpiringContexts(config, now.Add(s.options.RefreshBefore)), contextName) {
+				if updateClusterDetails(entry, cluster) {
+					if err := kubeconfig.WriteSplitFile(path, reconciler); err != nil {
+						return result, err
+					}
+					result.Updated = append(result.Updated, contextName)
+					result.Files[contextName] = path
+				}
+				continue
+			}
+		}
+
+		expirySeconds := s.options.ExpirySeconds
+		if exists {
+			expirySeconds = s.renewalExpirySeconds(config, contextName)
+		}
+		if err := s.saveSplitFile(ctx, cluster, contextName, path, reconciler, expirySeconds); err != nil {
+			return result, err
+		}
+		if exists {
+			result.Renewed = append(result.Renewed, contextName)
+		} else {
+			result.Added = append(result.Added, contextName)
+		}
+		result.Files[contextName] = path
+	}
+
+	result.Written = len(result.Removed) > 0 || len(result.Files) > 0
+	return result, nil
+}
+
+// SaveSplit is Save for the split layout: it writes the clusters identified by queries, or all the listed clusters
+// that do not have a file in dir yet if there are no queries, to their own file as SyncSplit does.
+// The options apply as for Save, except SetCurrentContext and BeforeWrite, and the store is not used.
+func (s *Syncer) SaveSplit(ctx context.Context, dir string, queries []string) (Result, error) {
+	allClusters, err := s.lister.ListClusters(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Clusters: allClusters, Files: make(map[string]string)}
+	if len(allClusters) == 0 {
+		return result, nil
+	}
+	waiting := s.options.Wait != nil
+
+	var selectedClusters []do.Cluster
+	if len(queries) > 0 {
+		for _, query := range queries {
+			cluster, err := ResolveCluster(allClusters, query)
+			if err != nil {
+				return result, err
+			}
+			warning, err := CheckClusterStatus(cluster, waiting)
+			if err != nil {
+				return result, err
+			}
+			if warning != "" {
+				result.Warnings = append(result.Warnings, warning)
+			}
+			if !slices.ContainsFunc(selectedClusters, func(c do.Cluster) bool { return c.ID == cluster.ID }) {
+				selectedClusters = append(selectedClusters, cluster)
+			}
+		}
+		if result.ContextNames, err = AssignSelectedContextNames(allClusters, selectedClusters, s.options.NameCollision); err != nil {
+			return result, err
+		}
+	} else {
+		if result.ContextNames, err = AssignContextNames(allClusters, s.options.NameCollision); err != nil {
+			return result, err
+		}
+		for _, cluster := range allClusters {
+			if _, err := os.Stat(kubeconfig.SplitFilePath(dir, cluster.ID)); err == nil && !s.options.Force {
+				continue
+			}
+			switch s.statusAction(cluster, &result) {
+			case SkipCluster:
+				continue
+			case DeferCluster:
+				if !waiting {
+					continue
+				}
+			}
+			selectedClusters = append(selectedClusters, cluster)
+		}
+	}
+
+	for _, cluster := range selectedClusters {
+		if waiting {
+			if cluster, err = s.options.Wait(ctx, cluster); err != nil {
+				return result, err
+			}
+		}
+
+		contextName := result.ContextNames[cluster.ID]
+		path := kubeconfig.SplitFilePath(dir, cluster.ID)
+		reconciler, err := s.loadSplitFile(path)
+		if err != nil {
+			return result, err
+		}
+		if err := s.saveSplitFile(ctx, cluster, contextName, path, reconciler, s.options.ExpirySeconds); err != nil {
+			return result, err
+		}
+		result.Added = append(result.Added, contextName)
+		result.Files[contextName] = path
+		result.Written = true
+	}
+	return result, nil
+}
+
+// loadSplitFile reads the kubeconfig file at path, which may not exist yet, like load.
+func (s *Syncer) loadSplitFile(path string) (*kubeconfig.Reconciler, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading kubeconfig at %s: %w", path, err)
+	}
+	reconciler, err := kubeconfig.NewReconciler(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig at %s: %w", path, err)
+	}
+	reconciler.Now = s.options.Now
+	return reconciler, nil
+}
+
+// saveSplitFile fetches the credentials of cluster, lasting expirySeconds, into reconciler, the file at path, under
+// contextName, makes contextName its current context and writes it.
+// Contexts left by an earlier name of the cluster are removed.
+func (s *Syncer) saveSplitFile(ctx context.Context, cluster do.Cluster, contextName, path string, reconciler *kubeconfig.Reconciler, expirySeconds int) error {
+	reconciler.Prune([]string{contextName})
+	if err := s.fetch(ctx, reconciler, cluster, contextName, expirySeconds); err != nil {
+		return err
+	}
+	reconciler.Config().CurrentContext = contextName
+	return kubeconfig.WriteSplitFile(path, reconciler)
+}
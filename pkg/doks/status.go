@@ -0,0 +1,51 @@
+package doks
+
+import (
+	"fmt"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+)
+
+// Action is what sync and save do with a cluster, depending on its state.
+type Action int
+
+const (
+	// SaveCluster saves the credentials of the cluster.
+	SaveCluster Action = iota
+	// DeferCluster leaves the cluster as it is in the kubeconfig until it is running.
+	DeferCluster
+	// SkipCluster ignores the cluster as if it did not exist.
+	SkipCluster
+)
+
+// StatusAction returns what to do with cluster when syncing or saving all clusters.
+// Clusters being deleted are skipped and provisioning clusters are deferred, with a notice explaining why.
+// Clusters in a degraded or error state are saved, with a warning.
+func StatusAction(cluster do.Cluster) (action Action, notice, warning string) {
+	switch cluster.Status {
+	case do.StatusDeleted:
+		return SkipCluster, fmt.Sprintf("Skipping cluster %q, which is being deleted.", cluster.Name), ""
+	case do.StatusProvisioning:
+		return DeferCluster, fmt.Sprintf("Deferring cluster %q until it is running; it is still provisioning.", cluster.Name), ""
+	case do.StatusDegraded, do.StatusError, do.StatusInvalid:
+		return SaveCluster, "", fmt.Sprintf("Cluster %q is in %s state.", cluster.Name, cluster.Status)
+	}
+	return SaveCluster, "", ""
+}
+
+// CheckClusterStatus checks that the credentials of a cluster asked for by name can be saved.
+// Unlike StatusAction, it only refuses clusters being deleted, and returns a warning for the others that are not running.
+// Provisioning clusters are not warned about when waiting for them.
+func CheckClusterStatus(cluster do.Cluster, waiting bool) (string, error) {
+	switch cluster.Status {
+	case do.StatusDeleted:
+		return "", fmt.Errorf("cluster %q is being deleted", cluster.Name)
+	case do.StatusProvisioning:
+		if !waiting {
+			return fmt.Sprintf("Cluster %q is still provisioning and its API server may not answer yet; `kubeconfig save --wait` waits until it is running.", cluster.Name), nil
+		}
+	case do.StatusDegraded, do.StatusError, do.StatusInvalid:
+		return fmt.Sprintf("Cluster %q is in %s state.", cluster.Name, cluster.Status), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,48 @@
+package doks
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+)
+
+// FileStore is a KubeconfigStore backed by a kubeconfig file.
+type FileStore struct {
+	// Path is the kubeconfig file, ~/.kube/config if empty. Load resolves it.
+	Path string
+	// Backup copies the file to Path.kubectl-doks.bak before it is first overwritten.
+	Backup bool
+	// BackedUp is set once the file has been backed up.
+	BackedUp bool
+}
+
+// Load reads the kubeconfig file. A missing file is an empty kubeconfig.
+func (s *FileStore) Load() ([]byte, error) {
+	path, data, err := kubeconfig.GetKubeconfig(s.Path)
+	s.Path = path
+	return data, err
+}
+
+// Save writes data to the kubeconfig file, backing up the previous file first if asked to.
+func (s *FileStore) Save(data []byte) error {
+	if s.Path == "" {
+		if _, err := s.Load(); err != nil {
+			return err
+		}
+	}
+
+	if s.Backup && !s.BackedUp {
+		if _, err := os.Stat(s.Path); err == nil {
+			if err := kubeconfig.BackupKubeconfig(s.Path, s.Path+".kubectl-doks.bak"); err != nil {
+				return fmt.Errorf("backing up kubeconfig: %w", err)
+			}
+			s.BackedUp = true
+		}
+	}
+
+	if err := os.WriteFile(s.Path, data, 0600); err != nil {
+		return fmt.Errorf("writing updated kubeconfig: %w", err)
+	}
+	return nil
+}
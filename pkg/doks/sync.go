@@ -0,0 +1,154 @@
+package doks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Sync makes the kubeconfig match the listed clusters: it adds contexts for new clusters, removes the contexts of
+// clusters that no longer exist, renews expiring credentials and records the details of every cluster.
+// Clusters being deleted are treated as gone, and provisioning clusters keep whatever entry they already have.
+// With SetCurrentContext, a single added context becomes current if there was no current context, or if it was removed.
+// If more contexts would be removed than MaxPrune or MaxPrunePercent allow, Sync fails with a *PruneLimitError
+// listing them in Result.Removed. The kubeconfig is only written if something changed.
+func (s *Syncer) Sync(ctx context.Context) (Result, error) {
+	reconciler, err := s.load()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allClusters, contextNames, err := s.listClusters(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Clusters: allClusters, ContextNames: contextNames, Config: reconciler.Config()}
+	configObj := reconciler.Config()
+
+	// Clusters being deleted are treated as gone, so their contexts are pruned.
+	// Provisioning clusters keep whatever entry they already have until they are running.
+	var liveClusters []do.Cluster
+	var liveContextNames []string
+	actions := make(map[string]Action)
+	for _, cluster := range allClusters {
+		action := s.statusAction(cluster, &result)
+		if action == SkipCluster {
+			continue
+		}
+		actions[cluster.ID] = action
+		liveClusters = append(liveClusters, cluster)
+		liveContextNames = append(liveContextNames, contextNames[cluster.ID])
+	}
+
+	// Adopt contexts created by doctl before pruning, as adoption may rename them.
+	if s.options.Adopt {
+		if err := s.adopt(ctx, reconciler, liveClusters, contextNames, &result); err != nil {
+			return result, err
+		}
+	}
+
+	managed := len(kubeconfig.ManagedContexts(configObj))
+	result.Removed = reconciler.Prune(liveContextNames)
+	if err := CheckPruneLimit(result.Removed, managed, s.options.MaxPrune, s.options.MaxPrunePercent); err != nil {
+		return result, err
+	}
+	if s.options.PruneExpired {
+		result.Expired = reconciler.PruneExpired(s.now())
+	}
+
+	for _, cluster := range liveClusters {
+		if actions[cluster.ID] == DeferCluster {
+			continue
+		}
+
+		expectedContextName := contextNames[cluster.ID]
+		// Expired contexts pruned above are not added back by this sync.
+		if slices.Contains(result.Expired, expectedContextName) {
+			continue
+		}
+
+		var needsUpdate bool
+		if existingCluster, exists := configObj.Clusters[expectedContextName]; !exists {
+			needsUpdate = true
+		} else {
+			if id, found := kubeconfig.GetClusterID(existingCluster); !found || id != cluster.ID {
+				needsUpdate = true
+				result.Notices = append(result.Notices, fmt.Sprintf("Cluster '%s' has a new ID, will resync config.", cluster.Name))
+			}
+		}
+
+		if !needsUpdate && !s.options.Force {
+			continue
+		}
+
+		if err := s.fetch(ctx, reconciler, cluster, expectedContextName, s.options.ExpirySeconds); err != nil {
+			return result, err
+		}
+		result.Added = append(result.Added, expectedContextName)
+	}
+
+	// Renew the credentials of existing contexts that have expired or are about to.
+	var renewableClusters []do.Cluster
+	for _, cluster := range liveClusters {
+		if actions[cluster.ID] == SaveCluster {
+			renewableClusters = append(renewableClusters, cluster)
+		}
+	}
+	var expiringContexts []string
+	for _, contextName := range kubeconfig.ExpiringContexts(configObj, s.now().Add(s.options.RefreshBefore)) {
+		if !slices.Contains(result.Added, contextName) {
+			expiringContexts = append(expiringContexts, contextName)
+		}
+	}
+	if err := s.renew(ctx, reconciler, expiringContexts, renewableClusters, &result); err != nil {
+		return result, err
+	}
+
+	// Record the details of every cluster that is already in the kubeconfig.
+	for _, cluster := range liveClusters {
+		contextName := contextNames[cluster.ID]
+		entry, ok := configObj.Clusters[contextName]
+		if !ok {
+			continue
+		}
+		if id, _ := kubeconfig.GetClusterID(entry); id != cluster.ID {
+			continue
+		}
+		if updateClusterDetails(entry, cluster) {
+			result.Updated = append(result.Updated, contextName)
+		}
+	}
+
+	if len(result.Adopted) == 0 && len(result.Removed) == 0 && len(result.Expired) == 0 &&
+		len(result.Added) == 0 && len(result.Renewed) == 0 && len(result.Updated) == 0 {
+		return result, nil
+	}
+
+	originalCurrentContext := reconciler.OriginalCurrentContext()
+	contextRemoved := originalCurrentContext != "" &&
+		slices.Contains(slices.Concat(result.Removed, result.Expired), originalCurrentContext)
+
+	if s.options.SetCurrentContext && len(result.Added) == 1 && (configObj.CurrentContext == "" || contextRemoved) {
+		configObj.CurrentContext = result.Added[0]
+		result.CurrentContext = result.Added[0]
+	}
+
+	return result, s.save(ctx, reconciler, &result)
+}
+
+// updateClusterDetails records the last known state, region and Kubernetes version of cluster in the extension of
+// its cluster entry, migrating extensions written by older versions of kubectl-doks. It reports whether entry changed.
+func updateClusterDetails(entry *k8sclientcmdapi.Cluster, cluster do.Cluster) bool {
+	extension, _ := kubeconfig.GetExtension(entry)
+	updated := extension
+	updated.Status, updated.Region, updated.KubernetesVersion = cluster.Status, cluster.Region, cluster.Version
+	if updated == extension && extension.Version >= kubeconfig.ExtensionVersion {
+		return false
+	}
+	kubeconfig.SetExtension(entry, updated)
+	return true
+}
@@ -0,0 +1,287 @@
+package doks
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// fakeClusters is a ClusterLister and CredentialFetcher serving clusters, recording the clusters whose credentials are fetched
+// and the lifetime last requested.
+type fakeClusters struct {
+	clusters      []do.Cluster
+	expiresAt     time.Time
+	fetched       []string
+	expirySeconds int
+}
+
+func (f *fakeClusters) ListClusters(ctx context.Context) ([]do.Cluster, error) {
+	return f.clusters, nil
+}
+
+func (f *fakeClusters) GetCredentials(ctx context.Context, cluster do.Cluster, expirySeconds int) (do.Credentials, error) {
+	f.fetched = append(f.fetched, cluster.Name)
+	f.expirySeconds = expirySeconds
+	return do.Credentials{
+		Server:        "https://" + cluster.ID + ".example.com",
+		Token:         cluster.Name + "-token",
+		ExpiresAt:     f.expiresAt,
+		ExpirySeconds: expirySeconds,
+	}, nil
+}
+
+// memoryStore is a KubeconfigStore keeping the kubeconfig in memory.
+type memoryStore struct {
+	data  []byte
+	saves int
+}
+
+func (m *memoryStore) Load() ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *memoryStore) Save(data []byte) error {
+	m.data = data
+	m.saves++
+	return nil
+}
+
+// load parses the kubeconfig of the store.
+func (m *memoryStore) load(t *testing.T) *k8sclientcmdapi.Config {
+	config, err := k8sclientcmd.Load(m.data)
+	require.NoError(t, err)
+	return config
+}
+
+func TestSyncerSync(t *testing.T) {
+	clusters := &fakeClusters{clusters: []do.Cluster{
+		{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "new-id", Name: "new", Region: "nyc1", Status: do.StatusProvisioning},
+		{ID: "old-id", Name: "old", Region: "nyc1", Status: do.StatusDeleted},
+	}}
+	store := &memoryStore{}
+	syncer := NewSyncer(clusters, clusters, store, Options{SetCurrentContext: true})
+
+	result, err := syncer.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Added)
+	assert.Equal(t, "do-nyc1-api", result.CurrentContext)
+	assert.True(t, result.Written)
+	assert.Len(t, result.Notices, 2, "The skipped and deferred clusters should be reported")
+	assert.Equal(t, []string{"api"}, clusters.fetched)
+
+	config := store.load(t)
+	assert.Equal(t, "do-nyc1-api", config.CurrentContext)
+	assert.Equal(t, "api-token", config.AuthInfos["do-nyc1-api-admin"].Token)
+
+	// The cluster is deleted, and its context removed.
+	clusters.clusters = clusters.clusters[1:]
+	clusters.fetched = nil
+	result, err = syncer.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Removed)
+	assert.Empty(t, clusters.fetched)
+	assert.NotContains(t, store.load(t).Contexts, "do-nyc1-api")
+
+	// Nothing changed, so the kubeconfig is not written.
+	saves := store.saves
+	result, err = syncer.Sync(context.Background())
+	require.NoError(t, err)
+	assert.False(t, result.Written)
+	assert.Equal(t, saves, store.saves)
+}
+
+func TestSyncerSave(t *testing.T) {
+	clusters := &fakeClusters{clusters: []do.Cluster{
+		{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "web-id", Name: "web", Region: "sfo3", Status: do.StatusProvisioning},
+	}}
+	store := &memoryStore{}
+	var waited []string
+	syncer := NewSyncer(clusters, clusters, store, Options{
+		SetCurrentContext: true,
+		Wait: func(ctx context.Context, cluster do.Cluster) (do.Cluster, error) {
+			waited = append(waited, cluster.Name)
+			cluster.Status = do.StatusRunning
+			return cluster, nil
+		},
+	})
+
+	result, err := syncer.Save(context.Background(), []string{"web", "api-id"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-sfo3-web", "do-nyc1-api"}, result.Added)
+	assert.Equal(t, "do-nyc1-api", result.CurrentContext, "The last cluster given should become the current context")
+	assert.Equal(t, []string{"web", "api"}, waited, "Wait should be called for every cluster")
+	assert.Empty(t, result.Warnings, "Provisioning clusters should not be warned about when waiting")
+
+	_, err = syncer.Save(context.Background(), []string{"missing"})
+	assert.ErrorContains(t, err, `cluster "missing" not found`)
+
+	// Without queries, only missing clusters are saved.
+	clusters.fetched = nil
+	result, err = syncer.Save(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, clusters.fetched)
+	assert.False(t, result.Written)
+}
+
+func TestSyncerRefresh(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clusters := &fakeClusters{
+		clusters:  []do.Cluster{{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning}},
+		expiresAt: now.Add(30 * time.Minute),
+	}
+	store := &memoryStore{}
+	syncer := NewSyncer(clusters, clusters, store, Options{ExpirySeconds: 1800})
+	_, err := syncer.Sync(context.Background())
+	require.NoError(t, err)
+
+	refresher := NewSyncer(clusters, clusters, store, Options{RefreshBefore: time.Hour, Now: func() time.Time { return now }})
+	clusters.fetched = nil
+	clusters.expiresAt = now.Add(24 * time.Hour)
+	result, err := refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Renewed)
+	assert.Equal(t, now.Add(30*time.Minute), result.Expiries["do-nyc1-api"])
+	assert.Equal(t, []string{"api"}, clusters.fetched)
+	assert.Equal(t, 1800, clusters.expirySeconds, "Renewed credentials should keep the lifetime they were fetched with")
+
+	expiresAt, ok := kubeconfig.CredentialsExpiry(store.load(t), "do-nyc1-api")
+	require.True(t, ok)
+	assert.Equal(t, now.Add(24*time.Hour), expiresAt)
+	assert.Equal(t, 1800, kubeconfig.CredentialsLifetime(store.load(t), "do-nyc1-api"))
+	extension, _ := kubeconfig.GetExtension(store.load(t).Clusters["do-nyc1-api"])
+	assert.Equal(t, now, extension.SyncedAt, "SyncedAt should come from Options.Now")
+
+	// The new credentials are fresh.
+	clusters.fetched = nil
+	result, err = refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, result.Renewed)
+	assert.Empty(t, clusters.fetched)
+
+	// An explicit lifetime overrides the recorded one.
+	refresher = NewSyncer(clusters, clusters, store, Options{ExpirySeconds: 900, RefreshBefore: 48 * time.Hour, Now: func() time.Time { return now }})
+	result, err = refresher.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Renewed)
+	assert.Equal(t, 900, clusters.expirySeconds)
+	assert.Equal(t, 900, kubeconfig.CredentialsLifetime(store.load(t), "do-nyc1-api"))
+}
+
+func TestSyncerPruneLimit(t *testing.T) {
+	clusters := &fakeClusters{clusters: []do.Cluster{
+		{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "web-id", Name: "web", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "db-id", Name: "db", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "ci-id", Name: "ci", Region: "nyc1", Status: do.StatusRunning},
+	}}
+	store := &memoryStore{}
+	_, err := NewSyncer(clusters, clusters, store, Options{}).Sync(context.Background())
+	require.NoError(t, err)
+
+	// The API briefly lists a single cluster.
+	clusters.clusters = clusters.clusters[:1]
+	saves := store.saves
+
+	result, err := NewSyncer(clusters, clusters, store, Options{MaxPrune: 2}).Sync(context.Background())
+	var limitErr *PruneLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.EqualError(t, err, "refusing to remove 3 of 4 DOKS clusters, more than the limit of 2")
+	assert.ElementsMatch(t, []string{"do-nyc1-web", "do-nyc1-db", "do-nyc1-ci"}, limitErr.Removed)
+	assert.ElementsMatch(t, limitErr.Removed, result.Removed)
+	assert.False(t, result.Written)
+	assert.Equal(t, saves, store.saves, "The kubeconfig should not be written")
+
+	_, err = NewSyncer(clusters, clusters, store, Options{MaxPrunePercent: 50}).Sync(context.Background())
+	assert.EqualError(t, err, "refusing to remove 3 of 4 DOKS clusters, more than 50% of them")
+
+	result, err = NewSyncer(clusters, clusters, store, Options{MaxPrune: 3, MaxPrunePercent: 75}).Sync(context.Background())
+	require.NoError(t, err, "Removing exactly the limit should be allowed")
+	assert.Len(t, result.Removed, 3)
+	assert.True(t, result.Written)
+}
+
+func TestSyncerSplit(t *testing.T) {
+	dir := t.TempDir()
+	clusters := &fakeClusters{clusters: []do.Cluster{
+		{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning},
+		{ID: "web-id", Name: "web", Region: "sfo3", Status: do.StatusRunning},
+	}}
+
+	result, err := NewSyncer(clusters, clusters, nil, Options{}).SaveSplit(context.Background(), dir, []string{"web"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-sfo3-web"}, result.Added)
+	webPath := kubeconfig.SplitFilePath(dir, "web-id")
+	assert.Equal(t, map[string]string{"do-sfo3-web": webPath}, result.Files)
+	config, err := k8sclientcmd.LoadFromFile(webPath)
+	require.NoError(t, err)
+	assert.Equal(t, "do-sfo3-web", config.CurrentContext)
+
+	syncer := NewSyncer(clusters, clusters, nil, Options{MaxPrune: 1})
+	clusters.fetched = nil
+	result, err = syncer.SyncSplit(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Added)
+	assert.Equal(t, []string{"api"}, clusters.fetched, "Clusters that have a file should not be fetched again")
+	assert.Equal(t, "do-nyc1-api", result.ContextNames["api-id"])
+	assert.True(t, result.Written)
+
+	// The details of the cluster are recorded without fetching its credentials again.
+	clusters.clusters[1].Version = "1.33.1-do.0"
+	clusters.fetched = nil
+	result, err = syncer.SyncSplit(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do-sfo3-web"}, result.Updated)
+	assert.Empty(t, clusters.fetched)
+	config, err = k8sclientcmd.LoadFromFile(webPath)
+	require.NoError(t, err)
+	extension, _ := kubeconfig.GetExtension(config.Clusters["do-sfo3-web"])
+	assert.Equal(t, "1.33.1-do.0", extension.KubernetesVersion)
+
+	// The files of deleted clusters are removed, within the limits.
+	clusters.clusters = nil
+	_, err = syncer.SyncSplit(context.Background(), dir)
+	var limitErr *PruneLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Len(t, limitErr.Removed, 2)
+
+	clusters.clusters = []do.Cluster{{ID: "api-id", Name: "api", Region: "nyc1", Status: do.StatusRunning}}
+	result, err = syncer.SyncSplit(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{webPath}, result.Removed)
+	assert.NoFileExists(t, webPath)
+	assert.FileExists(t, filepath.Join(dir, "api-id.yaml"))
+
+	// A cluster recreated with the same name gets a new file, and the file of the old one is removed.
+	clusters.clusters = []do.Cluster{{ID: "api-id-2", Name: "api", Region: "nyc1", Status: do.StatusRunning}}
+	result, err = syncer.SyncSplit(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "api-id.yaml")}, result.Removed)
+	assert.Equal(t, []string{"do-nyc1-api"}, result.Added)
+	paths, err := kubeconfig.SplitFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{kubeconfig.SplitFilePath(dir, "api-id-2")}, paths)
+}
+
+func TestSyncerListError(t *testing.T) {
+	syncer := NewSyncer(failingLister{}, &fakeClusters{}, &memoryStore{}, Options{})
+	_, err := syncer.Sync(context.Background())
+	assert.EqualError(t, err, "listing failed")
+}
+
+// failingLister is a ClusterLister that always fails.
+type failingLister struct{}
+
+func (failingLister) ListClusters(ctx context.Context) ([]do.Cluster, error) {
+	return nil, errors.New("listing failed")
+}
@@ -0,0 +1,209 @@
+// Package hooks runs the external commands configured to react to the changes kubectl-doks makes to a kubeconfig.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event is the name of a hook, for which commands can be configured.
+type Event string
+
+// Events hooks can be configured for.
+const (
+	// OnAdd runs for each context added, before the kubeconfig is written.
+	OnAdd Event = "on-add"
+	// OnUpdate runs for each context whose credentials or cluster details changed, before the kubeconfig is written.
+	OnUpdate Event = "on-update"
+	// OnRemove runs for each context removed, before the kubeconfig is written.
+	OnRemove Event = "on-remove"
+	// PostSync runs once after the kubeconfig is written.
+	PostSync Event = "post-sync"
+)
+
+// Events lists all events, in the order they run.
+var Events = []Event{OnRemove, OnAdd, OnUpdate, PostSync}
+
+// Failure policies, deciding what happens when a hook fails.
+const (
+	// FailureWarn reports failed hooks as warnings and carries on.
+	FailureWarn = "warn"
+	// FailureAbort stops at the first failed hook, before the kubeconfig is written if it was not yet.
+	FailureAbort = "abort"
+)
+
+// DefaultTimeout is how long a hook command may run when no timeout is configured.
+const DefaultTimeout = 30 * time.Second
+
+// Config holds the configured hooks.
+type Config struct {
+	// Commands are the shell commands to run for each event, in order.
+	Commands map[Event][]string
+	// Timeout is how long each command may run, DefaultTimeout if 0.
+	Timeout time.Duration
+	// FailurePolicy is FailureWarn or FailureAbort. Empty means FailureWarn.
+	FailurePolicy string
+}
+
+// Abort reports whether a failed hook should stop the command that ran it.
+func (c Config) Abort() bool {
+	return c.FailurePolicy == FailureAbort
+}
+
+// Validate checks the failure policy and events of c.
+func (c Config) Validate() error {
+	if c.FailurePolicy != "" && c.FailurePolicy != FailureWarn && c.FailurePolicy != FailureAbort {
+		return fmt.Errorf("invalid hook failure policy %q: must be %q or %q", c.FailurePolicy, FailureWarn, FailureAbort)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid hook timeout %s: must not be negative", c.Timeout)
+	}
+	for event := range c.Commands {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown hook %q", event)
+		}
+	}
+	return nil
+}
+
+// Cluster describes the cluster of a context passed to a hook. Fields the kubeconfig does not record are empty
+// for removed contexts.
+type Cluster struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Version string `json:"version,omitempty"`
+	Team    string `json:"team,omitempty"`
+}
+
+// Payload is the JSON document passed to hook commands on standard input.
+type Payload struct {
+	Event Event `json:"event"`
+	// Kubeconfig is the path of the kubeconfig being changed, or, for the hooks running before it is written,
+	// of a copy holding the changes about to be written.
+	Kubeconfig string `json:"kubeconfig"`
+
+	// Context and Cluster describe the context of an on-add, on-update or on-remove hook.
+	Context string   `json:"context,omitempty"`
+	Cluster *Cluster `json:"cluster,omitempty"`
+
+	// Added, Updated and Removed list the contexts changed by the sync, for a post-sync hook.
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Environment returns the environment variables describing payload, set for hook commands in addition to
+// the environment of kubectl-doks.
+func (p Payload) Environment() []string {
+	env := []string{
+		"KUBECTL_DOKS_HOOK=" + string(p.Event),
+		"KUBECONFIG=" + p.Kubeconfig,
+	}
+	if p.Context != "" {
+		env = append(env, "KUBECTL_DOKS_CONTEXT="+p.Context)
+	}
+	if p.Cluster != nil {
+		env = append(env,
+			"KUBECTL_DOKS_CLUSTER_ID="+p.Cluster.ID,
+			"KUBECTL_DOKS_CLUSTER_NAME="+p.Cluster.Name,
+			"KUBECTL_DOKS_REGION="+p.Cluster.Region,
+			"KUBECTL_DOKS_STATUS="+p.Cluster.Status,
+			"KUBECTL_DOKS_VERSION="+p.Cluster.Version,
+			"KUBECTL_DOKS_TEAM="+p.Cluster.Team,
+		)
+	}
+	if p.Event == PostSync {
+		env = append(env,
+			"KUBECTL_DOKS_ADDED="+strings.Join(p.Added, ","),
+			"KUBECTL_DOKS_UPDATED="+strings.Join(p.Updated, ","),
+			"KUBECTL_DOKS_REMOVED="+strings.Join(p.Removed, ","),
+		)
+	}
+	return env
+}
+
+// Runner runs the commands configured for hooks.
+type Runner struct {
+	Config Config
+	// Stdout and Stderr receive the output of the commands, os.Stderr if nil.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Configured reports whether commands are configured for event.
+func (r *Runner) Configured(event Event) bool {
+	return r != nil && len(r.Config.Commands[event]) > 0
+}
+
+// Run runs the commands configured for payload.Event through the shell, in order, passing payload in their
+// environment and as JSON on standard input. With FailureAbort it stops at the first command that fails;
+// otherwise it runs them all. It returns the errors of the commands that failed.
+func (r *Runner) Run(ctx context.Context, payload Payload) error {
+	if !r.Configured(payload.Event) {
+		return nil
+	}
+
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding %s hook input: %w", payload.Event, err)
+	}
+
+	var errs []error
+	for _, command := range r.Config.Commands[payload.Event] {
+		if err := r.run(ctx, command, input, payload); err != nil {
+			errs = append(errs, err)
+			if r.Config.Abort() {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run runs a single hook command.
+func (r *Runner) run(ctx context.Context, command string, input []byte, payload Payload) error {
+	timeout := r.Config.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c := exec.CommandContext(ctx, "sh", "-c", command)
+	c.Env = append(os.Environ(), payload.Environment()...)
+	c.Stdin = bytes.NewReader(input)
+	c.Stdout, c.Stderr = r.Stdout, r.Stderr
+	if c.Stdout == nil {
+		c.Stdout = os.Stderr
+	}
+	if c.Stderr == nil {
+		c.Stderr = os.Stderr
+	}
+	// Don't wait for background processes started by the command once it is killed.
+	c.WaitDelay = time.Second
+
+	description := fmt.Sprintf("%s hook %q", payload.Event, command)
+	if payload.Context != "" {
+		description += " for context " + payload.Context
+	}
+
+	err := c.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %s", description, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", description, err)
+	}
+	return nil
+}
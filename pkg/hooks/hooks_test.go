@@ -0,0 +1,101 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerRun(t *testing.T) {
+	payload := Payload{
+		Event:      OnAdd,
+		Kubeconfig: "/tmp/config",
+		Context:    "do-nyc1-prod",
+		Cluster:    &Cluster{ID: "prod-id", Name: "prod", Region: "nyc1", Team: "My Team"},
+	}
+
+	t.Run("passes the payload in the environment and on standard input", func(t *testing.T) {
+		var out bytes.Buffer
+		runner := &Runner{
+			Config: Config{Commands: map[Event][]string{OnAdd: {
+				`echo "$KUBECTL_DOKS_HOOK $KUBECTL_DOKS_CONTEXT $KUBECTL_DOKS_CLUSTER_ID $KUBECTL_DOKS_TEAM $KUBECONFIG"`,
+				"cat",
+			}}},
+			Stdout: &out,
+		}
+		require.NoError(t, runner.Run(context.Background(), payload))
+
+		first, input, _ := strings.Cut(out.String(), "\n")
+		assert.Equal(t, "on-add do-nyc1-prod prod-id My Team /tmp/config", first)
+		var received Payload
+		require.NoError(t, json.Unmarshal([]byte(input), &received))
+		assert.Equal(t, payload, received)
+	})
+
+	t.Run("runs nothing for events without commands", func(t *testing.T) {
+		runner := &Runner{Config: Config{Commands: map[Event][]string{OnRemove: {"exit 1"}}}}
+		assert.NoError(t, runner.Run(context.Background(), payload))
+
+		var nilRunner *Runner
+		assert.NoError(t, nilRunner.Run(context.Background(), payload))
+	})
+
+	t.Run("runs every command with the warn policy", func(t *testing.T) {
+		var out bytes.Buffer
+		runner := &Runner{
+			Config: Config{Commands: map[Event][]string{OnAdd: {"exit 1", "echo ran", "exit 2"}}, FailurePolicy: FailureWarn},
+			Stdout: &out,
+		}
+		err := runner.Run(context.Background(), payload)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `on-add hook "exit 1" for context do-nyc1-prod failed`)
+		assert.Contains(t, err.Error(), `on-add hook "exit 2"`)
+		assert.Equal(t, "ran\n", out.String())
+	})
+
+	t.Run("stops at the first failure with the abort policy", func(t *testing.T) {
+		var out bytes.Buffer
+		runner := &Runner{
+			Config: Config{Commands: map[Event][]string{OnAdd: {"exit 1", "echo ran"}}, FailurePolicy: FailureAbort},
+			Stdout: &out,
+		}
+		assert.Error(t, runner.Run(context.Background(), payload))
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("kills commands that time out", func(t *testing.T) {
+		var out bytes.Buffer
+		runner := &Runner{
+			Config: Config{Commands: map[Event][]string{OnAdd: {"sleep 10"}}, Timeout: 100 * time.Millisecond},
+			Stdout: &out,
+			Stderr: &out,
+		}
+		start := time.Now()
+		err := runner.Run(context.Background(), payload)
+		assert.EqualError(t, err, `on-add hook "sleep 10" for context do-nyc1-prod timed out after 100ms`)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestPayloadEnvironment(t *testing.T) {
+	env := Payload{Event: PostSync, Kubeconfig: "/tmp/config", Added: []string{"a", "b"}, Removed: []string{"c"}}.Environment()
+	assert.Contains(t, env, "KUBECTL_DOKS_HOOK=post-sync")
+	assert.Contains(t, env, "KUBECTL_DOKS_ADDED=a,b")
+	assert.Contains(t, env, "KUBECTL_DOKS_UPDATED=")
+	assert.Contains(t, env, "KUBECTL_DOKS_REMOVED=c")
+	assert.NotContains(t, env, "KUBECTL_DOKS_CONTEXT=")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Commands: map[Event][]string{PostSync: {"true"}}, FailurePolicy: FailureAbort}.Validate())
+	assert.Error(t, Config{FailurePolicy: "ignore"}.Validate())
+	assert.Error(t, Config{Timeout: -time.Second}.Validate())
+	assert.Error(t, Config{Commands: map[Event][]string{"on-delete": {"true"}}}.Validate())
+}
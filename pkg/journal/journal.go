@@ -0,0 +1,170 @@
+// Package journal keeps the operations kubectl-doks made to kubeconfig files, each with the patch needed to
+// revert it, so that `kubectl doks undo` can revert a single operation without restoring a whole backup.
+package journal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/DO-Solutions/kubectl-doks/pkg/state"
+)
+
+// DirName is the name of the journal directory in the kubectl-doks directory.
+const DirName = "journal"
+
+// MaxOperations is the number of operations kept in the journal. Older operations are removed when new ones are saved.
+const MaxOperations = 50
+
+// ErrNotFound is returned when an operation is not in the journal.
+var ErrNotFound = errors.New("operation not found in the journal")
+
+// Operation is a change made to a kubeconfig by a kubectl-doks command.
+type Operation struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Command is the kubectl-doks command that made the change, such as "kubeconfig sync".
+	Command string `json:"command"`
+	// Kubeconfig is the path of the kubeconfig that was changed.
+	Kubeconfig string `json:"kubeconfig"`
+	// Patch holds the managed contexts and current context before and after the change.
+	Patch kubeconfig.Patch `json:"patch"`
+	// Undoes is the ID of the operation this one reverted, if it was made by undo.
+	Undoes string `json:"undoes,omitempty"`
+	// UndoneBy is the ID of the operation that reverted this one, if any.
+	UndoneBy string `json:"undone_by,omitempty"`
+}
+
+// DefaultDir returns the location of the journal, ~/.kube/kubectl-doks/journal.
+func DefaultDir() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DirName), nil
+}
+
+// NewID returns a new random operation ID.
+func NewID() string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Save writes op to the journal in dir, replacing any operation with the same ID, and removes the oldest
+// operations beyond MaxOperations. Operations hold credentials, so they are only readable by the user.
+func Save(dir string, op Operation) error {
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding operation: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, ".operation-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("writing operation: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), operationPath(dir, op.ID)); err != nil {
+		return fmt.Errorf("saving operation: %w", err)
+	}
+
+	operations, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for len(operations) > MaxOperations {
+		if err := os.Remove(operationPath(dir, operations[0].ID)); err != nil {
+			return fmt.Errorf("removing old operation: %w", err)
+		}
+		operations = operations[1:]
+	}
+	return nil
+}
+
+// Load returns the operation of the journal in dir with the given ID, or a unique prefix of it.
+func Load(dir, id string) (Operation, error) {
+	operations, err := List(dir)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	var matches []Operation
+	for _, op := range operations {
+		if op.ID == id {
+			return op, nil
+		}
+		if strings.HasPrefix(op.ID, id) {
+			matches = append(matches, op)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Operation{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return matches[0], nil
+	default:
+		return Operation{}, fmt.Errorf("operation ID %s is ambiguous: it matches %d operations", id, len(matches))
+	}
+}
+
+// Last returns the most recent operation of the journal in dir on the kubeconfig at path that can be undone:
+// one that has not been undone and is not itself an undo, so that repeated undos walk back through the journal.
+func Last(dir, path string) (Operation, error) {
+	operations, err := List(dir)
+	if err != nil {
+		return Operation{}, err
+	}
+	for i := len(operations) - 1; i >= 0; i-- {
+		op := operations[i]
+		if op.Kubeconfig == path && op.UndoneBy == "" && op.Undoes == "" {
+			return op, nil
+		}
+	}
+	return Operation{}, fmt.Errorf("%w: no operation on %s left to undo", ErrNotFound, path)
+}
+
+// List returns the operations of the journal in dir, oldest first. A missing journal has no operations.
+func List(dir string) ([]Operation, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing journal: %w", err)
+	}
+
+	var operations []Operation
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading operation: %w", err)
+		}
+		var op Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return nil, fmt.Errorf("parsing operation %s: %w", path, err)
+		}
+		operations = append(operations, op)
+	}
+	sort.SliceStable(operations, func(i, j int) bool { return operations[i].Time.Before(operations[j].Time) })
+	return operations, nil
+}
+
+// operationPath returns the path of the file of an operation in the journal in dir.
+func operationPath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
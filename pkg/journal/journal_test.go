@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DirName)
+
+	operations, err := List(dir)
+	require.NoError(t, err)
+	assert.Empty(t, operations, "A missing journal should have no operations")
+
+	now := time.Now().UTC()
+	sync := Operation{ID: "aaaa1111", Time: now.Add(-time.Minute), Command: "kubeconfig sync", Kubeconfig: "/home/a/.kube/config",
+		Patch: kubeconfig.Patch{Contexts: []kubeconfig.ContextChange{{Context: "do-nyc1-prod", After: "apiVersion: v1\n"}}}}
+	gc := Operation{ID: "aabb2222", Time: now, Command: "kubeconfig gc", Kubeconfig: "/home/a/.kube/config"}
+	require.NoError(t, Save(dir, sync))
+	require.NoError(t, Save(dir, gc))
+
+	info, err := os.Stat(filepath.Join(dir, "aaaa1111.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	operations, err = List(dir)
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+	assert.Equal(t, "aaaa1111", operations[0].ID)
+	assert.Equal(t, sync.Patch, operations[0].Patch)
+
+	op, err := Load(dir, "aabb")
+	require.NoError(t, err, "A unique prefix should match")
+	assert.Equal(t, "kubeconfig gc", op.Command)
+	_, err = Load(dir, "aa")
+	assert.ErrorContains(t, err, "ambiguous")
+	_, err = Load(dir, "ffff")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestLast(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	for i, op := range []Operation{
+		{ID: "sync", Kubeconfig: "/config"},
+		{ID: "save", Kubeconfig: "/config", UndoneBy: "undo"},
+		{ID: "other", Kubeconfig: "/other"},
+		{ID: "undo", Kubeconfig: "/config", Undoes: "save"},
+	} {
+		op.Time = now.Add(time.Duration(i) * time.Second)
+		require.NoError(t, Save(dir, op))
+	}
+
+	op, err := Last(dir, "/config")
+	require.NoError(t, err)
+	assert.Equal(t, "sync", op.ID, "Undone operations and undos should be skipped")
+
+	_, err = Last(dir, "/missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestSaveRemovesOldOperations(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	for i := range MaxOperations + 2 {
+		require.NoError(t, Save(dir, Operation{ID: fmt.Sprintf("op%03d", i), Time: now.Add(time.Duration(i) * time.Second)}))
+	}
+
+	operations, err := List(dir)
+	require.NoError(t, err)
+	require.Len(t, operations, MaxOperations)
+	assert.Equal(t, "op002", operations[0].ID)
+}
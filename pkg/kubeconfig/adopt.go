@@ -0,0 +1,101 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Ways an Adoption was matched to its cluster.
+const (
+	MatchedByServer = "server URL"
+	MatchedByName   = "name"
+)
+
+// Adoption is a context that follows the DOKS naming but was not written by kubectl-doks,
+// such as one saved by `doctl kubernetes cluster kubeconfig save`, matched to a live cluster.
+type Adoption struct {
+	ContextName string
+	Cluster     do.Cluster
+	// MatchedBy is MatchedByServer or MatchedByName.
+	MatchedBy string
+}
+
+// FindAdoptions returns the contexts of config that follow the DOKS naming but whose cluster entry carries no
+// cluster ID, matched to clusters by API server URL or, failing that, by context name. contextNames gives the
+// context name used for each cluster, keyed by cluster ID. Contexts matching no cluster are not returned.
+// The adoptions are sorted by context name.
+func FindAdoptions(config *k8sclientcmdapi.Config, clusters []do.Cluster, contextNames map[string]string) []Adoption {
+	byServer := make(map[string]do.Cluster)
+	byName := make(map[string]do.Cluster)
+	for _, cluster := range clusters {
+		if cluster.Endpoint != "" {
+			byServer[strings.TrimSuffix(cluster.Endpoint, "/")] = cluster
+		}
+		byName[contextNames[cluster.ID]] = cluster
+	}
+
+	var adoptions []Adoption
+	for contextName, context := range config.Contexts {
+		if !isManagedContext(contextName, context) {
+			continue
+		}
+		entry, ok := config.Clusters[context.Cluster]
+		if !ok {
+			continue
+		}
+		if _, ok := GetClusterID(entry); ok {
+			continue
+		}
+
+		if cluster, ok := byServer[strings.TrimSuffix(entry.Server, "/")]; ok {
+			adoptions = append(adoptions, Adoption{ContextName: contextName, Cluster: cluster, MatchedBy: MatchedByServer})
+		} else if cluster, ok := byName[contextName]; ok {
+			adoptions = append(adoptions, Adoption{ContextName: contextName, Cluster: cluster, MatchedBy: MatchedByName})
+		}
+	}
+
+	sort.Slice(adoptions, func(i, j int) bool { return adoptions[i].ContextName < adoptions[j].ContextName })
+	return adoptions
+}
+
+// UsesDoctlExec reports whether the user of a context gets its credentials by running doctl as an exec plugin.
+func UsesDoctlExec(config *k8sclientcmdapi.Config, contextName string) bool {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return false
+	}
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok || authInfo.Exec == nil {
+		return false
+	}
+	command := strings.TrimSuffix(filepath.Base(authInfo.Exec.Command), ".exe")
+	return command == "doctl"
+}
+
+// Adopt brings the context of adoption under the management of kubectl-doks under contextName:
+// its entries are renamed to contextName if needed, and its cluster entry is tagged with the details of the cluster.
+// The credentials are left as they are. It fails if the entries need to be renamed and contextName, or the cluster
+// or user entry it would be given, is already taken.
+func (r *Reconciler) Adopt(adoption Adoption, contextName string) error {
+	if adoption.ContextName != contextName {
+		if _, exists := r.config.Contexts[contextName]; exists {
+			return fmt.Errorf("context %q already exists", contextName)
+		}
+		if _, exists := r.config.Clusters[contextName]; exists {
+			return fmt.Errorf("cluster %q already exists", contextName)
+		}
+		if _, exists := r.config.AuthInfos[contextName+"-admin"]; exists {
+			return fmt.Errorf("user %q already exists", contextName+"-admin")
+		}
+		if err := renameContext(r.config, adoption.ContextName, contextName); err != nil {
+			return err
+		}
+	}
+	SetExtension(r.config.Clusters[contextName], ClusterExtension(adoption.Cluster))
+	return nil
+}
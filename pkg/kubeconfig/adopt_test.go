@@ -0,0 +1,182 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const doctlKubeconfig = `apiVersion: v1
+kind: Config
+current-context: do-nyc1-prod
+clusters:
+- name: do-nyc1-prod
+  cluster:
+    server: https://prod-id.k8s.ondigitalocean.com
+- name: do-sfo3-staging
+  cluster:
+    server: https://old-staging.k8s.ondigitalocean.com
+- name: do-nyc1-renamed
+  cluster:
+    server: https://renamed-id.k8s.ondigitalocean.com/
+- name: do-nyc1-managed
+  cluster:
+    server: https://managed-id.k8s.ondigitalocean.com
+    extensions:
+    - name: digitalocean.com/cluster-id
+      extension:
+        id: managed-id
+- name: do-nyc1-gone
+  cluster:
+    server: https://gone-id.k8s.ondigitalocean.com
+- name: prod
+  cluster:
+    server: https://prod-id.k8s.ondigitalocean.com
+users:
+- name: do-nyc1-prod-admin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: doctl
+      args:
+      - kubernetes
+      - cluster
+      - kubeconfig
+      - exec-credential
+      - --version=v1beta1
+      - prod-id
+- name: do-sfo3-staging-admin
+  user:
+    token: staging-token
+- name: do-nyc1-renamed-admin
+  user:
+    token: renamed-token
+- name: do-nyc1-managed-admin
+  user:
+    token: managed-token
+- name: do-nyc1-gone-admin
+  user:
+    token: gone-token
+- name: prod
+  user:
+    token: prod-token
+contexts:
+- name: do-nyc1-prod
+  context:
+    cluster: do-nyc1-prod
+    user: do-nyc1-prod-admin
+- name: do-sfo3-staging
+  context:
+    cluster: do-sfo3-staging
+    user: do-sfo3-staging-admin
+- name: do-nyc1-renamed
+  context:
+    cluster: do-nyc1-renamed
+    user: do-nyc1-renamed-admin
+- name: do-nyc1-managed
+  context:
+    cluster: do-nyc1-managed
+    user: do-nyc1-managed-admin
+- name: do-nyc1-gone
+  context:
+    cluster: do-nyc1-gone
+    user: do-nyc1-gone-admin
+- name: prod
+  context:
+    cluster: prod
+    user: prod
+`
+
+func adoptClusters() ([]do.Cluster, map[string]string) {
+	clusters := []do.Cluster{
+		{ID: "prod-id", Name: "prod", Region: "nyc1", Endpoint: "https://prod-id.k8s.ondigitalocean.com"},
+		{ID: "staging-id", Name: "staging", Region: "sfo3", Endpoint: "https://staging-id.k8s.ondigitalocean.com"},
+		{ID: "renamed-id", Name: "new-name", Region: "nyc1", Endpoint: "https://renamed-id.k8s.ondigitalocean.com"},
+		{ID: "managed-id", Name: "managed", Region: "nyc1", Endpoint: "https://managed-id.k8s.ondigitalocean.com"},
+	}
+	contextNames := make(map[string]string)
+	for _, cluster := range clusters {
+		contextNames[cluster.ID] = ContextName(cluster)
+	}
+	return clusters, contextNames
+}
+
+func TestFindAdoptions(t *testing.T) {
+	reconciler, err := NewReconciler([]byte(doctlKubeconfig))
+	require.NoError(t, err)
+	clusters, contextNames := adoptClusters()
+
+	adoptions := FindAdoptions(reconciler.Config(), clusters, contextNames)
+	require.Len(t, adoptions, 3)
+	assert.Equal(t, Adoption{ContextName: "do-nyc1-prod", Cluster: clusters[0], MatchedBy: MatchedByServer}, adoptions[0])
+	assert.Equal(t, Adoption{ContextName: "do-nyc1-renamed", Cluster: clusters[2], MatchedBy: MatchedByServer}, adoptions[1])
+	assert.Equal(t, Adoption{ContextName: "do-sfo3-staging", Cluster: clusters[1], MatchedBy: MatchedByName}, adoptions[2])
+}
+
+func TestUsesDoctlExec(t *testing.T) {
+	reconciler, err := NewReconciler([]byte(doctlKubeconfig))
+	require.NoError(t, err)
+
+	assert.True(t, UsesDoctlExec(reconciler.Config(), "do-nyc1-prod"))
+	assert.False(t, UsesDoctlExec(reconciler.Config(), "do-sfo3-staging"))
+	assert.False(t, UsesDoctlExec(reconciler.Config(), "do-nyc1-missing"))
+}
+
+func TestReconcilerAdopt(t *testing.T) {
+	reconciler, err := NewReconciler([]byte(doctlKubeconfig))
+	require.NoError(t, err)
+	clusters, contextNames := adoptClusters()
+	config := reconciler.Config()
+
+	for _, adoption := range FindAdoptions(config, clusters, contextNames) {
+		require.NoError(t, reconciler.Adopt(adoption, contextNames[adoption.Cluster.ID]))
+	}
+
+	extension, found := GetExtension(config.Clusters["do-nyc1-prod"])
+	require.True(t, found)
+	assert.Equal(t, "prod-id", extension.ClusterID)
+	assert.Equal(t, "nyc1", extension.Region)
+	assert.NotNil(t, config.AuthInfos["do-nyc1-prod-admin"].Exec, "Adopting should keep the credentials")
+
+	assert.NotContains(t, config.Contexts, "do-nyc1-renamed")
+	require.Contains(t, config.Contexts, "do-nyc1-new-name")
+	assert.Equal(t, "do-nyc1-new-name-admin", config.Contexts["do-nyc1-new-name"].AuthInfo)
+	assert.Equal(t, "renamed-token", config.AuthInfos["do-nyc1-new-name-admin"].Token)
+	id, _ := GetClusterID(config.Clusters["do-nyc1-new-name"])
+	assert.Equal(t, "renamed-id", id)
+
+	// Nothing is left to adopt, and renaming onto an existing context fails.
+	assert.Empty(t, FindAdoptions(config, clusters, contextNames))
+	assert.Error(t, reconciler.Adopt(Adoption{ContextName: "do-nyc1-gone", Cluster: clusters[0]}, "do-nyc1-prod"))
+}
+
+func TestReconcilerAdoptEntryCollisions(t *testing.T) {
+	clusters, _ := adoptClusters()
+	for _, tt := range []struct {
+		name     string
+		collide  func(config *k8sclientcmdapi.Config)
+		expected string
+	}{
+		{"cluster", func(config *k8sclientcmdapi.Config) {
+			config.Clusters["do-nyc1-taken"] = &k8sclientcmdapi.Cluster{Server: "https://taken-server"}
+		}, `cluster "do-nyc1-taken" already exists`},
+		{"user", func(config *k8sclientcmdapi.Config) {
+			config.AuthInfos["do-nyc1-taken-admin"] = &k8sclientcmdapi.AuthInfo{Token: "taken-token"}
+		}, `user "do-nyc1-taken-admin" already exists`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciler, err := NewReconciler([]byte(doctlKubeconfig))
+			require.NoError(t, err)
+			config := reconciler.Config()
+			tt.collide(config)
+
+			err = reconciler.Adopt(Adoption{ContextName: "do-nyc1-prod", Cluster: clusters[0]}, "do-nyc1-taken")
+			assert.ErrorContains(t, err, tt.expected)
+			assert.Contains(t, config.Contexts, "do-nyc1-prod", "Nothing should be renamed")
+			assert.NotNil(t, config.AuthInfos["do-nyc1-prod-admin"].Exec)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package kubeconfig
+
+import (
+	"github.com/DO-Solutions/kubectl-doks/do"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// CredentialsConfig returns a kubeconfig holding the cluster, user and context entries for a DOKS cluster,
+// built from the credentials returned by the DigitalOcean API. The entries follow the naming of the
+// DigitalOcean kubeconfig endpoint: the cluster and context are named contextName and the user contextName-admin.
+// The current context is not set.
+func CredentialsConfig(contextName string, credentials do.Credentials) *k8sclientcmdapi.Config {
+	config := k8sclientcmdapi.NewConfig()
+
+	cluster := k8sclientcmdapi.NewCluster()
+	cluster.Server = credentials.Server
+	cluster.CertificateAuthorityData = credentials.CertificateAuthorityData
+	config.Clusters[contextName] = cluster
+
+	authInfo := k8sclientcmdapi.NewAuthInfo()
+	authInfo.Token = credentials.Token
+	authInfo.ClientCertificateData = credentials.ClientCertificateData
+	authInfo.ClientKeyData = credentials.ClientKeyData
+	config.AuthInfos[contextName+"-admin"] = authInfo
+
+	context := k8sclientcmdapi.NewContext()
+	context.Cluster = contextName
+	context.AuthInfo = contextName + "-admin"
+	config.Contexts[contextName] = context
+
+	return config
+}
@@ -0,0 +1,424 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// errNotEditable is returned by editConfig when the original kubeconfig cannot be edited in place.
+var errNotEditable = errors.New("kubeconfig cannot be edited in place")
+
+// kubeconfig sections holding named entries, in the order clientcmd writes them.
+const (
+	sectionClusters = "clusters"
+	sectionContexts = "contexts"
+	sectionUsers    = "users"
+)
+
+// EditConfig serializes config, keeping the bytes of original wherever they still describe config.
+// Clusters, contexts and users that did not change keep their exact text, including comments, ordering and
+// formatting; changed entries are rewritten in place, new entries are appended to their list and removed
+// entries are deleted. The current-context line is only rewritten if it changed.
+// If original cannot be edited in place, for example because it is empty, uses flow style, or fields other than
+// the named entries and the current context changed, the whole config is serialized with clientcmd.Write instead.
+func EditConfig(original []byte, config *k8sclientcmdapi.Config) ([]byte, error) {
+	// An original that cannot be parsed is simply replaced.
+	originalConfig, _ := k8sclientcmd.Load(original)
+	return editOrWriteConfig(original, originalConfig, config)
+}
+
+// editOrWriteConfig is EditConfig for callers that already parsed original into originalConfig.
+// originalConfig can be nil if original could not be parsed.
+func editOrWriteConfig(original []byte, originalConfig, config *k8sclientcmdapi.Config) ([]byte, error) {
+	if originalConfig != nil {
+		edited, err := editConfig(original, originalConfig, config)
+		if err == nil {
+			return edited, nil
+		}
+		if !errors.Is(err, errNotEditable) {
+			return nil, err
+		}
+	}
+
+	written, err := k8sclientcmd.Write(*config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize kubeconfig: %v", err)
+	}
+	return written, nil
+}
+
+// lineEdit replaces the lines [start, end) of a document with text. An edit with start == end inserts text.
+type lineEdit struct {
+	start, end int
+	text       string
+}
+
+// editConfig edits original, parsed into originalConfig, in place to match config, or returns errNotEditable.
+func editConfig(original []byte, originalConfig, config *k8sclientcmdapi.Config) ([]byte, error) {
+	if len(bytes.TrimSpace(original)) == 0 || bytes.Contains(original, []byte("\r\n")) {
+		return nil, errNotEditable
+	}
+
+	// Only the named entries and the current context are edited in place.
+	sameOther, err := sameOtherFields(originalConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	if !sameOther {
+		return nil, errNotEditable
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(original, &document); err != nil || len(document.Content) != 1 {
+		return nil, errNotEditable
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+		return nil, errNotEditable
+	}
+
+	lines := strings.SplitAfter(string(original), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var edits []lineEdit
+	for _, section := range []string{sectionClusters, sectionContexts, sectionUsers} {
+		sectionEdits, err := editSection(lines, root, section, originalConfig, config)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, sectionEdits...)
+	}
+
+	if originalConfig.CurrentContext != config.CurrentContext {
+		edit, err := editCurrentContext(lines, root, config.CurrentContext)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+
+	if len(edits) == 0 {
+		return original, nil
+	}
+
+	edited := applyLineEdits(lines, edits)
+
+	// Make sure the edited document describes exactly config, and fall back to a full rewrite otherwise.
+	editedConfig, err := k8sclientcmd.Load(edited)
+	if err != nil {
+		return nil, errNotEditable
+	}
+	same, err := sameConfig(editedConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, errNotEditable
+	}
+	return edited, nil
+}
+
+// editSection returns the edits that make the entries of a section of the document match config.
+func editSection(lines []string, root *yaml.Node, section string, originalConfig, config *k8sclientcmdapi.Config) ([]lineEdit, error) {
+	wanted := sectionNames(config, section)
+	key, value := mappingValue(root, section)
+
+	if key == nil || value.Kind != yaml.SequenceNode || value.Style&yaml.FlowStyle != 0 || len(value.Content) == 0 {
+		if len(wanted) == 0 {
+			return nil, nil
+		}
+		// The section is missing, null or an empty flow sequence: write it as a new block sequence.
+		if key == nil {
+			items, err := entriesText(config, section, wanted, 0)
+			if err != nil {
+				return nil, err
+			}
+			return []lineEdit{{start: len(lines), end: len(lines), text: section + ":\n" + items}}, nil
+		}
+		if value.Line != key.Line || value.LineComment != "" || (value.Kind != yaml.ScalarNode && value.Kind != yaml.SequenceNode) {
+			return nil, errNotEditable
+		}
+		items, err := entriesText(config, section, wanted, key.Column-1)
+		if err != nil {
+			return nil, err
+		}
+		line := key.Line - 1
+		prefix := strings.TrimRight(lines[line][:value.Column-1], " ")
+		return []lineEdit{{start: line, end: line + 1, text: prefix + "\n" + items}}, nil
+	}
+
+	var edits []lineEdit
+	present := make(map[string]bool)
+	insertAt, dashColumn := 0, 0
+	for _, item := range value.Content {
+		start, end, column, ok := itemLines(lines, item)
+		if !ok {
+			return nil, errNotEditable
+		}
+		insertAt, dashColumn = end, column
+
+		_, nameNode := mappingValue(item, "name")
+		if nameNode == nil || nameNode.Kind != yaml.ScalarNode {
+			return nil, errNotEditable
+		}
+		name := nameNode.Value
+		if present[name] {
+			return nil, errNotEditable
+		}
+		present[name] = true
+
+		if !hasEntry(config, section, name) {
+			edits = append(edits, lineEdit{start: start, end: end})
+			continue
+		}
+
+		same, err := sameEntry(originalConfig, config, section, name)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			continue
+		}
+		text, err := entriesText(config, section, []string{name}, dashColumn)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, lineEdit{start: start, end: end, text: text})
+	}
+
+	var added []string
+	for _, name := range wanted {
+		if !present[name] {
+			added = append(added, name)
+		}
+	}
+	if len(added) > 0 {
+		text, err := entriesText(config, section, added, dashColumn)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, lineEdit{start: insertAt, end: insertAt, text: text})
+	}
+	return edits, nil
+}
+
+// editCurrentContext returns the edit that sets the current context of the document.
+func editCurrentContext(lines []string, root *yaml.Node, currentContext string) (lineEdit, error) {
+	scalar, err := yaml.Marshal(currentContext)
+	if err != nil {
+		return lineEdit{}, fmt.Errorf("failed to serialize current context: %v", err)
+	}
+	formatted := strings.TrimSuffix(string(scalar), "\n")
+
+	key, value := mappingValue(root, "current-context")
+	if key == nil {
+		return lineEdit{start: len(lines), end: len(lines), text: "current-context: " + formatted + "\n"}, nil
+	}
+	if value.Kind != yaml.ScalarNode || value.Line != key.Line || strings.Contains(formatted, "\n") {
+		return lineEdit{}, errNotEditable
+	}
+
+	line := key.Line - 1
+	text := lines[line][:value.Column-1] + formatted
+	if value.LineComment != "" {
+		text += " " + value.LineComment
+	}
+	return lineEdit{start: line, end: line + 1, text: text + "\n"}, nil
+}
+
+// itemLines returns the range of lines [start, end) of a block sequence item, along with the column of its dash.
+// Trailing blank lines are not part of the item.
+func itemLines(lines []string, item *yaml.Node) (int, int, int, bool) {
+	start := item.Line - 1
+	if item.Kind != yaml.MappingNode || start < 0 || start >= len(lines) {
+		return 0, 0, 0, false
+	}
+
+	line := lines[start]
+	dash := strings.LastIndex(line[:min(item.Column-1, len(line))], "-")
+	if dash < 0 || strings.TrimSpace(line[:dash]) != "" {
+		return 0, 0, 0, false
+	}
+
+	end := start + 1
+	for end < len(lines) && (isBlank(lines[end]) || indentation(lines[end]) > dash) {
+		end++
+	}
+	for end > start+1 && isBlank(lines[end-1]) {
+		end--
+	}
+	return start, end, dash, true
+}
+
+// applyLineEdits returns lines with edits applied.
+func applyLineEdits(lines []string, edits []lineEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out bytes.Buffer
+	next := 0
+	for _, edit := range edits {
+		for ; next < edit.start; next++ {
+			out.WriteString(lines[next])
+		}
+		if edit.text != "" && out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteString("\n")
+		}
+		out.WriteString(edit.text)
+		if edit.end > next {
+			next = edit.end
+		}
+	}
+	for ; next < len(lines); next++ {
+		out.WriteString(lines[next])
+	}
+	return out.Bytes()
+}
+
+// entriesText returns the YAML of the named entries of a section of config, as block sequence items
+// whose dash is at the given column.
+func entriesText(config *k8sclientcmdapi.Config, section string, names []string, column int) (string, error) {
+	subset := k8sclientcmdapi.NewConfig()
+	for _, name := range names {
+		switch section {
+		case sectionClusters:
+			subset.Clusters[name] = config.Clusters[name]
+		case sectionContexts:
+			subset.Contexts[name] = config.Contexts[name]
+		case sectionUsers:
+			subset.AuthInfos[name] = config.AuthInfos[name]
+		}
+	}
+
+	written, err := k8sclientcmd.Write(*subset)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize %s: %v", section, err)
+	}
+
+	// clientcmd writes the items of the section right after its key, with the dash at column 0.
+	var text strings.Builder
+	indent := strings.Repeat(" ", column)
+	inSection := false
+	for _, line := range strings.SplitAfter(string(written), "\n") {
+		if inSection && (strings.HasPrefix(line, "-") || strings.HasPrefix(line, " ")) {
+			text.WriteString(indent + line)
+			continue
+		}
+		inSection = line == section+":\n"
+	}
+	return text.String(), nil
+}
+
+// sameEntry reports whether the named entry of a section is the same in both configs.
+func sameEntry(a, b *k8sclientcmdapi.Config, section, name string) (bool, error) {
+	var x, y interface{}
+	switch section {
+	case sectionClusters:
+		x, y = a.Clusters[name], b.Clusters[name]
+	case sectionContexts:
+		x, y = a.Contexts[name], b.Contexts[name]
+	case sectionUsers:
+		x, y = a.AuthInfos[name], b.AuthInfos[name]
+	}
+	if reflect.DeepEqual(x, y) {
+		return true, nil
+	}
+
+	textA, err := entriesText(a, section, []string{name}, 0)
+	if err != nil {
+		return false, err
+	}
+	textB, err := entriesText(b, section, []string{name}, 0)
+	if err != nil {
+		return false, err
+	}
+	return textA == textB, nil
+}
+
+// sameOtherFields reports whether two configs are the same apart from their named entries and current context.
+func sameOtherFields(a, b *k8sclientcmdapi.Config) (bool, error) {
+	strip := func(config *k8sclientcmdapi.Config) *k8sclientcmdapi.Config {
+		stripped := *config
+		stripped.Clusters, stripped.Contexts, stripped.AuthInfos = nil, nil, nil
+		stripped.CurrentContext = ""
+		return &stripped
+	}
+	return sameConfig(strip(a), strip(b))
+}
+
+// sameConfig reports whether two configs serialize to the same YAML.
+func sameConfig(a, b *k8sclientcmdapi.Config) (bool, error) {
+	writtenA, err := k8sclientcmd.Write(*a)
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize kubeconfig: %v", err)
+	}
+	writtenB, err := k8sclientcmd.Write(*b)
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize kubeconfig: %v", err)
+	}
+	return bytes.Equal(writtenA, writtenB), nil
+}
+
+// sectionNames returns the sorted names of the entries of a section of config.
+func sectionNames(config *k8sclientcmdapi.Config, section string) []string {
+	var names []string
+	switch section {
+	case sectionClusters:
+		for name := range config.Clusters {
+			names = append(names, name)
+		}
+	case sectionContexts:
+		for name := range config.Contexts {
+			names = append(names, name)
+		}
+	case sectionUsers:
+		for name := range config.AuthInfos {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasEntry reports whether config has an entry with the given name in a section.
+func hasEntry(config *k8sclientcmdapi.Config, section, name string) bool {
+	var ok bool
+	switch section {
+	case sectionClusters:
+		_, ok = config.Clusters[name]
+	case sectionContexts:
+		_, ok = config.Contexts[name]
+	case sectionUsers:
+		_, ok = config.AuthInfos[name]
+	}
+	return ok
+}
+
+// mappingValue returns the key and value nodes of a key in a mapping node, or nil if the key is missing.
+func mappingValue(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// isBlank reports whether a line is empty or only contains whitespace.
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentation returns the number of leading spaces of a line.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
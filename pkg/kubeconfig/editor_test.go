@@ -0,0 +1,200 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// handEditedKubeconfig is a kubeconfig with comments and an ordering that clientcmd.Write would not produce.
+const handEditedKubeconfig = `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: kind-kind # my default
+clusters:
+  # Local clusters first.
+  - name: kind-kind
+    cluster:
+      server: https://127.0.0.1:6443
+  - name: do-nyc1-prod
+    cluster:
+      server: https://prod-server
+users:
+  - name: kind-kind
+    user:
+      token: kind-token
+  - name: do-nyc1-prod-admin
+    user:
+      token: prod-token
+contexts:
+  - name: kind-kind
+    context:
+      cluster: kind-kind
+      user: kind-kind
+  - name: do-nyc1-prod
+    context:
+      cluster: do-nyc1-prod
+      user: do-nyc1-prod-admin
+preferences: {}
+`
+
+func loadForEdit(t *testing.T, data string) *k8sclientcmdapi.Config {
+	t.Helper()
+	config, err := k8sclientcmd.Load([]byte(data))
+	require.NoError(t, err)
+	return config
+}
+
+// requireSameConfig checks that edited describes the same kubeconfig as config.
+func requireSameConfig(t *testing.T, config *k8sclientcmdapi.Config, edited []byte) {
+	t.Helper()
+	want, err := k8sclientcmd.Write(*config)
+	require.NoError(t, err)
+	editedConfig, err := k8sclientcmd.Load(edited)
+	require.NoError(t, err)
+	got, err := k8sclientcmd.Write(*editedConfig)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
+
+func TestEditConfigUnchanged(t *testing.T) {
+	config := loadForEdit(t, handEditedKubeconfig)
+
+	edited, err := EditConfig([]byte(handEditedKubeconfig), config)
+	require.NoError(t, err)
+	assert.Equal(t, handEditedKubeconfig, string(edited))
+}
+
+func TestEditConfigAddsEntries(t *testing.T) {
+	config := loadForEdit(t, handEditedKubeconfig)
+	config.Clusters["do-sfo3-dev"] = &k8sclientcmdapi.Cluster{Server: "https://dev-server"}
+	config.AuthInfos["do-sfo3-dev-admin"] = &k8sclientcmdapi.AuthInfo{Token: "dev-token"}
+	config.Contexts["do-sfo3-dev"] = &k8sclientcmdapi.Context{Cluster: "do-sfo3-dev", AuthInfo: "do-sfo3-dev-admin"}
+
+	edited, err := EditConfig([]byte(handEditedKubeconfig), config)
+	require.NoError(t, err)
+	requireSameConfig(t, config, edited)
+
+	// Everything that was there is kept as is; the new entries are appended to their lists, with the same indentation.
+	expected := strings.Replace(handEditedKubeconfig, `      server: https://prod-server
+`, `      server: https://prod-server
+  - cluster:
+      server: https://dev-server
+    name: do-sfo3-dev
+`, 1)
+	expected = strings.Replace(expected, `      token: prod-token
+`, `      token: prod-token
+  - name: do-sfo3-dev-admin
+    user:
+      token: dev-token
+`, 1)
+	expected = strings.Replace(expected, `      user: do-nyc1-prod-admin
+`, `      user: do-nyc1-prod-admin
+  - context:
+      cluster: do-sfo3-dev
+      user: do-sfo3-dev-admin
+    name: do-sfo3-dev
+`, 1)
+	assert.Equal(t, expected, string(edited))
+}
+
+func TestEditConfigUpdatesAndRemovesEntries(t *testing.T) {
+	config := loadForEdit(t, handEditedKubeconfig)
+	config.AuthInfos["do-nyc1-prod-admin"].Token = "new-prod-token"
+	delete(config.Clusters, "kind-kind")
+	delete(config.AuthInfos, "kind-kind")
+	delete(config.Contexts, "kind-kind")
+	config.CurrentContext = "do-nyc1-prod"
+
+	edited, err := EditConfig([]byte(handEditedKubeconfig), config)
+	require.NoError(t, err)
+	requireSameConfig(t, config, edited)
+
+	assert.Equal(t, `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: do-nyc1-prod # my default
+clusters:
+  # Local clusters first.
+  - name: do-nyc1-prod
+    cluster:
+      server: https://prod-server
+users:
+  - name: do-nyc1-prod-admin
+    user:
+      token: new-prod-token
+contexts:
+  - name: do-nyc1-prod
+    context:
+      cluster: do-nyc1-prod
+      user: do-nyc1-prod-admin
+preferences: {}
+`, string(edited))
+}
+
+func TestEditConfigNullSections(t *testing.T) {
+	original := `apiVersion: v1
+clusters: null
+contexts: []
+current-context: ""
+kind: Config
+preferences: {}
+users: null
+`
+	config := loadForEdit(t, original)
+	config.Clusters["do-sfo3-dev"] = &k8sclientcmdapi.Cluster{Server: "https://dev-server"}
+	config.AuthInfos["do-sfo3-dev-admin"] = &k8sclientcmdapi.AuthInfo{Token: "dev-token"}
+	config.Contexts["do-sfo3-dev"] = &k8sclientcmdapi.Context{Cluster: "do-sfo3-dev", AuthInfo: "do-sfo3-dev-admin"}
+	config.CurrentContext = "do-sfo3-dev"
+
+	edited, err := EditConfig([]byte(original), config)
+	require.NoError(t, err)
+	requireSameConfig(t, config, edited)
+
+	written, err := k8sclientcmd.Write(*config)
+	require.NoError(t, err)
+	assert.Equal(t, string(written), string(edited), "A kubeconfig written by clientcmd should be edited into what clientcmd writes")
+}
+
+func TestEditConfigMissingSectionsAndNoTrailingNewline(t *testing.T) {
+	original := "apiVersion: v1\nkind: Config # hand written"
+	config := loadForEdit(t, original)
+	config.Clusters["do-sfo3-dev"] = &k8sclientcmdapi.Cluster{Server: "https://dev-server"}
+
+	edited, err := EditConfig([]byte(original), config)
+	require.NoError(t, err)
+	requireSameConfig(t, config, edited)
+	assert.True(t, strings.HasPrefix(string(edited), original+"\nclusters:\n- cluster:\n"))
+}
+
+func TestEditConfigFallsBackToWrite(t *testing.T) {
+	config := k8sclientcmdapi.NewConfig()
+	config.Clusters["do-sfo3-dev"] = &k8sclientcmdapi.Cluster{Server: "https://dev-server"}
+	written, err := k8sclientcmd.Write(*config)
+	require.NoError(t, err)
+
+	t.Run("empty original", func(t *testing.T) {
+		edited, err := EditConfig(nil, config)
+		require.NoError(t, err)
+		assert.Equal(t, string(written), string(edited))
+	})
+
+	t.Run("flow style original", func(t *testing.T) {
+		edited, err := EditConfig([]byte(`{"apiVersion": "v1", "kind": "Config", "clusters": []}`), config)
+		require.NoError(t, err)
+		assert.Equal(t, string(written), string(edited))
+	})
+
+	t.Run("other fields changed", func(t *testing.T) {
+		changed := loadForEdit(t, handEditedKubeconfig)
+		changed.Preferences.Colors = true
+		edited, err := EditConfig([]byte(handEditedKubeconfig), changed)
+		require.NoError(t, err)
+		requireSameConfig(t, changed, edited)
+		assert.NotContains(t, string(edited), "# Managed by my dotfiles.")
+	})
+}
@@ -0,0 +1,57 @@
+package kubeconfig
+
+import (
+	"sort"
+	"time"
+
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ExpiringContexts returns the names of the contexts managed by kubectl-doks whose user credentials
+// expire before deadline, sorted by name. Contexts without a recorded expiry are never returned.
+func ExpiringContexts(config *k8sclientcmdapi.Config, deadline time.Time) []string {
+	var expiring []string
+	for contextName, context := range config.Contexts {
+		if !isManagedContext(contextName, context) {
+			continue
+		}
+		authInfo, ok := config.AuthInfos[context.AuthInfo]
+		if !ok {
+			continue
+		}
+		if expiresAt, ok := GetCredentialsExpiry(authInfo); ok && expiresAt.Before(deadline) {
+			expiring = append(expiring, contextName)
+		}
+	}
+	sort.Strings(expiring)
+	return expiring
+}
+
+// CredentialsExpiry returns when the credentials of the user of a context expire.
+// It returns false if the context or its user does not exist, or no expiry was recorded.
+func CredentialsExpiry(config *k8sclientcmdapi.Config, contextName string) (time.Time, bool) {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return time.Time{}, false
+	}
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return time.Time{}, false
+	}
+	return GetCredentialsExpiry(authInfo)
+}
+
+// CredentialsLifetime returns the lifetime in seconds requested for the credentials of the user of a context.
+// It returns 0 if the context or its user does not exist, or no lifetime was recorded.
+func CredentialsLifetime(config *k8sclientcmdapi.Config, contextName string) int {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return 0
+	}
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return 0
+	}
+	extension, _ := GetUserExtension(authInfo)
+	return extension.ExpirySeconds
+}
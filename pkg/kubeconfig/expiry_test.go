@@ -0,0 +1,94 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestExpiringContexts(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	config := k8sclientcmdapi.NewConfig()
+	addContext := func(name string, expiresAt time.Time) {
+		config.Clusters[name] = k8sclientcmdapi.NewCluster()
+		config.AuthInfos[name+"-admin"] = k8sclientcmdapi.NewAuthInfo()
+		SetCredentialsExpiry(config.AuthInfos[name+"-admin"], expiresAt)
+		config.Contexts[name] = &k8sclientcmdapi.Context{Cluster: name, AuthInfo: name + "-admin"}
+	}
+	addContext("do-nyc1-expired", now.Add(-time.Minute))
+	addContext("do-nyc1-expiring", now.Add(30*time.Minute))
+	addContext("do-nyc1-fresh", now.Add(24*time.Hour))
+	addContext("do-nyc1-no-expiry", time.Time{})
+	addContext("kind-expired", now.Add(-time.Minute))
+
+	assert.Equal(t, []string{"do-nyc1-expired"}, ExpiringContexts(config, now))
+	assert.Equal(t, []string{"do-nyc1-expired", "do-nyc1-expiring"}, ExpiringContexts(config, now.Add(time.Hour)))
+
+	expiresAt, ok := CredentialsExpiry(config, "do-nyc1-expiring")
+	require.True(t, ok)
+	assert.Equal(t, now.Add(30*time.Minute), expiresAt)
+	_, ok = CredentialsExpiry(config, "do-nyc1-no-expiry")
+	assert.False(t, ok)
+	_, ok = CredentialsExpiry(config, "do-nyc1-missing")
+	assert.False(t, ok)
+}
+
+func TestReconcilerPruneExpired(t *testing.T) {
+	now := time.Now()
+	original := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: do-nyc1-incident
+clusters:
+- name: do-nyc1-incident
+  cluster:
+    server: https://incident-server
+- name: do-nyc1-prod
+  cluster:
+    server: https://prod-server
+users:
+- name: do-nyc1-incident-admin
+  user:
+    token: incident-token
+    extensions:
+    - name: digitalocean.com/cluster-id
+      extension:
+        expires_at: %[1]q
+- name: do-nyc1-prod-admin
+  user:
+    token: prod-token
+    extensions:
+    - name: digitalocean.com/cluster-id
+      extension:
+        expires_at: %[2]q
+contexts:
+- name: do-nyc1-incident
+  context:
+    cluster: do-nyc1-incident
+    user: do-nyc1-incident-admin
+- name: incident-readonly
+  context:
+    cluster: do-nyc1-incident
+    user: readonly
+- name: do-nyc1-prod
+  context:
+    cluster: do-nyc1-prod
+    user: do-nyc1-prod-admin
+`, now.Add(-time.Minute).UTC().Format(time.RFC3339), now.Add(time.Hour).UTC().Format(time.RFC3339))
+
+	reconciler, err := NewReconciler([]byte(original))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"do-nyc1-incident"}, reconciler.PruneExpired(now))
+
+	config := reconciler.Config()
+	assert.NotContains(t, config.Contexts, "do-nyc1-incident")
+	assert.NotContains(t, config.AuthInfos, "do-nyc1-incident-admin")
+	assert.Contains(t, config.Clusters, "do-nyc1-incident", "A cluster still used by another context should be kept")
+	assert.Contains(t, config.Contexts, "incident-readonly")
+	assert.Contains(t, config.Contexts, "do-nyc1-prod")
+	assert.Empty(t, config.CurrentContext, "The removed current context should be cleared")
+}
@@ -2,41 +2,212 @@ package kubeconfig
 
 import (
 	"encoding/json"
+	"time"
 
-	"k8s.io/client-go/tools/clientcmd/api"
+	"github.com/DO-Solutions/kubectl-doks/do"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
 )
 
 // DigitalOceanClusterIDExtension is the name of the extension used to store the DigitalOcean cluster ID.
 const DigitalOceanClusterIDExtension = "digitalocean.com/cluster-id"
 
+// ExtensionVersion is the version of the DigitalOcean extension written by kubectl-doks.
+// Version 1 is the original extension, which only held the cluster ID and had no version field.
+const ExtensionVersion = 2
+
+// ManagedBy is the value of Extension.ManagedBy for entries written by kubectl-doks.
+const ManagedBy = "kubectl-doks"
+
+// Extension is the DigitalOcean extension stored in kubeconfig entries, under DigitalOceanClusterIDExtension.
+// Cluster entries carry the details of the DOKS cluster, and user entries carry the expiry of their credentials.
+// Fields that are not known are left empty and omitted from the kubeconfig.
+type Extension struct {
+	Version           int    `json:"version"`
+	ClusterID         string `json:"id,omitempty"`
+	TeamUUID          string `json:"team_uuid,omitempty"`
+	TeamName          string `json:"team_name,omitempty"`
+	Region            string `json:"region,omitempty"`
+	KubernetesVersion string `json:"kubernetes_version,omitempty"`
+	AuthContext       string `json:"auth_context,omitempty"`
+	// Status is the last known state of the cluster, such as "running".
+	Status string `json:"status,omitempty"`
+	// SyncedAt is when the credentials of the cluster were last fetched.
+	SyncedAt time.Time `json:"synced_at,omitzero"`
+	// ExpiresAt is when the credentials of a user entry expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// ExpirySeconds is the lifetime requested for the credentials of a user entry, 0 for the API default.
+	ExpirySeconds int    `json:"expiry_seconds,omitempty"`
+	ManagedBy     string `json:"managed_by,omitempty"`
+}
+
+// ClusterExtension returns the extension describing cluster in the kubeconfig.
+// SyncedAt and ExpiresAt are left for the caller to set.
+func ClusterExtension(cluster do.Cluster) Extension {
+	return Extension{
+		ClusterID:         cluster.ID,
+		TeamUUID:          cluster.Team.UUID,
+		TeamName:          cluster.Team.Name,
+		Region:            cluster.Region,
+		KubernetesVersion: cluster.Version,
+		AuthContext:       cluster.AuthContext,
+		Status:            cluster.Status,
+	}
+}
+
+// GetExtension reads the DigitalOcean extension of a cluster entry.
+// Extensions written by older versions of kubectl-doks keep their Version, and are migrated when they are next set.
+// It returns false if the cluster has no DigitalOcean extension.
+func GetExtension(cluster *api.Cluster) (Extension, bool) {
+	return readExtension(cluster.Extensions)
+}
+
+// SetExtension replaces the DigitalOcean extension of a cluster entry, stamping it with the current version.
+func SetExtension(cluster *api.Cluster, extension Extension) {
+	cluster.Extensions = writeExtension(cluster.Extensions, extension)
+}
+
+// GetUserExtension reads the DigitalOcean extension of a user entry, like GetExtension.
+// It returns false if the user has no DigitalOcean extension.
+func GetUserExtension(authInfo *api.AuthInfo) (Extension, bool) {
+	return readExtension(authInfo.Extensions)
+}
+
+// SetUserExtension replaces the DigitalOcean extension of a user entry, stamping it with the current version.
+func SetUserExtension(authInfo *api.AuthInfo, extension Extension) {
+	authInfo.Extensions = writeExtension(authInfo.Extensions, extension)
+}
+
 // GetClusterID retrieves the DigitalOcean cluster ID from a kubeconfig cluster's extensions.
 // It returns the ID and true if the extension is found, otherwise it returns an empty string and false.
 func GetClusterID(cluster *api.Cluster) (string, bool) {
-	extension, ok := cluster.Extensions[DigitalOceanClusterIDExtension]
-	if !ok {
+	extension, ok := GetExtension(cluster)
+	if !ok || extension.ClusterID == "" {
 		return "", false
 	}
+	return extension.ClusterID, true
+}
 
-	unknown, ok := extension.(*runtime.Unknown)
+// SetClusterID adds or updates the DigitalOcean cluster ID in a kubeconfig cluster's extensions.
+func SetClusterID(cluster *api.Cluster, id string) {
+	extension, _ := GetExtension(cluster)
+	extension.ClusterID = id
+	SetExtension(cluster, extension)
+}
+
+// GetClusterSource retrieves the team and doctl auth context a cluster was synced from.
+// It returns false if the cluster has no DigitalOcean extension.
+func GetClusterSource(cluster *api.Cluster) (do.Team, string, bool) {
+	extension, ok := GetExtension(cluster)
 	if !ok {
-		return "", false
+		return do.Team{}, "", false
 	}
 
-	var data map[string]string
-	if err := json.Unmarshal(unknown.Raw, &data); err != nil {
+	team := do.Team{UUID: extension.TeamUUID, Name: extension.TeamName}
+	return team, extension.AuthContext, true
+}
+
+// SetClusterSource records the team and doctl auth context a cluster was synced from in its DigitalOcean extension.
+// Empty values are omitted.
+func SetClusterSource(cluster *api.Cluster, team do.Team, authContext string) {
+	extension, _ := GetExtension(cluster)
+	extension.TeamUUID = team.UUID
+	extension.TeamName = team.Name
+	extension.AuthContext = authContext
+	SetExtension(cluster, extension)
+}
+
+// GetClusterStatus retrieves the last known state of a cluster, such as "running" or "degraded".
+// It returns false if no state was recorded.
+func GetClusterStatus(cluster *api.Cluster) (string, bool) {
+	extension, ok := GetExtension(cluster)
+	if !ok || extension.Status == "" {
 		return "", false
 	}
+	return extension.Status, true
+}
 
-	id, ok := data["id"]
-	return id, ok
+// SetClusterStatus records the last known state of a cluster in its DigitalOcean extension.
+// An empty status removes the recorded state.
+func SetClusterStatus(cluster *api.Cluster, status string) {
+	extension, _ := GetExtension(cluster)
+	extension.Status = status
+	SetExtension(cluster, extension)
 }
 
-// SetClusterID adds or updates the DigitalOcean cluster ID in a kubeconfig cluster's extensions.
-func SetClusterID(cluster *api.Cluster, id string) {
-	if cluster.Extensions == nil {
-		cluster.Extensions = make(map[string]runtime.Object)
+// GetCredentialsExpiry retrieves when the credentials of a user entry expire.
+// It returns false if no expiry was recorded.
+func GetCredentialsExpiry(authInfo *api.AuthInfo) (time.Time, bool) {
+	extension, ok := GetUserExtension(authInfo)
+	if !ok || extension.ExpiresAt.IsZero() {
+		return time.Time{}, false
+	}
+	return extension.ExpiresAt, true
+}
+
+// SetCredentialsExpiry records when the credentials of a user entry expire in its DigitalOcean extension.
+// A zero time removes the recorded expiry.
+func SetCredentialsExpiry(authInfo *api.AuthInfo, expiresAt time.Time) {
+	extension, _ := GetUserExtension(authInfo)
+	extension.ExpiresAt = expiresAt
+	SetUserExtension(authInfo, extension)
+}
+
+// readExtension decodes the DigitalOcean extension from the extensions of a cluster or user entry.
+func readExtension(extensions map[string]runtime.Object) (Extension, bool) {
+	object, ok := extensions[DigitalOceanClusterIDExtension]
+	if !ok {
+		return Extension{}, false
 	}
 
-	cluster.Extensions[DigitalOceanClusterIDExtension] = &runtime.Unknown{Raw: []byte(`{"id":"` + id + `"}`)}
+	unknown, ok := object.(*runtime.Unknown)
+	if !ok {
+		return Extension{}, false
+	}
+
+	extension, err := decodeExtension(unknown.Raw)
+	if err != nil {
+		return Extension{}, false
+	}
+	return extension, true
+}
+
+// decodeExtension decodes the JSON form of the DigitalOcean extension.
+// Version 1 extensions, {"id": "<cluster ID>"} optionally followed by team, auth context, state and expiry fields
+// stored as strings, use the same field names as the current version but have no version field.
+func decodeExtension(raw []byte) (Extension, error) {
+	var extension Extension
+	if err := json.Unmarshal(raw, &extension); err != nil {
+		return Extension{}, err
+	}
+	if extension.Version == 0 {
+		extension.Version = 1
+	}
+	return extension, nil
+}
+
+// writeExtension stores extension in the extensions of a cluster or user entry and returns the updated extensions.
+func writeExtension(extensions map[string]runtime.Object, extension Extension) map[string]runtime.Object {
+	if extensions == nil {
+		extensions = make(map[string]runtime.Object)
+	}
+
+	extension.Version = ExtensionVersion
+	if extension.ManagedBy == "" {
+		extension.ManagedBy = ManagedBy
+	}
+	if !extension.SyncedAt.IsZero() {
+		extension.SyncedAt = extension.SyncedAt.UTC().Truncate(time.Second)
+	}
+	if !extension.ExpiresAt.IsZero() {
+		extension.ExpiresAt = extension.ExpiresAt.UTC().Truncate(time.Second)
+	}
+
+	raw, err := json.Marshal(extension)
+	if err != nil {
+		// The extension only holds strings, numbers and times, so it always marshals; keep the previous one if it somehow does not.
+		return extensions
+	}
+	extensions[DigitalOceanClusterIDExtension] = &runtime.Unknown{Raw: raw}
+	return extensions
 }
@@ -2,8 +2,12 @@ package kubeconfig
 
 import (
 	"testing"
+	"time"
 
+	"github.com/DO-Solutions/kubectl-doks/do"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -83,3 +87,130 @@ func TestSetClusterID(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterSource(t *testing.T) {
+	cluster := &api.Cluster{}
+	SetClusterID(cluster, "cluster-id")
+
+	_, _, found := GetClusterSource(&api.Cluster{})
+	assert.False(t, found, "cluster without extension should have no source")
+
+	SetClusterSource(cluster, do.Team{UUID: "team-uuid", Name: "My Team"}, "my-context")
+	team, authContext, found := GetClusterSource(cluster)
+	assert.True(t, found)
+	assert.Equal(t, do.Team{UUID: "team-uuid", Name: "My Team"}, team)
+	assert.Equal(t, "my-context", authContext)
+
+	// Setting the source must not clobber the cluster ID and vice versa.
+	id, found := GetClusterID(cluster)
+	assert.True(t, found)
+	assert.Equal(t, "cluster-id", id)
+
+	SetClusterID(cluster, "new-id")
+	team, _, _ = GetClusterSource(cluster)
+	assert.Equal(t, "My Team", team.Name)
+
+	// Empty values are removed.
+	SetClusterSource(cluster, do.Team{}, "")
+	team, authContext, found = GetClusterSource(cluster)
+	assert.True(t, found)
+	assert.Empty(t, team.Name)
+	assert.Empty(t, authContext)
+}
+
+func TestClusterStatus(t *testing.T) {
+	cluster := &api.Cluster{}
+	_, found := GetClusterStatus(cluster)
+	assert.False(t, found)
+
+	SetClusterID(cluster, "cluster-id")
+	SetClusterStatus(cluster, "degraded")
+	status, found := GetClusterStatus(cluster)
+	assert.True(t, found)
+	assert.Equal(t, "degraded", status)
+
+	id, _ := GetClusterID(cluster)
+	assert.Equal(t, "cluster-id", id)
+
+	SetClusterStatus(cluster, "")
+	_, found = GetClusterStatus(cluster)
+	assert.False(t, found)
+}
+
+func TestCredentialsExpiry(t *testing.T) {
+	authInfo := &api.AuthInfo{}
+	_, found := GetCredentialsExpiry(authInfo)
+	assert.False(t, found)
+
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60))
+	SetCredentialsExpiry(authInfo, expiresAt)
+	got, found := GetCredentialsExpiry(authInfo)
+	assert.True(t, found)
+	assert.True(t, expiresAt.Equal(got))
+	assert.Equal(t, time.UTC, got.Location())
+
+	SetCredentialsExpiry(authInfo, time.Time{})
+	_, found = GetCredentialsExpiry(authInfo)
+	assert.False(t, found)
+}
+
+func TestExtensionMigratesVersion1(t *testing.T) {
+	cluster := &api.Cluster{
+		Extensions: map[string]runtime.Object{
+			DigitalOceanClusterIDExtension: &runtime.Unknown{Raw: []byte(`{"id":"test-id","team_name":"My Team","status":"running"}`)},
+		},
+	}
+
+	extension, found := GetExtension(cluster)
+	assert.True(t, found)
+	assert.Equal(t, Extension{Version: 1, ClusterID: "test-id", TeamName: "My Team", Status: "running"}, extension)
+
+	SetClusterStatus(cluster, "degraded")
+	extension, _ = GetExtension(cluster)
+	assert.Equal(t, Extension{Version: ExtensionVersion, ClusterID: "test-id", TeamName: "My Team", Status: "degraded", ManagedBy: ManagedBy}, extension)
+	assert.JSONEq(t, `{"version":2,"id":"test-id","team_name":"My Team","status":"degraded","managed_by":"kubectl-doks"}`,
+		string(cluster.Extensions[DigitalOceanClusterIDExtension].(*runtime.Unknown).Raw))
+}
+
+func TestExtensionRoundTrip(t *testing.T) {
+	syncedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cluster := ClusterExtension(do.Cluster{
+		ID:          "cluster-id",
+		Region:      "nyc1",
+		Status:      "running",
+		Version:     "1.31.1-do.0",
+		Team:        do.Team{UUID: "team-uuid", Name: "My Team"},
+		AuthContext: "my-context",
+	})
+	cluster.SyncedAt = syncedAt
+
+	config := api.NewConfig()
+	config.Clusters["do-nyc1-test"] = api.NewCluster()
+	SetExtension(config.Clusters["do-nyc1-test"], cluster)
+	config.AuthInfos["do-nyc1-test-admin"] = api.NewAuthInfo()
+	SetUserExtension(config.AuthInfos["do-nyc1-test-admin"], Extension{ExpiresAt: syncedAt.Add(time.Hour)})
+
+	data, err := k8sclientcmd.Write(*config)
+	require.NoError(t, err)
+	loaded, err := k8sclientcmd.Load(data)
+	require.NoError(t, err)
+
+	extension, found := GetExtension(loaded.Clusters["do-nyc1-test"])
+	require.True(t, found)
+	assert.Equal(t, Extension{
+		Version:           ExtensionVersion,
+		ClusterID:         "cluster-id",
+		TeamUUID:          "team-uuid",
+		TeamName:          "My Team",
+		Region:            "nyc1",
+		KubernetesVersion: "1.31.1-do.0",
+		AuthContext:       "my-context",
+		Status:            "running",
+		SyncedAt:          syncedAt,
+		ManagedBy:         ManagedBy,
+	}, extension)
+
+	expiresAt, found := GetCredentialsExpiry(loaded.AuthInfos["do-nyc1-test-admin"])
+	assert.True(t, found)
+	assert.True(t, syncedAt.Add(time.Hour).Equal(expiresAt))
+}
@@ -46,8 +46,8 @@ func MergeConfig(srcConfig, newConfig []byte, setCurrentContext bool) ([]byte, e
 		configObj.CurrentContext = newConfigObj.CurrentContext
 	}
 
-	// Convert the merged config back to bytes
-	mergedConfig, err := k8sclientcmd.Write(*configObj)
+	// Convert the merged config back to bytes, leaving unrelated entries of the source config untouched
+	mergedConfig, err := EditConfig(srcConfig, configObj)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize merged config: %v", err)
 	}
@@ -0,0 +1,96 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ContextName returns the kubeconfig context name used for a DOKS cluster.
+// It matches the naming used by doctl and by the DigitalOcean kubeconfig endpoint,
+// which also names the cluster entry after the context and the user entry after the context with an "-admin" suffix.
+func ContextName(cluster do.Cluster) string {
+	return fmt.Sprintf("do-%s-%s", cluster.Region, cluster.Name)
+}
+
+// QualifiedContextName returns the context name for a cluster whose plain context name collides
+// with a cluster of another team, in the do-<region>-<name>@<qualifier> format.
+func QualifiedContextName(cluster do.Cluster, qualifier string) string {
+	return ContextName(cluster) + "@" + qualifier
+}
+
+// TeamQualifier returns a short identifier of the team a cluster was listed from, for use in QualifiedContextName.
+// It is derived from the team name, falling back to the team UUID and then to the doctl auth context.
+// It returns an empty string if none of them is known.
+func TeamQualifier(cluster do.Cluster) string {
+	if slug := slugify(cluster.Team.Name); slug != "" {
+		return slug
+	}
+	if len(cluster.Team.UUID) >= 8 {
+		return cluster.Team.UUID[:8]
+	}
+	if cluster.Team.UUID != "" {
+		return cluster.Team.UUID
+	}
+	return slugify(cluster.AuthContext)
+}
+
+// slugify lowercases s and replaces every run of characters other than letters and digits with a single dash.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// ClusterNameFromContext extracts the DOKS cluster name from a context name produced by ContextName
+// or QualifiedContextName. It returns false if the context name does not follow the do-<region>-<name> format.
+func ClusterNameFromContext(contextName string) (string, bool) {
+	if i := strings.Index(contextName, "@"); i >= 0 {
+		contextName = contextName[:i]
+	}
+	parts := strings.SplitN(contextName, "-", 3)
+	if len(parts) != 3 || parts[0] != "do" || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// renameContext renames the context from of configObj to to, along with its cluster and "-admin" user entries,
+// so that all three follow the naming convention for the new context name.
+func renameContext(configObj *k8sclientcmdapi.Config, from, to string) error {
+	context, ok := configObj.Contexts[from]
+	if !ok {
+		return fmt.Errorf("context %q not found", from)
+	}
+	delete(configObj.Contexts, from)
+	configObj.Contexts[to] = context
+
+	if cluster, ok := configObj.Clusters[context.Cluster]; ok {
+		delete(configObj.Clusters, context.Cluster)
+		configObj.Clusters[to] = cluster
+		context.Cluster = to
+	}
+	if authInfo, ok := configObj.AuthInfos[context.AuthInfo]; ok {
+		delete(configObj.AuthInfos, context.AuthInfo)
+		configObj.AuthInfos[to+"-admin"] = authInfo
+		context.AuthInfo = to + "-admin"
+	}
+	if configObj.CurrentContext == from {
+		configObj.CurrentContext = to
+	}
+
+	return nil
+}
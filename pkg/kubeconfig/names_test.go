@@ -0,0 +1,99 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+func TestContextName(t *testing.T) {
+	assert.Equal(t, "do-nyc1-my-cluster", ContextName(do.Cluster{ID: "id", Name: "my-cluster", Region: "nyc1"}))
+}
+
+func TestQualifiedContextName(t *testing.T) {
+	cluster := do.Cluster{ID: "id", Name: "api", Region: "nyc1"}
+	assert.Equal(t, "do-nyc1-api@acme", QualifiedContextName(cluster, "acme"))
+}
+
+func TestTeamQualifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster do.Cluster
+		want    string
+	}{
+		{"team name", do.Cluster{Team: do.Team{UUID: "0123456789ab", Name: "Acme Prod!"}}, "acme-prod"},
+		{"team UUID", do.Cluster{Team: do.Team{UUID: "0123456789ab"}, AuthContext: "work"}, "01234567"},
+		{"auth context", do.Cluster{AuthContext: "Work_Team"}, "work-team"},
+		{"unknown", do.Cluster{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TeamQualifier(tt.cluster))
+		})
+	}
+}
+
+func TestRenameContext(t *testing.T) {
+	config := []byte(`
+apiVersion: v1
+clusters:
+- cluster:
+    server: https://api-server
+  name: do-nyc1-api
+contexts:
+- context:
+    cluster: do-nyc1-api
+    user: do-nyc1-api-admin
+  name: do-nyc1-api
+current-context: do-nyc1-api
+kind: Config
+users:
+- name: do-nyc1-api-admin
+  user:
+    token: api-token
+`)
+
+	configObj, err := k8sclientcmd.Load(config)
+	require.NoError(t, err)
+	require.NoError(t, renameContext(configObj, "do-nyc1-api", "do-nyc1-api@acme"))
+
+	assert.Equal(t, "do-nyc1-api@acme", configObj.CurrentContext)
+	require.Contains(t, configObj.Contexts, "do-nyc1-api@acme")
+	assert.Equal(t, "do-nyc1-api@acme", configObj.Contexts["do-nyc1-api@acme"].Cluster)
+	assert.Equal(t, "do-nyc1-api@acme-admin", configObj.Contexts["do-nyc1-api@acme"].AuthInfo)
+	assert.Equal(t, "https://api-server", configObj.Clusters["do-nyc1-api@acme"].Server)
+	assert.Equal(t, "api-token", configObj.AuthInfos["do-nyc1-api@acme-admin"].Token)
+	assert.NotContains(t, configObj.Contexts, "do-nyc1-api")
+	assert.NotContains(t, configObj.Clusters, "do-nyc1-api")
+	assert.NotContains(t, configObj.AuthInfos, "do-nyc1-api-admin")
+
+	assert.Error(t, renameContext(configObj, "do-nyc1-missing", "do-nyc1-other"))
+}
+
+func TestClusterNameFromContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		contextName string
+		want        string
+		wantOK      bool
+	}{
+		{"simple name", "do-nyc1-cluster", "cluster", true},
+		{"name with dashes", "do-sfo3-my-prod-cluster", "my-prod-cluster", true},
+		{"not a do context", "kind-kind", "", false},
+		{"missing name", "do-nyc1", "", false},
+		{"empty name", "do-nyc1-", "", false},
+		{"team-qualified name", "do-nyc1-api@acme-prod", "api", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ClusterNameFromContext(tt.contextName)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"sort"
+
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ContextChange is the change of a managed context, along with its cluster and user.
+// Before and After hold the kubeconfig of the context, its cluster and its user as written by clientcmd,
+// before and after the change, and are empty if the context did not exist.
+type ContextChange struct {
+	Context string `json:"context"`
+	Before  string `json:"before,omitempty"`
+	After   string `json:"after,omitempty"`
+}
+
+// Patch records the changes a command made to the contexts managed by kubectl-doks and to the current context,
+// so that exactly those changes can be reverted later, leaving the rest of the kubeconfig alone.
+type Patch struct {
+	Contexts             []ContextChange `json:"contexts,omitempty"`
+	CurrentContextBefore string          `json:"current_context_before,omitempty"`
+	CurrentContextAfter  string          `json:"current_context_after,omitempty"`
+}
+
+// DiffManaged returns the patch from the kubeconfig before to the kubeconfig after, covering the contexts
+// managed by kubectl-doks in either of them whose context, cluster or user changed. Empty kubeconfigs are empty configs.
+func DiffManaged(before, after []byte) (Patch, error) {
+	beforeConfig, err := loadConfig(before)
+	if err != nil {
+		return Patch{}, err
+	}
+	afterConfig, err := loadConfig(after)
+	if err != nil {
+		return Patch{}, err
+	}
+
+	names := make(map[string]bool)
+	for _, config := range []*k8sclientcmdapi.Config{beforeConfig, afterConfig} {
+		for contextName, context := range config.Contexts {
+			if isManagedContext(contextName, context) {
+				names[contextName] = true
+			}
+		}
+	}
+
+	patch := Patch{CurrentContextBefore: beforeConfig.CurrentContext, CurrentContextAfter: afterConfig.CurrentContext}
+	for contextName := range names {
+		beforeText, err := contextText(beforeConfig, contextName)
+		if err != nil {
+			return Patch{}, err
+		}
+		afterText, err := contextText(afterConfig, contextName)
+		if err != nil {
+			return Patch{}, err
+		}
+		if beforeText != afterText {
+			patch.Contexts = append(patch.Contexts, ContextChange{Context: contextName, Before: beforeText, After: afterText})
+		}
+	}
+	sort.Slice(patch.Contexts, func(i, j int) bool { return patch.Contexts[i].Context < patch.Contexts[j].Context })
+	return patch, nil
+}
+
+// Empty reports whether the patch changes nothing.
+func (p Patch) Empty() bool {
+	return len(p.Contexts) == 0 && p.CurrentContextBefore == p.CurrentContextAfter
+}
+
+// Changes returns the names of the contexts the patch adds, updates and removes.
+func (p Patch) Changes() (added, updated, removed []string) {
+	for _, change := range p.Contexts {
+		switch {
+		case change.Before == "":
+			added = append(added, change.Context)
+		case change.After == "":
+			removed = append(removed, change.Context)
+		default:
+			updated = append(updated, change.Context)
+		}
+	}
+	return added, updated, removed
+}
+
+// Conflicts returns the names of the contexts of the patch that changed in config since the patch was made,
+// and so cannot be reverted without losing those changes.
+func (p Patch) Conflicts(config *k8sclientcmdapi.Config) ([]string, error) {
+	var conflicts []string
+	for _, change := range p.Contexts {
+		text, err := contextText(config, change.Context)
+		if err != nil {
+			return nil, err
+		}
+		if text != change.After {
+			conflicts = append(conflicts, change.Context)
+		}
+	}
+	return conflicts, nil
+}
+
+// Revert undoes the patch in config: each context of the patch, along with its cluster and user, is restored to
+// what it was before, or removed if it did not exist. The previous current context is restored if the current
+// context is still the one the patch set or was removed, and the previous one still exists.
+func (p Patch) Revert(config *k8sclientcmdapi.Config) error {
+	currentContext := config.CurrentContext
+	for _, change := range p.Contexts {
+		removeContexts(config, []string{change.Context})
+		if change.Before == "" {
+			continue
+		}
+		before, err := k8sclientcmd.Load([]byte(change.Before))
+		if err != nil {
+			return fmt.Errorf("failed to parse the previous entries of context %s: %v", change.Context, err)
+		}
+		mergeKubeConfigObjects(config, before)
+	}
+
+	config.CurrentContext = ""
+	if _, ok := config.Contexts[currentContext]; ok && currentContext != p.CurrentContextAfter {
+		config.CurrentContext = currentContext
+	} else if _, ok := config.Contexts[p.CurrentContextBefore]; ok {
+		config.CurrentContext = p.CurrentContextBefore
+	}
+	return nil
+}
+
+// loadConfig parses a kubeconfig. An empty kubeconfig is an empty config.
+func loadConfig(data []byte) (*k8sclientcmdapi.Config, error) {
+	if len(data) == 0 {
+		return k8sclientcmdapi.NewConfig(), nil
+	}
+	config, err := k8sclientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+	return config, nil
+}
+
+// contextText returns the kubeconfig holding only the named context of config, its cluster and its user,
+// as written by clientcmd, or an empty string if config has no such context.
+func contextText(config *k8sclientcmdapi.Config, contextName string) (string, error) {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return "", nil
+	}
+
+	subset := k8sclientcmdapi.NewConfig()
+	subset.Contexts[contextName] = context
+	if cluster, ok := config.Clusters[context.Cluster]; ok {
+		subset.Clusters[context.Cluster] = cluster
+	}
+	if authInfo, ok := config.AuthInfos[context.AuthInfo]; ok {
+		subset.AuthInfos[context.AuthInfo] = authInfo
+	}
+
+	written, err := k8sclientcmd.Write(*subset)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize context %s: %v", contextName, err)
+	}
+	return string(written), nil
+}
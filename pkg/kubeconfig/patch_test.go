@@ -0,0 +1,124 @@
+package kubeconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// patchedKubeconfig is handEditedKubeconfig after a sync that renewed do-nyc1-prod, added do-sfo3-dev and made it current.
+const patchedKubeconfig = `apiVersion: v1
+kind: Config
+current-context: do-sfo3-dev
+clusters:
+  - name: kind-kind
+    cluster:
+      server: https://127.0.0.1:6443
+  - name: do-nyc1-prod
+    cluster:
+      server: https://prod-server
+  - name: do-sfo3-dev
+    cluster:
+      server: https://dev-server
+users:
+  - name: kind-kind
+    user:
+      token: kind-token
+  - name: do-nyc1-prod-admin
+    user:
+      token: new-prod-token
+  - name: do-sfo3-dev-admin
+    user:
+      token: dev-token
+contexts:
+  - name: kind-kind
+    context:
+      cluster: kind-kind
+      user: kind-kind
+  - name: do-nyc1-prod
+    context:
+      cluster: do-nyc1-prod
+      user: do-nyc1-prod-admin
+  - name: do-sfo3-dev
+    context:
+      cluster: do-sfo3-dev
+      user: do-sfo3-dev-admin
+`
+
+func TestDiffManaged(t *testing.T) {
+	patch, err := DiffManaged([]byte(handEditedKubeconfig), []byte(patchedKubeconfig))
+	require.NoError(t, err)
+
+	require.Len(t, patch.Contexts, 2)
+	assert.Equal(t, "do-nyc1-prod", patch.Contexts[0].Context)
+	assert.Contains(t, patch.Contexts[0].Before, "prod-token")
+	assert.Contains(t, patch.Contexts[0].After, "new-prod-token")
+	assert.Equal(t, "do-sfo3-dev", patch.Contexts[1].Context)
+	assert.Empty(t, patch.Contexts[1].Before)
+	assert.Equal(t, "kind-kind", patch.CurrentContextBefore)
+	assert.Equal(t, "do-sfo3-dev", patch.CurrentContextAfter)
+	assert.False(t, patch.Empty())
+
+	added, updated, removed := patch.Changes()
+	assert.Equal(t, []string{"do-sfo3-dev"}, added)
+	assert.Equal(t, []string{"do-nyc1-prod"}, updated)
+	assert.Empty(t, removed)
+
+	unchanged, err := DiffManaged([]byte(handEditedKubeconfig), []byte(handEditedKubeconfig))
+	require.NoError(t, err)
+	assert.True(t, unchanged.Empty())
+
+	fromEmpty, err := DiffManaged(nil, []byte(handEditedKubeconfig))
+	require.NoError(t, err)
+	added, _, _ = fromEmpty.Changes()
+	assert.Equal(t, []string{"do-nyc1-prod"}, added, "Only managed contexts should be in the patch")
+}
+
+func TestPatchRevert(t *testing.T) {
+	patch, err := DiffManaged([]byte(handEditedKubeconfig), []byte(patchedKubeconfig))
+	require.NoError(t, err)
+
+	t.Run("reverts the managed contexts and keeps other edits", func(t *testing.T) {
+		config := loadForEdit(t, patchedKubeconfig)
+		config.Clusters["minikube"] = &k8sclientcmdapi.Cluster{Server: "https://minikube"}
+
+		conflicts, err := patch.Conflicts(config)
+		require.NoError(t, err)
+		assert.Empty(t, conflicts)
+
+		require.NoError(t, patch.Revert(config))
+		assert.Equal(t, "kind-kind", config.CurrentContext)
+		assert.Equal(t, "prod-token", config.AuthInfos["do-nyc1-prod-admin"].Token)
+		assert.NotContains(t, config.Contexts, "do-sfo3-dev")
+		assert.NotContains(t, config.Clusters, "do-sfo3-dev")
+		assert.NotContains(t, config.AuthInfos, "do-sfo3-dev-admin")
+		assert.Contains(t, config.Clusters, "minikube")
+
+		reverted, err := k8sclientcmd.Write(*config)
+		require.NoError(t, err)
+		again, err := DiffManaged([]byte(handEditedKubeconfig), reverted)
+		require.NoError(t, err)
+		assert.Empty(t, again.Contexts)
+	})
+
+	t.Run("keeps a current context switched to since", func(t *testing.T) {
+		config := loadForEdit(t, patchedKubeconfig)
+		config.CurrentContext = "do-nyc1-prod"
+
+		require.NoError(t, patch.Revert(config))
+		assert.Equal(t, "do-nyc1-prod", config.CurrentContext)
+	})
+
+	t.Run("reports contexts changed since", func(t *testing.T) {
+		config := loadForEdit(t, patchedKubeconfig)
+		config.Contexts["do-sfo3-dev"].Namespace = "apps"
+		delete(config.Contexts, "do-nyc1-prod")
+
+		conflicts, err := patch.Conflicts(config)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"do-nyc1-prod", "do-sfo3-dev"}, conflicts)
+	})
+}
@@ -2,16 +2,30 @@ package kubeconfig
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/DO-Solutions/kubectl-doks/do"
 	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // PruneConfig removes contexts, clusters, and users whose context names start with 'do-'
 // but whose corresponding cluster no longer exists in the list of liveClusters.
 // It returns the pruned configuration as a byte array along with a slice of removed context names.
 func PruneConfig(config []byte, liveClusters []do.Cluster) ([]byte, []string, error) {
+	var liveContextNames []string
+	for _, cluster := range liveClusters {
+		liveContextNames = append(liveContextNames, ContextName(cluster))
+	}
+	return PruneContexts(config, liveContextNames)
+}
+
+// PruneContexts removes contexts, clusters, and users whose context names start with 'do-'
+// but are not in liveContextNames. It is used instead of PruneConfig when live clusters
+// do not all use the plain context name, for example because of team-qualified names.
+// It returns the pruned configuration as a byte array along with a slice of removed context names.
+func PruneContexts(config []byte, liveContextNames []string) ([]byte, []string, error) {
 	if len(config) == 0 {
 		return []byte{}, nil, nil
 	}
@@ -22,29 +36,64 @@ func PruneConfig(config []byte, liveClusters []do.Cluster) ([]byte, []string, er
 		return nil, nil, fmt.Errorf("failed to parse kubeconfig: %v", err)
 	}
 
+	removedContexts := pruneContexts(configObj, liveContextNames)
+
+	// Write the pruned config back to bytes, leaving unrelated entries untouched
+	prunedConfig, err := EditConfig(config, configObj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write pruned kubeconfig: %v", err)
+	}
+
+	return prunedConfig, removedContexts, nil
+}
+
+// ManagedContexts returns the sorted names of the contexts of configObj managed by kubectl-doks, which Prune may remove.
+func ManagedContexts(configObj *k8sclientcmdapi.Config) []string {
+	var contextNames []string
+	for contextName, context := range configObj.Contexts {
+		if isManagedContext(contextName, context) {
+			contextNames = append(contextNames, contextName)
+		}
+	}
+	sort.Strings(contextNames)
+	return contextNames
+}
+
+// isManagedContext reports whether a context is managed by kubectl-doks:
+// its name starts with do- and its cluster and user follow the naming of the DigitalOcean kubeconfig endpoint.
+func isManagedContext(contextName string, context *k8sclientcmdapi.Context) bool {
+	return strings.HasPrefix(contextName, "do-") &&
+		context.Cluster == contextName &&
+		context.AuthInfo == contextName+"-admin"
+}
+
+// pruneContexts removes the managed contexts of configObj that are not in liveContextNames,
+// along with their clusters and users, and returns the names of the removed contexts.
+func pruneContexts(configObj *k8sclientcmdapi.Config, liveContextNames []string) []string {
 	// Create a map of live cluster context names for quick lookup
 	liveContexts := make(map[string]bool)
-	for _, cluster := range liveClusters {
-		contextName := fmt.Sprintf("do-%s-%s", cluster.Region, cluster.Name)
+	for _, contextName := range liveContextNames {
 		liveContexts[contextName] = true
 	}
 
 	var removedContexts []string
 	for contextName, context := range configObj.Contexts {
-		// A context is managed by us if it starts with do- and the cluster and user match the expected format.
-		isManaged := strings.HasPrefix(contextName, "do-") &&
-			context.Cluster == contextName &&
-			context.AuthInfo == contextName+"-admin"
-
-		if isManaged {
+		if isManagedContext(contextName, context) {
 			if !liveContexts[contextName] {
 				removedContexts = append(removedContexts, contextName)
 			}
 		}
 	}
 
-	// Remove stale contexts and their associated clusters and users
-	for _, contextName := range removedContexts {
+	removeContexts(configObj, removedContexts)
+
+	return removedContexts
+}
+
+// removeContexts removes the given contexts of configObj, along with their clusters and users
+// unless they are still used by a remaining context. The current context is cleared if it is removed.
+func removeContexts(configObj *k8sclientcmdapi.Config, contextNames []string) {
+	for _, contextName := range contextNames {
 		ctx, exists := configObj.Contexts[contextName]
 		if !exists {
 			continue
@@ -88,7 +137,7 @@ func PruneConfig(config []byte, liveClusters []do.Cluster) ([]byte, []string, er
 
 	// If the current context was removed, clear it
 	currentContextRemoved := false
-	for _, removed := range removedContexts {
+	for _, removed := range contextNames {
 		if configObj.CurrentContext == removed {
 			currentContextRemoved = true
 			break
@@ -97,12 +146,4 @@ func PruneConfig(config []byte, liveClusters []do.Cluster) ([]byte, []string, er
 	if currentContextRemoved {
 		configObj.CurrentContext = ""
 	}
-
-	// Write the pruned config back to bytes
-	prunedConfig, err := k8sclientcmd.Write(*configObj)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to write pruned kubeconfig: %v", err)
-	}
-
-	return prunedConfig, removedContexts, nil
 }
@@ -0,0 +1,89 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+	k8sclientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Reconciler applies all the changes of a sync or save to a kubeconfig in memory.
+// The kubeconfig is parsed once when the Reconciler is created, every addition, update and removal is applied
+// to the same api.Config, and the result is serialized once by Bytes, keeping the text of unchanged entries.
+type Reconciler struct {
+	// Now returns the current time, recorded as when clusters were synced; time.Now if nil.
+	Now func() time.Time
+
+	original       []byte
+	originalConfig *k8sclientcmdapi.Config
+	config         *k8sclientcmdapi.Config
+}
+
+// NewReconciler parses the kubeconfig original. An empty original starts from an empty config.
+func NewReconciler(original []byte) (*Reconciler, error) {
+	if len(original) == 0 {
+		return &Reconciler{config: k8sclientcmdapi.NewConfig()}, nil
+	}
+
+	config, err := k8sclientcmd.Load(original)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+	return &Reconciler{original: original, originalConfig: config.DeepCopy(), config: config}, nil
+}
+
+// Config returns the config being reconciled. Changes made to it are included in Bytes.
+func (r *Reconciler) Config() *k8sclientcmdapi.Config {
+	return r.config
+}
+
+// OriginalCurrentContext returns the current context of the kubeconfig before any change.
+func (r *Reconciler) OriginalCurrentContext() string {
+	if r.originalConfig == nil {
+		return ""
+	}
+	return r.originalConfig.CurrentContext
+}
+
+// Prune removes the contexts managed by kubectl-doks that are not in liveContextNames, along with their
+// clusters and users, like PruneContexts. It returns the names of the removed contexts.
+func (r *Reconciler) Prune(liveContextNames []string) []string {
+	return pruneContexts(r.config, liveContextNames)
+}
+
+// PruneExpired removes the contexts managed by kubectl-doks whose credentials expired before now, along with
+// their clusters and users unless other contexts still use them, like Prune. It returns the names of the removed contexts.
+func (r *Reconciler) PruneExpired(now time.Time) []string {
+	expired := ExpiringContexts(r.config, now)
+	removeContexts(r.config, expired)
+	return expired
+}
+
+// AddCluster adds the entries for cluster, built from credentials, to the config under contextName,
+// replacing any existing entries with the same names.
+// The cluster entry is tagged with the details of the cluster and when it was synced,
+// and the user entry with when the credentials expire and the lifetime requested for them.
+// The current context is left unchanged.
+func (r *Reconciler) AddCluster(cluster do.Cluster, contextName string, credentials do.Credentials) {
+	mergeKubeConfigObjects(r.config, CredentialsConfig(contextName, credentials))
+
+	extension := ClusterExtension(cluster)
+	extension.SyncedAt = r.now()
+	SetExtension(r.config.Clusters[contextName], extension)
+	SetUserExtension(r.config.AuthInfos[contextName+"-admin"], Extension{ExpiresAt: credentials.ExpiresAt, ExpirySeconds: credentials.ExpirySeconds})
+}
+
+// now returns the current time according to Now.
+func (r *Reconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Bytes serializes the reconciled config. Entries that did not change keep their exact text from the original kubeconfig.
+func (r *Reconciler) Bytes() ([]byte, error) {
+	return editOrWriteConfig(r.original, r.originalConfig, r.config)
+}
@@ -0,0 +1,220 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sclientcmd "k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterKubeconfig returns a kubeconfig like the one returned by the DigitalOcean API for cluster.
+func clusterKubeconfig(cluster do.Cluster) []byte {
+	name := ContextName(cluster)
+	return []byte(fmt.Sprintf(`apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: Y2VydGlmaWNhdGU=
+    server: https://%[2]s.k8s.ondigitalocean.com
+  name: %[1]s
+contexts:
+- context:
+    cluster: %[1]s
+    user: %[1]s-admin
+  name: %[1]s
+current-context: %[1]s
+kind: Config
+preferences: {}
+users:
+- name: %[1]s-admin
+  user:
+    token: %[2]s-token
+`, name, cluster.ID))
+}
+
+// clusterCredentials returns credentials like the ones returned by the DigitalOcean API for cluster.
+func clusterCredentials(cluster do.Cluster) do.Credentials {
+	return do.Credentials{
+		Server:                   fmt.Sprintf("https://%s.k8s.ondigitalocean.com", cluster.ID),
+		CertificateAuthorityData: []byte("certificate"),
+		Token:                    cluster.ID + "-token",
+		ExpiresAt:                time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// largeKubeconfig returns a kubeconfig with n DOKS contexts, for clusters cluster-0 to cluster-<n-1>.
+func largeKubeconfig(n int) ([]byte, []do.Cluster) {
+	var clusters, contexts, users strings.Builder
+	var live []do.Cluster
+	for i := 0; i < n; i++ {
+		cluster := do.Cluster{ID: fmt.Sprintf("id-%d", i), Name: fmt.Sprintf("cluster-%d", i), Region: "nyc1"}
+		live = append(live, cluster)
+		name := ContextName(cluster)
+		fmt.Fprintf(&clusters, "- cluster:\n    server: https://%s.k8s.ondigitalocean.com\n  name: %s\n", cluster.ID, name)
+		fmt.Fprintf(&contexts, "- context:\n    cluster: %[1]s\n    user: %[1]s-admin\n  name: %[1]s\n", name)
+		fmt.Fprintf(&users, "- name: %s-admin\n  user:\n    token: %s-token\n", name, cluster.ID)
+	}
+	config := "apiVersion: v1\nclusters:\n" + clusters.String() + "contexts:\n" + contexts.String() +
+		"current-context: do-nyc1-cluster-0\nkind: Config\npreferences: {}\nusers:\n" + users.String()
+	return []byte(config), live
+}
+
+func TestReconciler(t *testing.T) {
+	original := `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: do-nyc1-old
+clusters:
+  - name: kind-kind
+    cluster:
+      server: https://127.0.0.1:6443
+  - name: do-nyc1-old
+    cluster:
+      server: https://old-server
+users:
+  - name: kind-kind
+    user:
+      token: kind-token
+  - name: do-nyc1-old-admin
+    user:
+      token: old-token
+contexts:
+  - name: kind-kind
+    context:
+      cluster: kind-kind
+      user: kind-kind
+  - name: do-nyc1-old
+    context:
+      cluster: do-nyc1-old
+      user: do-nyc1-old-admin
+`
+	reconciler, err := NewReconciler([]byte(original))
+	require.NoError(t, err)
+
+	api := do.Cluster{ID: "api-id", Name: "api", Region: "nyc1", Status: "running", Team: do.Team{Name: "Acme"}, AuthContext: "acme"}
+	reconciler.AddCluster(api, "do-nyc1-api@acme", clusterCredentials(api))
+	assert.Equal(t, []string{"do-nyc1-old"}, reconciler.Prune([]string{"do-nyc1-api@acme"}))
+	assert.Equal(t, "do-nyc1-old", reconciler.OriginalCurrentContext())
+
+	config := reconciler.Config()
+	assert.Empty(t, config.CurrentContext, "The removed current context should be cleared")
+	require.Contains(t, config.Contexts, "do-nyc1-api@acme")
+	assert.Equal(t, "do-nyc1-api@acme-admin", config.Contexts["do-nyc1-api@acme"].AuthInfo)
+	id, ok := GetClusterID(config.Clusters["do-nyc1-api@acme"])
+	assert.True(t, ok)
+	assert.Equal(t, "api-id", id)
+	team, authContext, _ := GetClusterSource(config.Clusters["do-nyc1-api@acme"])
+	assert.Equal(t, "Acme", team.Name)
+	assert.Equal(t, "acme", authContext)
+	status, _ := GetClusterStatus(config.Clusters["do-nyc1-api@acme"])
+	assert.Equal(t, "running", status)
+	expiresAt, ok := GetCredentialsExpiry(config.AuthInfos["do-nyc1-api@acme-admin"])
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), expiresAt)
+	assert.Equal(t, "api-id-token", config.AuthInfos["do-nyc1-api@acme-admin"].Token)
+
+	out, err := reconciler.Bytes()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), `# Managed by my dotfiles.
+apiVersion: v1
+kind: Config
+current-context: ""
+clusters:
+  - name: kind-kind
+    cluster:
+      server: https://127.0.0.1:6443
+  - cluster:
+`), "unexpected kubeconfig:\n%s", out)
+	assert.NotContains(t, string(out), "do-nyc1-old")
+
+	written, err := k8sclientcmd.Load(out)
+	require.NoError(t, err)
+	assert.Len(t, written.Contexts, 2)
+}
+
+func TestReconcilerEmptyKubeconfig(t *testing.T) {
+	reconciler, err := NewReconciler(nil)
+	require.NoError(t, err)
+
+	cluster := do.Cluster{ID: "id", Name: "dev", Region: "sfo3"}
+	reconciler.AddCluster(cluster, ContextName(cluster), clusterCredentials(cluster))
+	assert.Empty(t, reconciler.Config().CurrentContext, "Adding a cluster should not change the current context")
+
+	out, err := reconciler.Bytes()
+	require.NoError(t, err)
+	config, err := k8sclientcmd.Load(out)
+	require.NoError(t, err)
+	assert.Contains(t, config.Contexts, "do-sfo3-dev")
+}
+
+func TestReconcilerAddClusterSyncedAt(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	reconciler, err := NewReconciler(nil)
+	require.NoError(t, err)
+	reconciler.Now = func() time.Time { return now }
+
+	cluster := do.Cluster{ID: "id", Name: "dev", Region: "sfo3"}
+	reconciler.AddCluster(cluster, ContextName(cluster), clusterCredentials(cluster))
+
+	extension, ok := GetExtension(reconciler.Config().Clusters["do-sfo3-dev"])
+	require.True(t, ok)
+	assert.Equal(t, now, extension.SyncedAt, "SyncedAt should come from the clock of the reconciler")
+}
+
+// BenchmarkReconciler500Contexts syncs a kubeconfig with 500 DOKS contexts where 10 clusters were deleted
+// and 10 were created, the way sync does it: parse once, apply every change, serialize once.
+func BenchmarkReconciler500Contexts(b *testing.B) {
+	original, live := largeKubeconfig(500)
+	live = live[10:]
+	var added []do.Cluster
+	for i := 0; i < 10; i++ {
+		added = append(added, do.Cluster{ID: fmt.Sprintf("new-id-%d", i), Name: fmt.Sprintf("new-cluster-%d", i), Region: "sfo3"})
+	}
+	var liveContextNames []string
+	for _, cluster := range append(live, added...) {
+		liveContextNames = append(liveContextNames, ContextName(cluster))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reconciler, err := NewReconciler(original)
+		if err != nil {
+			b.Fatal(err)
+		}
+		reconciler.Prune(liveContextNames)
+		for _, cluster := range added {
+			reconciler.AddCluster(cluster, ContextName(cluster), clusterCredentials(cluster))
+		}
+		if _, err := reconciler.Bytes(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMergeConfigPerCluster500Contexts applies the same changes as BenchmarkReconciler500Contexts
+// by pruning and merging each cluster through bytes, for comparison.
+func BenchmarkMergeConfigPerCluster500Contexts(b *testing.B) {
+	original, live := largeKubeconfig(500)
+	live = live[10:]
+	var added []do.Cluster
+	for i := 0; i < 10; i++ {
+		added = append(added, do.Cluster{ID: fmt.Sprintf("new-id-%d", i), Name: fmt.Sprintf("new-cluster-%d", i), Region: "sfo3"})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		current, _, err := PruneConfig(original, append(live, added...))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, cluster := range added {
+			if current, err = MergeConfig(current, clusterKubeconfig(cluster), false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
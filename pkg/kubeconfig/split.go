@@ -0,0 +1,139 @@
+package kubeconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SplitIndexFile is the name of the index file written to a split layout directory.
+// It does not end in .yaml, so that tools loading every kubeconfig of the directory skip it.
+const SplitIndexFile = "index.json"
+
+// SplitIndexEntry describes one cluster file of a split layout directory in its index file.
+type SplitIndexEntry struct {
+	ClusterID string `json:"cluster_id"`
+	Name      string `json:"name"`
+	Context   string `json:"context"`
+	Region    string `json:"region"`
+	Team      string `json:"team,omitempty"`
+	File      string `json:"file"`
+}
+
+// SplitFilePath returns the path of the kubeconfig file of the cluster with the given ID in a split layout directory.
+func SplitFilePath(dir, clusterID string) string {
+	return filepath.Join(dir, clusterID+".yaml")
+}
+
+// SplitFiles returns the paths of the kubeconfig files in a split layout directory, sorted.
+// It returns no paths and no error if the directory does not exist.
+func SplitFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading kubeconfig directory %s: %v", dir, err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".yaml") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// StaleSplitFiles returns the paths of the kubeconfig files of a split layout directory that PruneSplitFiles would
+// remove, along with the number of files that only hold contexts managed by kubectl-doks.
+func StaleSplitFiles(dir string, liveClusterIDs []string) ([]string, int, error) {
+	paths, err := SplitFiles(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var stale []string
+	managed := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading kubeconfig at %s: %v", path, err)
+		}
+		reconciler, err := NewReconciler(data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading kubeconfig at %s: %v", path, err)
+		}
+		config := reconciler.Config()
+		if len(config.Contexts) == 0 || len(ManagedContexts(config)) < len(config.Contexts) {
+			continue
+		}
+		managed++
+
+		// Files are named after the cluster ID rather than the context name, so the file of a deleted cluster
+		// is stale even if a new cluster with the same name and region now uses its context name.
+		if !slices.Contains(liveClusterIDs, strings.TrimSuffix(filepath.Base(path), ".yaml")) {
+			stale = append(stale, path)
+		}
+	}
+	return stale, managed, nil
+}
+
+// PruneSplitFiles removes the kubeconfig files of a split layout directory that only hold contexts managed by
+// kubectl-doks and are not the file of a cluster in liveClusterIDs, as named by SplitFilePath.
+// Files holding other contexts are left alone. It returns the paths of the removed files.
+func PruneSplitFiles(dir string, liveClusterIDs []string) ([]string, error) {
+	stale, _, err := StaleSplitFiles(dir, liveClusterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("removing stale kubeconfig at %s: %v", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// WriteSplitFile writes the config of reconciler to path, creating its directory if needed.
+func WriteSplitFile(path string, reconciler *Reconciler) error {
+	data, err := reconciler.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating kubeconfig directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing kubeconfig at %s: %v", path, err)
+	}
+	return nil
+}
+
+// WriteSplitIndex writes the index file of a split layout directory, listing entries sorted by context name.
+func WriteSplitIndex(dir string, entries []SplitIndexEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Context < entries[j].Context })
+	if entries == nil {
+		entries = []SplitIndexEntry{}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode kubeconfig index: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating kubeconfig directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SplitIndexFile), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("writing kubeconfig index: %v", err)
+	}
+	return nil
+}
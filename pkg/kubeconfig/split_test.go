@@ -0,0 +1,106 @@
+package kubeconfig
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneSplitFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string) string {
+		cluster := do.Cluster{ID: name + "-id", Name: name, Region: "nyc1"}
+		path := SplitFilePath(dir, cluster.ID)
+		reconciler, err := NewReconciler(nil)
+		require.NoError(t, err)
+		reconciler.AddCluster(cluster, ContextName(cluster), clusterCredentials(cluster))
+		require.NoError(t, WriteSplitFile(path, reconciler))
+		return path
+	}
+	live := writeFile("live")
+	stale := writeFile("stale")
+	foreign := filepath.Join(dir, "foreign.yaml")
+	require.NoError(t, os.WriteFile(foreign, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: minikube
+  cluster:
+    server: https://127.0.0.1:8443
+contexts:
+- name: minikube
+  context:
+    cluster: minikube
+    user: minikube
+users:
+- name: minikube
+  user:
+    token: token
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a kubeconfig"), 0600))
+
+	staleFiles, managed, err := StaleSplitFiles(dir, []string{"live-id"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{stale}, staleFiles)
+	assert.Equal(t, 2, managed, "Files of other tools should not be counted")
+
+	removed, err := PruneSplitFiles(dir, []string{"live-id"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{stale}, removed)
+
+	paths, err := SplitFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{foreign, live}, paths, "Files of live clusters and of other tools should be kept")
+}
+
+func TestPruneSplitFilesRecreatedCluster(t *testing.T) {
+	dir := t.TempDir()
+
+	// A cluster deleted and recreated with the same name and region keeps its context name but not its ID.
+	writeFile := func(id string) string {
+		cluster := do.Cluster{ID: id, Name: "api", Region: "nyc1"}
+		path := SplitFilePath(dir, cluster.ID)
+		reconciler, err := NewReconciler(nil)
+		require.NoError(t, err)
+		reconciler.AddCluster(cluster, ContextName(cluster), clusterCredentials(cluster))
+		require.NoError(t, WriteSplitFile(path, reconciler))
+		return path
+	}
+	old := writeFile("old-id")
+	recreated := writeFile("new-id")
+
+	removed, err := PruneSplitFiles(dir, []string{"new-id"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{old}, removed)
+
+	paths, err := SplitFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{recreated}, paths)
+}
+
+func TestSplitFilesMissingDir(t *testing.T) {
+	paths, err := SplitFiles(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+func TestWriteSplitIndex(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "doks")
+	require.NoError(t, WriteSplitIndex(dir, []SplitIndexEntry{
+		{ClusterID: "b-id", Name: "b", Context: "do-nyc1-b", Region: "nyc1", File: "b-id.yaml"},
+		{ClusterID: "a-id", Name: "a", Context: "do-nyc1-a", Region: "nyc1", Team: "Acme", File: "a-id.yaml"},
+	}))
+
+	data, err := os.ReadFile(filepath.Join(dir, SplitIndexFile))
+	require.NoError(t, err)
+	var entries []SplitIndexEntry
+	require.NoError(t, json.Unmarshal(data, &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "do-nyc1-a", entries[0].Context, "Entries should be sorted by context name")
+	assert.Equal(t, "Acme", entries[0].Team)
+}
@@ -0,0 +1,147 @@
+// Package manifests builds the Kubernetes manifests that register DOKS clusters with GitOps tools,
+// such as Argo CD cluster Secrets and Flux kubeconfig Secrets.
+package manifests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/DO-Solutions/kubectl-doks/pkg/kubeconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Labels set on every Secret, describing the DOKS cluster it gives access to.
+const (
+	ClusterIDLabel = "digitalocean.com/cluster-id"
+	RegionLabel    = "digitalocean.com/region"
+	TeamLabel      = "digitalocean.com/team"
+)
+
+// ArgoCDSecretTypeLabel marks a Secret as an Argo CD cluster, with the value "cluster".
+const ArgoCDSecretTypeLabel = "argocd.argoproj.io/secret-type"
+
+// FluxKubeconfigKey is the key of a Flux kubeconfig Secret holding the kubeconfig, which Flux reads by default.
+const FluxKubeconfigKey = "value"
+
+// Secret is a Kubernetes Secret manifest with its data given as strings.
+type Secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   Metadata          `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// Metadata is the metadata of a Secret manifest.
+type Metadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// argoCDConfig is the connection configuration of an Argo CD cluster Secret, stored as JSON under "config".
+type argoCDConfig struct {
+	BearerToken     string                `json:"bearerToken,omitempty"`
+	TLSClientConfig argoCDTLSClientConfig `json:"tlsClientConfig"`
+}
+
+// argoCDTLSClientConfig holds base64-encoded PEM data, which encoding/json produces from byte slices.
+type argoCDTLSClientConfig struct {
+	Insecure bool   `json:"insecure"`
+	CAData   []byte `json:"caData,omitempty"`
+	CertData []byte `json:"certData,omitempty"`
+	KeyData  []byte `json:"keyData,omitempty"`
+}
+
+// ArgoCDSecret returns an Argo CD cluster Secret for cluster in namespace, named after contextName,
+// which is also the name of the cluster in Argo CD.
+func ArgoCDSecret(cluster do.Cluster, contextName string, credentials do.Credentials, namespace string) (Secret, error) {
+	config, err := json.MarshalIndent(argoCDConfig{
+		BearerToken: credentials.Token,
+		TLSClientConfig: argoCDTLSClientConfig{
+			CAData:   credentials.CertificateAuthorityData,
+			CertData: credentials.ClientCertificateData,
+			KeyData:  credentials.ClientKeyData,
+		},
+	}, "", "  ")
+	if err != nil {
+		return Secret{}, fmt.Errorf("failed to encode Argo CD cluster config: %v", err)
+	}
+
+	secret := newSecret(cluster, contextName, namespace)
+	secret.Metadata.Labels[ArgoCDSecretTypeLabel] = "cluster"
+	secret.StringData = map[string]string{
+		"name":   contextName,
+		"server": credentials.Server,
+		"config": string(config),
+	}
+	return secret, nil
+}
+
+// FluxSecret returns a Secret in namespace holding config, the kubeconfig of cluster, in the format read by
+// the kubeConfig.secretRef of Flux Kustomizations and HelmReleases. It is named after contextName.
+func FluxSecret(cluster do.Cluster, contextName string, config []byte, namespace string) Secret {
+	secret := newSecret(cluster, contextName, namespace)
+	secret.StringData = map[string]string{FluxKubeconfigKey: string(config)}
+	return secret
+}
+
+// newSecret returns an Opaque Secret for cluster with the DigitalOcean labels and no data.
+func newSecret(cluster do.Cluster, contextName, namespace string) Secret {
+	labels := map[string]string{
+		ClusterIDLabel: cluster.ID,
+		RegionLabel:    cluster.Region,
+	}
+	if team := kubeconfig.TeamQualifier(cluster); team != "" {
+		labels[TeamLabel] = labelValue(team)
+	}
+
+	return Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: Metadata{
+			Name:      SecretName(contextName),
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type: "Opaque",
+	}
+}
+
+// SecretName returns a valid Secret name for a context name: characters other than lowercase letters,
+// digits, dashes and dots, such as the "@" of team-qualified context names, are replaced with dashes.
+func SecretName(contextName string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(contextName))
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// labelValue shortens value to the 63 characters allowed in a label value.
+func labelValue(value string) string {
+	if len(value) > 63 {
+		value = strings.TrimRight(value[:63], "-")
+	}
+	return value
+}
+
+// Encode writes secrets to w as a multi-document YAML stream.
+func Encode(w io.Writer, secrets []Secret) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, secret := range secrets {
+		if err := encoder.Encode(secret); err != nil {
+			return fmt.Errorf("failed to encode Secret %s: %v", secret.Metadata.Name, err)
+		}
+	}
+	return encoder.Close()
+}
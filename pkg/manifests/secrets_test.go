@@ -0,0 +1,79 @@
+package manifests
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/DO-Solutions/kubectl-doks/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var testCluster = do.Cluster{
+	ID:     "cluster-id",
+	Name:   "api",
+	Region: "nyc1",
+	Team:   do.Team{UUID: "0123456789ab", Name: "Acme Prod"},
+}
+
+func TestArgoCDSecret(t *testing.T) {
+	secret, err := ArgoCDSecret(testCluster, "do-nyc1-api@acme-prod", do.Credentials{
+		Server:                   "https://api.example.com",
+		CertificateAuthorityData: []byte("ca"),
+		Token:                    "token",
+	}, "argocd")
+	require.NoError(t, err)
+
+	assert.Equal(t, "do-nyc1-api-acme-prod", secret.Metadata.Name)
+	assert.Equal(t, "argocd", secret.Metadata.Namespace)
+	assert.Equal(t, map[string]string{
+		ArgoCDSecretTypeLabel: "cluster",
+		ClusterIDLabel:        "cluster-id",
+		RegionLabel:           "nyc1",
+		TeamLabel:             "acme-prod",
+	}, secret.Metadata.Labels)
+	assert.Equal(t, "do-nyc1-api@acme-prod", secret.StringData["name"])
+	assert.Equal(t, "https://api.example.com", secret.StringData["server"])
+
+	var config struct {
+		BearerToken     string `json:"bearerToken"`
+		TLSClientConfig struct {
+			CAData string `json:"caData"`
+		} `json:"tlsClientConfig"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(secret.StringData["config"]), &config))
+	assert.Equal(t, "token", config.BearerToken)
+	assert.Equal(t, "Y2E=", config.TLSClientConfig.CAData, "The CA data should be base64-encoded")
+}
+
+func TestFluxSecret(t *testing.T) {
+	secret := FluxSecret(do.Cluster{ID: "cluster-id", Region: "nyc1"}, "do-nyc1-api", []byte("kubeconfig"), "flux-system")
+
+	assert.Equal(t, "do-nyc1-api", secret.Metadata.Name)
+	assert.Equal(t, "flux-system", secret.Metadata.Namespace)
+	assert.NotContains(t, secret.Metadata.Labels, TeamLabel, "No team label should be set when the team is unknown")
+	assert.Equal(t, map[string]string{FluxKubeconfigKey: "kubeconfig"}, secret.StringData)
+}
+
+func TestEncode(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Encode(&out, []Secret{
+		FluxSecret(testCluster, "do-nyc1-api", []byte("apiVersion: v1\nkind: Config\n"), "flux-system"),
+		FluxSecret(testCluster, "do-nyc1-web", []byte("apiVersion: v1\nkind: Config\n"), "flux-system"),
+	}))
+
+	decoder := yaml.NewDecoder(&out)
+	var names []string
+	for {
+		var secret Secret
+		if err := decoder.Decode(&secret); err != nil {
+			break
+		}
+		assert.Equal(t, "Secret", secret.Kind)
+		assert.Equal(t, "apiVersion: v1\nkind: Config\n", secret.StringData[FluxKubeconfigKey])
+		names = append(names, secret.Metadata.Name)
+	}
+	assert.Equal(t, []string{"do-nyc1-api", "do-nyc1-web"}, names)
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirName is the name of the directory, relative to ~/.kube, where kubectl-doks keeps its own files.
+const DirName = "kubectl-doks"
+
+// State holds data kubectl-doks persists between invocations.
+type State struct {
+	// PreviousContexts maps a kubeconfig path to the context that was current
+	// before the last `use` switched away from it.
+	PreviousContexts map[string]string `json:"previous_contexts,omitempty"`
+}
+
+// Dir returns the directory where kubectl-doks keeps its own files, ~/.kube/kubectl-doks.
+func Dir() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	return filepath.Join(homedir, ".kube", DirName), nil
+}
+
+// DefaultPath returns the default location of the state file.
+func DefaultPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// Load reads the state file at path.
+// If the file does not exist, it returns an empty state and no error.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{}, nil
+		}
+		return nil, fmt.Errorf("reading state file at %s: %w", path, err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing state file at %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state to path, creating the parent directory if needed.
+// The file is written to a temporary file first and then renamed into place.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing state: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating state directory %s: %w", dir, err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, ".state-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return fmt.Errorf("renaming temp file to state file: %w", err)
+	}
+	return nil
+}
+
+// PreviousContext returns the context that was current before the last switch in the given kubeconfig.
+func (s *State) PreviousContext(kubeconfigPath string) string {
+	return s.PreviousContexts[kubeconfigPath]
+}
+
+// SetPreviousContext records the context that was current before a switch in the given kubeconfig.
+func (s *State) SetPreviousContext(kubeconfigPath, contextName string) {
+	if s.PreviousContexts == nil {
+		s.PreviousContexts = make(map[string]string)
+	}
+	s.PreviousContexts[kubeconfigPath] = contextName
+}
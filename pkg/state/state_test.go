@@ -0,0 +1,51 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	assert.Empty(t, s.PreviousContext("/some/kubeconfig"))
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	s := &State{}
+	s.SetPreviousContext("/home/user/.kube/config", "do-nyc1-cluster")
+	s.SetPreviousContext("/tmp/other", "kind-kind")
+	require.NoError(t, s.Save(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "do-nyc1-cluster", loaded.PreviousContext("/home/user/.kube/config"))
+	assert.Equal(t, "kind-kind", loaded.PreviousContext("/tmp/other"))
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+
+	_, err := Load(path)
+	assert.Error(t, err)
+}
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".kube", "kubectl-doks", "state.json"), path)
+}